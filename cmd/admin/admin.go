@@ -0,0 +1,43 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package admin
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+type adminOptions struct{}
+
+var (
+	adminShort   = ""
+	adminLong    = ""
+	adminExample = ""
+)
+
+func NewAdminCmd(out io.Writer) *cobra.Command {
+	options := adminOptions{}
+	cmd := &cobra.Command{
+		Use:     "admin",
+		Short:   adminShort,
+		Long:    adminLong,
+		Example: adminExample,
+	}
+
+	// create subcommands
+	cmd.AddCommand(NewHealthCmd(options))
+	return cmd
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type HealthOptions struct {
+	*adminOptions
+}
+
+var (
+	healthShort   = "Show the health of Gate and the Spinnaker services behind it"
+	healthLong    = "Show Gate's status, version and response time along with Gate's view of the downstream services it depends on. Gate only reports the services it failed to reach, so those are shown as DOWN and every other service as UNKNOWN, as it may be healthy or not installed at all. Gate doesn't report the latency of the downstream services, only Gate's own response time is shown. Exits with an error when Spinnaker is degraded"
+	healthExample = "usage: spin admin health"
+)
+
+// downstreamServices are the services Gate may check, in the order they are reported.
+var downstreamServices = []string{"orca", "clouddriver", "front50", "fiat", "igor", "echo", "rosco", "kayenta"}
+
+const (
+	healthUp      = "UP"
+	healthDown    = "DOWN"
+	healthUnknown = "UNKNOWN"
+)
+
+// serviceHealth is the health of Gate or of one of the services behind it.
+type serviceHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Version   string `json:"version,omitempty"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type healthReport struct {
+	Gate     serviceHealth   `json:"gate"`
+	Services []serviceHealth `json:"services"`
+}
+
+func NewHealthCmd(adminOptions adminOptions) *cobra.Command {
+	options := HealthOptions{
+		adminOptions: &adminOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "health",
+		Short:   healthShort,
+		Long:    healthLong,
+		Example: healthExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showHealth(cmd, options)
+		},
+	}
+
+	return cmd
+}
+
+func showHealth(cmd *cobra.Command, options HealthOptions) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	gateVersion, resp, err := gateClient.VersionControllerApi.GetVersionUsingGET(gateClient.Context)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error getting the version of Gate, status code: %d\n", resp.StatusCode)
+	}
+
+	start := time.Now()
+	health, resp, err := gateClient.Health()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("Encountered an error getting the health of Gate, status code: %d\n", resp.StatusCode)
+	}
+
+	report := healthReport{
+		Gate: serviceHealth{
+			Name:      "gate",
+			Status:    healthStatus(health),
+			Version:   gateVersion.Version,
+			LatencyMs: int64(time.Since(start) / time.Millisecond),
+		},
+		Services: downstreamHealth(health),
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(report, util.UI.OutputFormat)
+	} else {
+		for _, line := range renderHealth(report) {
+			util.UI.Output(line)
+		}
+	}
+
+	var degraded []string
+	for _, service := range append([]serviceHealth{report.Gate}, report.Services...) {
+		if service.Status == healthDown {
+			degraded = append(degraded, service.Name)
+		}
+	}
+	if len(degraded) > 0 {
+		return fmt.Errorf("Spinnaker is degraded, unhealthy services: %s\n", strings.Join(degraded, ", "))
+	}
+	return nil
+}
+
+func healthStatus(indicator map[string]interface{}) string {
+	if status, _ := indicator["status"].(string); status != "" {
+		return strings.ToUpper(status)
+	}
+	return healthUnknown
+}
+
+// healthComponents returns the health indicators in Gate's health report, which Spring Boot
+// nests under components, or details before 2.2, and kept at the top level before 2.0.
+func healthComponents(health map[string]interface{}) map[string]interface{} {
+	for _, key := range []string{"components", "details"} {
+		if components, ok := health[key].(map[string]interface{}); ok {
+			return components
+		}
+	}
+	return health
+}
+
+// downstreamHealth reads the services Gate failed to reach from its downstreamServices
+// indicator, which carries an error message per failing service.
+func downstreamHealth(health map[string]interface{}) []serviceHealth {
+	indicator, ok := healthComponents(health)["downstreamServices"].(map[string]interface{})
+	var services []serviceHealth
+	if !ok {
+		for _, name := range downstreamServices {
+			services = append(services, serviceHealth{Name: name, Status: healthUnknown, Detail: "not reported by Gate"})
+		}
+		return services
+	}
+
+	failures, _ := indicator["errors"].(map[string]interface{})
+	if details, ok := indicator["details"].(map[string]interface{}); ok && failures == nil {
+		failures, _ = details["errors"].(map[string]interface{})
+	}
+	// Services Gate doesn't list as failing may be up, or may not be installed.
+	detail := "not reported"
+	if healthStatus(indicator) == healthUp {
+		detail = "not reported, Gate's downstream checks passed"
+	}
+
+	known := map[string]bool{}
+	for _, name := range downstreamServices {
+		known[name] = true
+		services = append(services, downstreamService(name, detail, failures))
+	}
+	// Gate may check services beyond the usual ones, such as read-only replicas.
+	var others []string
+	for name := range failures {
+		if !known[strings.ToLower(name)] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		services = append(services, downstreamService(name, detail, failures))
+	}
+	return services
+}
+
+func downstreamService(name string, detail string, failures map[string]interface{}) serviceHealth {
+	for failed, reason := range failures {
+		if strings.EqualFold(failed, name) {
+			return serviceHealth{Name: name, Status: healthDown, Detail: fmt.Sprintf("%v", reason)}
+		}
+	}
+	return serviceHealth{Name: name, Status: healthUnknown, Detail: detail}
+}
+
+func renderHealth(report healthReport) []string {
+	version := report.Gate.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	lines := []string{
+		fmt.Sprintf("%-12s %s %dms (%s)", report.Gate.Name, colorStatus(report.Gate.Status), report.Gate.LatencyMs, version),
+	}
+	for _, service := range report.Services {
+		line := fmt.Sprintf("%-12s %s", service.Name, colorStatus(service.Status))
+		if service.Detail != "" {
+			line += " " + service.Detail
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func colorStatus(status string) string {
+	color := "[yellow]"
+	switch status {
+	case healthUp:
+		color = "[green]"
+	case healthDown:
+		color = "[red]"
+	}
+	return util.Colorize().Color(fmt.Sprintf("[reset][bold]%s%-7s[reset]", color, status))
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestAdminHealth_basic(t *testing.T) {
+	ts := testGateHealth(http.StatusOK, healthUpJson)
+	defer ts.Close()
+
+	currentCmd := NewHealthCmd(adminOptions{})
+	rootCmd := getRootCmdForTest()
+	adminCmd := NewAdminCmd(os.Stdout)
+	adminCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(adminCmd)
+
+	args := []string{"admin", "health", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestAdminHealth_degraded(t *testing.T) {
+	ts := testGateHealth(http.StatusServiceUnavailable, healthDownJson)
+	defer ts.Close()
+
+	currentCmd := NewHealthCmd(adminOptions{})
+	rootCmd := getRootCmdForTest()
+	adminCmd := NewAdminCmd(os.Stdout)
+	adminCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(adminCmd)
+
+	args := []string{"admin", "health", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected a degraded Spinnaker to fail the command")
+	}
+	if !strings.Contains(err.Error(), "gate, clouddriver, fiat") {
+		t.Fatalf("Expected the unhealthy services to be named, got: %s", err)
+	}
+}
+
+func TestAdminHealth_fail(t *testing.T) {
+	ts := testGateHealth(http.StatusInternalServerError, "Internal Server Error")
+	defer ts.Close()
+
+	currentCmd := NewHealthCmd(adminOptions{})
+	rootCmd := getRootCmdForTest()
+	adminCmd := NewAdminCmd(os.Stdout)
+	adminCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(adminCmd)
+
+	args := []string{"admin", "health", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestAdminHealth_downstream(t *testing.T) {
+	tests := []struct {
+		name     string
+		health   string
+		expected map[string]string
+	}{
+		{"up", healthUpJson, map[string]string{"orca": "UNKNOWN", "fiat": "UNKNOWN", "kayenta": "UNKNOWN"}},
+		{"down", healthDownJson, map[string]string{"orca": "UNKNOWN", "clouddriver": "DOWN", "fiat": "DOWN", "clouddriver-ro": "DOWN"}},
+		{"legacy", healthLegacyJson, map[string]string{"front50": "DOWN", "echo": "UNKNOWN"}},
+		{"unreported", `{"status": "UP"}`, map[string]string{"orca": "UNKNOWN"}},
+	}
+	for _, test := range tests {
+		var health map[string]interface{}
+		if err := json.Unmarshal([]byte(test.health), &health); err != nil {
+			t.Fatalf("Could not parse %s health: %s", test.name, err)
+		}
+		statuses := map[string]string{}
+		for _, service := range downstreamHealth(health) {
+			statuses[service.Name] = service.Status
+		}
+		for name, status := range test.expected {
+			if statuses[name] != status {
+				t.Errorf("Expected %s health to report %s as %s, got %q", test.name, name, status, statuses[name])
+			}
+		}
+	}
+}
+
+// testGateHealth spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds to health checks with the given status and report.
+func testGateHealth(status int, health string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintln(w, strings.TrimSpace(health))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const healthUpJson = `
+{
+  "status": "UP",
+  "components": {
+    "diskSpace": {"status": "UP"},
+    "downstreamServices": {"status": "UP"},
+    "redis": {"status": "UP"}
+  }
+}
+`
+
+const healthDownJson = `
+{
+  "status": "DOWN",
+  "components": {
+    "downstreamServices": {
+      "status": "DOWN",
+      "details": {
+        "errors": {
+          "clouddriver": "Connection refused",
+          "clouddriver-ro": "Connection refused",
+          "fiat": "timeout"
+        }
+      }
+    }
+  }
+}
+`
+
+const healthLegacyJson = `
+{
+  "status": "DOWN",
+  "downstreamServices": {
+    "status": "DOWN",
+    "errors": {
+      "front50": "500 Internal Server Error"
+    }
+  }
+}
+`
@@ -30,5 +30,6 @@ func NewApplicationCmd(out io.Writer) *cobra.Command {
 	cmd.AddCommand(NewListCmd(options))
 	cmd.AddCommand(NewDeleteCmd(options))
 	cmd.AddCommand(NewSaveCmd(options))
+	cmd.AddCommand(NewTrafficCmd(options))
 	return cmd
 }
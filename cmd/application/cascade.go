@@ -0,0 +1,360 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spinnaker/spin/cmd/gateclient"
+	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
+	"github.com/spinnaker/spin/util"
+)
+
+// cascadePlan lists the resources depending on an application. Infrastructure is never
+// deleted by a cascade, it blocks the deletion instead.
+type cascadePlan struct {
+	serverGroups  []string
+	loadBalancers []string
+
+	pipelines     []string
+	strategies    []string
+	projects      []map[string]interface{}
+	canaryConfigs []canaryConfigRef
+}
+
+// canaryConfigRef is a canary config used by the application, along with the other
+// applications it is shared with.
+type canaryConfigRef struct {
+	id     string
+	name   string
+	shared []string
+}
+
+func (p *cascadePlan) blocked() bool {
+	return len(p.serverGroups) > 0 || len(p.loadBalancers) > 0
+}
+
+func planCascade(gateClient *gateclient.GatewayClient, application string) (*cascadePlan, error) {
+	plan := &cascadePlan{}
+
+	serverGroups, resp, err := gateClient.ServerGroupControllerApi.GetServerGroupsForApplicationUsingGET(gateClient.Context, application, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing server groups, status code: %d\n", resp.StatusCode)
+	}
+	plan.serverGroups = resourceNames(serverGroups)
+
+	loadBalancers, resp, err := gateClient.LoadBalancerControllerApi.GetApplicationLoadBalancersUsingGET(gateClient.Context, application, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing load balancers, status code: %d\n", resp.StatusCode)
+	}
+	plan.loadBalancers = resourceNames(loadBalancers)
+
+	pipelines, resp, err := gateClient.ApplicationControllerApi.GetPipelineConfigsForApplicationUsingGET(gateClient.Context, application)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing pipelines, status code: %d\n", resp.StatusCode)
+	}
+	plan.pipelines = configNames(pipelines)
+
+	strategies, resp, err := gateClient.ApplicationControllerApi.GetStrategyConfigsForApplicationUsingGET(gateClient.Context, application)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing strategies, status code: %d\n", resp.StatusCode)
+	}
+	plan.strategies = configNames(strategies)
+
+	projects, resp, err := gateClient.ProjectControllerApi.AllUsingGET3(gateClient.Context)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing projects, status code: %d\n", resp.StatusCode)
+	}
+	for _, rawProject := range projects {
+		project, ok := rawProject.(map[string]interface{})
+		if ok && projectReferences(project, application) {
+			plan.projects = append(plan.projects, project)
+		}
+	}
+
+	canaryConfigs, resp, err := gateClient.V2CanaryConfigControllerApi.GetCanaryConfigsUsingGET(gateClient.Context, map[string]interface{}{"application": application})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// Canary analysis is not enabled.
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, rawConfig := range canaryConfigs {
+		config, ok := rawConfig.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := config["id"].(string)
+		if id == "" {
+			continue
+		}
+		var shared []string
+		for _, app := range stringValues(config["applications"]) {
+			if app != application {
+				shared = append(shared, app)
+			}
+		}
+		name, _ := config["name"].(string)
+		plan.canaryConfigs = append(plan.canaryConfigs, canaryConfigRef{id: id, name: name, shared: shared})
+	}
+	return plan, nil
+}
+
+func printCascadePlan(plan *cascadePlan, application string) {
+	util.UI.Output(fmt.Sprintf("Deleting application '%s' will:", application))
+	for _, pipeline := range plan.pipelines {
+		util.UI.Output(fmt.Sprintf("  delete pipeline %s", pipeline))
+	}
+	for _, strategy := range plan.strategies {
+		util.UI.Output(fmt.Sprintf("  delete strategy %s", strategy))
+	}
+	for _, project := range plan.projects {
+		util.UI.Output(fmt.Sprintf("  remove the application from project %v", project["name"]))
+	}
+	for _, config := range plan.canaryConfigs {
+		if len(config.shared) > 0 {
+			util.UI.Output(fmt.Sprintf("  remove the application from canary config %s (%s), shared with %s",
+				config.name, config.id, strings.Join(config.shared, ", ")))
+		} else {
+			util.UI.Output(fmt.Sprintf("  delete canary config %s (%s)", config.name, config.id))
+		}
+	}
+	util.UI.Output("  delete the application")
+}
+
+// deleteDependents deletes or updates everything in the plan that refers to the application.
+func deleteDependents(gateClient *gateclient.GatewayClient, plan *cascadePlan, application string) error {
+	for _, pipeline := range plan.pipelines {
+		resp, err := gateClient.PipelineControllerApi.DeletePipelineUsingDELETE(gateClient.Context, application, pipeline)
+		if err != nil {
+			return fmt.Errorf("Encountered an error deleting pipeline %s: %v\n", pipeline, err)
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("Encountered an error deleting pipeline %s, status code: %d\n", pipeline, resp.StatusCode)
+		}
+	}
+
+	for _, strategy := range plan.strategies {
+		resp, err := deleteStrategy(gateClient, application, strategy)
+		if err != nil {
+			return fmt.Errorf("Encountered an error deleting strategy %s: %v\n", strategy, err)
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("Encountered an error deleting strategy %s, status code: %d\n", strategy, resp.StatusCode)
+		}
+	}
+
+	for _, config := range plan.canaryConfigs {
+		if err := removeCanaryConfig(gateClient, config); err != nil {
+			return err
+		}
+	}
+
+	for _, project := range plan.projects {
+		removeProjectReferences(project, application)
+		upsertTask := map[string]interface{}{
+			"job":         []interface{}{map[string]interface{}{"type": "upsertProject", "project": project}},
+			"application": "spinnaker",
+			"description": fmt.Sprintf("Update Project: %v", project["name"]),
+		}
+		taskRef, resp, err := gateClient.TaskControllerApi.TaskUsingPOST1(gateClient.Context, upsertTask)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error updating project %v, status code: %d\n", project["name"], resp.StatusCode)
+		}
+		if err = orca_tasks.WaitForSuccessfulTask(gateClient, taskRef, 5); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteStrategy deletes a deployment strategy. Gate's generated API client has no method for
+// DELETE /strategies/{application}/{name}, so the request is made with the gate client's HTTP client.
+func deleteStrategy(gateClient *gateclient.GatewayClient, application string, strategy string) (*http.Response, error) {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/strategies/%s/%s", gateClient.GateEndpoint(),
+		url.PathEscape(application), url.PathEscape(strategy)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gateClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// removeCanaryConfig deletes the canary config, or only drops the application from it when the
+// config is shared with other applications.
+func removeCanaryConfig(gateClient *gateclient.GatewayClient, ref canaryConfigRef) error {
+	id := ref.id
+	if len(ref.shared) == 0 {
+		resp, err := gateClient.V2CanaryConfigControllerApi.DeleteCanaryConfigUsingDELETE(gateClient.Context, id, map[string]interface{}{})
+		if err != nil {
+			return fmt.Errorf("Encountered an error deleting canary config %s: %v\n", id, err)
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("Encountered an error deleting canary config %s, status code: %d\n", id, resp.StatusCode)
+		}
+		return nil
+	}
+
+	rawConfig, resp, err := gateClient.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(gateClient.Context, id, map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("Encountered an error retrieving canary config %s: %v\n", id, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error retrieving canary config %s, status code: %d\n", id, resp.StatusCode)
+	}
+	config, _ := rawConfig.(map[string]interface{})
+	if config == nil {
+		return fmt.Errorf("Unexpected canary config %s: %v\n", id, rawConfig)
+	}
+	config["applications"] = ref.shared
+	_, resp, err = gateClient.V2CanaryConfigControllerApi.UpdateCanaryConfigUsingPUT(gateClient.Context, id, config, map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("Encountered an error updating canary config %s: %v\n", id, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error updating canary config %s, status code: %d\n", id, resp.StatusCode)
+	}
+	return nil
+}
+
+func projectReferences(project map[string]interface{}, application string) bool {
+	config, _ := project["config"].(map[string]interface{})
+	for _, app := range stringValues(config["applications"]) {
+		if app == application {
+			return true
+		}
+	}
+	return false
+}
+
+// removeProjectReferences drops the application from the project's applications, clusters
+// and pipelines.
+func removeProjectReferences(project map[string]interface{}, application string) {
+	config, _ := project["config"].(map[string]interface{})
+	if config == nil {
+		return
+	}
+
+	config["applications"] = without(stringValues(config["applications"]), application)
+
+	if rawClusters, ok := config["clusters"].([]interface{}); ok {
+		clusters := []interface{}{}
+		for _, rawCluster := range rawClusters {
+			cluster, ok := rawCluster.(map[string]interface{})
+			if !ok {
+				clusters = append(clusters, rawCluster)
+				continue
+			}
+			if _, scoped := cluster["applications"]; scoped {
+				apps := without(stringValues(cluster["applications"]), application)
+				if len(apps) == 0 {
+					continue
+				}
+				cluster["applications"] = apps
+			}
+			clusters = append(clusters, cluster)
+		}
+		config["clusters"] = clusters
+	}
+
+	if rawPipelines, ok := config["pipelineConfigs"].([]interface{}); ok {
+		pipelines := []interface{}{}
+		for _, rawPipeline := range rawPipelines {
+			if pipeline, ok := rawPipeline.(map[string]interface{}); ok && pipeline["application"] == application {
+				continue
+			}
+			pipelines = append(pipelines, rawPipeline)
+		}
+		config["pipelineConfigs"] = pipelines
+	}
+}
+
+// resourceNames returns account/region/name identifiers of the listed infrastructure.
+func resourceNames(resources []interface{}) []string {
+	var names []string
+	for _, rawResource := range resources {
+		resource, ok := rawResource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parts := []string{}
+		for _, key := range []string{"account", "region", "name"} {
+			if value, _ := resource[key].(string); value != "" {
+				parts = append(parts, value)
+			}
+		}
+		names = append(names, strings.Join(parts, "/"))
+	}
+	return names
+}
+
+func configNames(configs []interface{}) []string {
+	var names []string
+	for _, rawConfig := range configs {
+		if config, ok := rawConfig.(map[string]interface{}); ok {
+			if name, _ := config["name"].(string); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func stringValues(value interface{}) []string {
+	var values []string
+	rawValues, _ := value.([]interface{})
+	for _, rawValue := range rawValues {
+		if v, ok := rawValue.(string); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func without(values []string, value string) []string {
+	remaining := []string{}
+	for _, v := range values {
+		if v != value {
+			remaining = append(remaining, v)
+		}
+	}
+	return remaining
+}
@@ -15,6 +15,7 @@
 package application
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spinnaker/spin/cmd/orca-tasks"
 	"net/http"
@@ -26,27 +27,41 @@ import (
 
 type DeleteOptions struct {
 	*applicationOptions
+	cascade bool
+	dryRun  bool
 }
 
 var (
 	deleteApplicationShort   = "Delete the specified application"
-	deleteApplicationLong    = "Delete the provided application --application-name: Name of the Spinnaker application to delete"
+	deleteApplicationLong    = "Delete the provided application --application-name: Name of the Spinnaker application to delete. With --cascade, the application's pipelines, strategies, project references and canary configs are deleted first"
 	deleteApplicationExample = "usage: spin application delete [options] applicationName"
 )
 
 func NewDeleteCmd(appOptions applicationOptions) *cobra.Command {
+	options := DeleteOptions{
+		applicationOptions: &appOptions,
+	}
 	cmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"del"},
 		Short:   deleteApplicationShort,
 		Long:    deleteApplicationLong,
 		Example: deleteApplicationExample,
-		RunE:    deleteApplication,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return deleteApplication(cmd, options, args)
+		},
 	}
+
+	cmd.PersistentFlags().BoolVar(&options.cascade, "cascade", false, "also delete the application's pipelines, strategies, project references and canary configs. Refuses while server groups or load balancers remain")
+	cmd.PersistentFlags().BoolVar(&options.dryRun, "dry-run", false, "print what a cascading delete would do without deleting anything")
 	return cmd
 }
 
-func deleteApplication(cmd *cobra.Command, args []string) error {
+func deleteApplication(cmd *cobra.Command, options DeleteOptions, args []string) error {
+	if options.dryRun && !options.cascade {
+		return errors.New("--dry-run is only supported with --cascade")
+	}
+
 	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
@@ -74,6 +89,31 @@ func deleteApplication(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Encountered an error checking application existence, status code: %d\n", resp.StatusCode)
 	}
 
+	if options.cascade {
+		plan, err := planCascade(gateClient, applicationName)
+		if err != nil {
+			return err
+		}
+		if plan.blocked() {
+			message := fmt.Sprintf("Application '%s' still has live infrastructure:", applicationName)
+			for _, serverGroup := range plan.serverGroups {
+				message += fmt.Sprintf("\n  server group %s", serverGroup)
+			}
+			for _, loadBalancer := range plan.loadBalancers {
+				message += fmt.Sprintf("\n  load balancer %s", loadBalancer)
+			}
+			util.UI.Error(message + "\n")
+			return fmt.Errorf("Refusing to delete application '%s' with live server groups or load balancers\n", applicationName)
+		}
+		printCascadePlan(plan, applicationName)
+		if options.dryRun {
+			return nil
+		}
+		if err = deleteDependents(gateClient, plan, applicationName); err != nil {
+			return err
+		}
+	}
+
 	deleteAppTask := map[string]interface{}{
 		"job":         []interface{}{appSpec},
 		"application": applicationName,
@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/spinnaker/spin/util"
@@ -79,6 +80,81 @@ func TestApplicationDelete_flags(t *testing.T) {
 	}
 }
 
+func TestApplicationDelete_cascade(t *testing.T) {
+	var requests []string
+	ts := testGateApplicationCascadeSuccess(&requests, "[]")
+	defer ts.Close()
+
+	currentCmd := NewDeleteCmd(applicationOptions{})
+	rootCmd := getRootCmdForTest()
+	appCmd := NewApplicationCmd(os.Stdout)
+	appCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(appCmd)
+
+	args := []string{"application", "delete", NAME, "--cascade", "--gate-endpoint=" + ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	expected := []string{
+		"DELETE /pipelines/app/deploy",
+		"DELETE /strategies/app/highlander",
+		"DELETE /v2/canaryConfig/owned",
+		"PUT /v2/canaryConfig/shared applications=[other]",
+		"POST /tasks upsertProject applications=[other]",
+		"POST /tasks deleteApplication",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestApplicationDelete_cascadeDryRun(t *testing.T) {
+	var requests []string
+	ts := testGateApplicationCascadeSuccess(&requests, "[]")
+	defer ts.Close()
+
+	currentCmd := NewDeleteCmd(applicationOptions{})
+	rootCmd := getRootCmdForTest()
+	appCmd := NewApplicationCmd(os.Stdout)
+	appCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(appCmd)
+
+	args := []string{"application", "delete", NAME, "--cascade", "--dry-run", "--gate-endpoint=" + ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("Expected a dry run to change nothing, got: %v", requests)
+	}
+}
+
+func TestApplicationDelete_cascadeLiveInfrastructure(t *testing.T) {
+	var requests []string
+	ts := testGateApplicationCascadeSuccess(&requests, applicationServerGroupsJson)
+	defer ts.Close()
+
+	currentCmd := NewDeleteCmd(applicationOptions{})
+	rootCmd := getRootCmdForTest()
+	appCmd := NewApplicationCmd(os.Stdout)
+	appCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(appCmd)
+
+	args := []string{"application", "delete", NAME, "--cascade", "--gate-endpoint=" + ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected delete to be refused while server groups remain")
+	}
+	if len(requests) != 0 {
+		t.Fatalf("Expected nothing to be deleted, got: %v", requests)
+	}
+}
+
 // testGateApplicationDeleteSuccess spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with successful responses to pipeline execute API calls.
 func testGateApplicationDeleteSuccess() *httptest.Server {
@@ -115,3 +191,123 @@ func GateAppDeleteFail() *httptest.Server {
 	}))
 	return httptest.NewServer(mux)
 }
+
+// testGateApplicationCascadeSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves an application with dependent pipelines, strategies, projects and
+// canary configs, and records the changes made to them.
+func testGateApplicationCascadeSuccess(requests *[]string, serverGroups string) *httptest.Server {
+	record := func(r *http.Request, detail string) {
+		request := r.Method + " " + r.URL.Path
+		if detail != "" {
+			request += " " + detail
+		}
+		*requests = append(*requests, request)
+	}
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/"+APP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "{}")
+	}))
+	mux.Handle("/applications/"+APP+"/serverGroups", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(serverGroups))
+	}))
+	mux.Handle("/applications/"+APP+"/loadBalancers", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "[]")
+	}))
+	mux.Handle("/applications/"+APP+"/pipelineConfigs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"name": "deploy", "application": "app"}]`)
+	}))
+	mux.Handle("/applications/"+APP+"/strategyConfigs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"name": "highlander", "application": "app"}]`)
+	}))
+	mux.Handle("/projects", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(applicationProjectsJson))
+	}))
+	mux.Handle("/v2/canaryConfig", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(applicationCanaryConfigsJson))
+	}))
+	mux.Handle("/v2/canaryConfig/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintln(w, `{"id": "shared", "name": "shared", "applications": ["app", "other"]}`)
+		case http.MethodPut:
+			var config map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&config)
+			record(r, fmt.Sprintf("applications=%v", config["applications"]))
+			fmt.Fprintln(w, "{}")
+		default:
+			record(r, "")
+		}
+	}))
+	mux.Handle("/pipelines/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r, "")
+	}))
+	mux.Handle("/strategies/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r, "")
+	}))
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var task map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&task)
+		job := task["job"].([]interface{})[0].(map[string]interface{})
+		detail := job["type"].(string)
+		if project, ok := job["project"].(map[string]interface{}); ok {
+			config := project["config"].(map[string]interface{})
+			detail += fmt.Sprintf(" applications=%v", config["applications"])
+		}
+		record(r, detail)
+		fmt.Fprintln(w, `{"ref": "/tasks/id"}`)
+	}))
+	mux.Handle("/tasks/id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status": "SUCCEEDED"}`)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const applicationServerGroupsJson = `
+[
+  {
+    "name": "app-main-v001",
+    "account": "prod",
+    "region": "us-east-1"
+  }
+]
+`
+
+const applicationProjectsJson = `
+[
+  {
+    "name": "shop",
+    "config": {
+      "applications": ["app", "other"],
+      "clusters": [
+        {"account": "prod", "stack": "main", "applications": ["app"]},
+        {"account": "prod", "stack": "*"}
+      ],
+      "pipelineConfigs": [
+        {"application": "app", "pipelineConfigId": "1"},
+        {"application": "other", "pipelineConfigId": "2"}
+      ]
+    }
+  },
+  {
+    "name": "unrelated",
+    "config": {
+      "applications": ["other"]
+    }
+  }
+]
+`
+
+const applicationCanaryConfigsJson = `
+[
+  {
+    "id": "owned",
+    "name": "owned",
+    "applications": ["app"]
+  },
+  {
+    "id": "shared",
+    "name": "shared",
+    "applications": ["app", "other"]
+  }
+]
+`
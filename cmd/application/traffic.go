@@ -0,0 +1,267 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type TrafficOptions struct {
+	*applicationOptions
+	account string
+	region  string
+}
+
+var (
+	trafficApplicationShort   = "Show which server groups serve the application's load balancers"
+	trafficApplicationLong    = "Show, for each load balancer or Kubernetes Service of the application, the server groups or ReplicaSets behind it, whether they are in service and their share of the healthy instances taking traffic"
+	trafficApplicationExample = "usage: spin application traffic [options] application-name"
+)
+
+// trafficLoadBalancer is a load balancer and the server groups it sends traffic to.
+type trafficLoadBalancer struct {
+	Name          string               `json:"name"`
+	Account       string               `json:"account"`
+	Region        string               `json:"region"`
+	CloudProvider string               `json:"cloudProvider,omitempty"`
+	ServerGroups  []trafficServerGroup `json:"serverGroups"`
+}
+
+type trafficServerGroup struct {
+	Name      string `json:"name"`
+	InService bool   `json:"inService"`
+	Healthy   int    `json:"healthy"`
+	Total     int    `json:"total"`
+	// Share is the percentage of the load balancer's healthy, in service instances in the server group.
+	Share int `json:"share"`
+}
+
+func NewTrafficCmd(appOptions applicationOptions) *cobra.Command {
+	options := TrafficOptions{
+		applicationOptions: &appOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "traffic",
+		Short:   trafficApplicationShort,
+		Long:    trafficApplicationLong,
+		Example: trafficApplicationExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return applicationTraffic(cmd, options, args)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&options.account, "account", "", "(optional) only show load balancers in this account")
+	cmd.PersistentFlags().StringVar(&options.region, "region", "", "(optional) only show load balancers in this region or namespace")
+
+	return cmd
+}
+
+func applicationTraffic(cmd *cobra.Command, options TrafficOptions, args []string) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	applicationName, err := util.ReadArgsOrStdin(args)
+	if err != nil {
+		return err
+	}
+	if applicationName == "" {
+		return errors.New("no application name supplied, exiting")
+	}
+
+	loadBalancers, resp, err := gateClient.LoadBalancerControllerApi.GetApplicationLoadBalancersUsingGET(gateClient.Context, applicationName, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error listing load balancers, status code: %d\n", resp.StatusCode)
+	}
+	serverGroups, resp, err := gateClient.ServerGroupControllerApi.GetServerGroupsForApplicationUsingGET(gateClient.Context, applicationName, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error listing server groups, status code: %d\n", resp.StatusCode)
+	}
+
+	traffic := correlateTraffic(loadBalancers, serverGroups, options.account, options.region)
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(traffic, util.UI.OutputFormat)
+		return nil
+	}
+	if len(traffic) == 0 {
+		util.UI.Info(fmt.Sprintf("No load balancers found for application %s.", applicationName))
+		return nil
+	}
+	util.UI.Output(strings.Join(renderTraffic(traffic), "\n"))
+	return nil
+}
+
+// correlateTraffic matches server groups to the load balancers they are registered with, either as
+// listed by the load balancer or by the server group, in the same account and region.
+func correlateTraffic(loadBalancers []interface{}, serverGroups []interface{}, account string, region string) []trafficLoadBalancer {
+	serverGroupsByKey := map[string]map[string]interface{}{}
+	for _, rawServerGroup := range serverGroups {
+		serverGroup, ok := rawServerGroup.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		serverGroupsByKey[resourceKey(serverGroup)] = serverGroup
+	}
+
+	var traffic []trafficLoadBalancer
+	for _, rawLoadBalancer := range loadBalancers {
+		loadBalancer, ok := rawLoadBalancer.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		lb := trafficLoadBalancer{}
+		lb.Name, _ = loadBalancer["name"].(string)
+		lb.Account, _ = loadBalancer["account"].(string)
+		lb.Region, _ = loadBalancer["region"].(string)
+		lb.CloudProvider, _ = loadBalancer["cloudProvider"].(string)
+		if lb.CloudProvider == "" {
+			lb.CloudProvider, _ = loadBalancer["type"].(string)
+		}
+		if (account != "" && lb.Account != account) || (region != "" && lb.Region != region) {
+			continue
+		}
+
+		members := map[string]trafficServerGroup{}
+		lbServerGroups, _ := loadBalancer["serverGroups"].([]interface{})
+		for _, rawMember := range lbServerGroups {
+			member, ok := rawMember.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := member["name"].(string)
+			memberRegion, _ := member["region"].(string)
+			if memberRegion == "" {
+				memberRegion = lb.Region
+			}
+			details := serverGroupsByKey[lb.Account+"/"+memberRegion+"/"+name]
+			if details == nil {
+				details = member
+			}
+			members[name] = trafficMember(name, details)
+		}
+		for key, serverGroup := range serverGroupsByKey {
+			name, _ := serverGroup["name"].(string)
+			if _, exists := members[name]; exists || !strings.HasPrefix(key, lb.Account+"/"+lb.Region+"/") {
+				continue
+			}
+			for _, registered := range stringValues(serverGroup["loadBalancers"]) {
+				if registered == lb.Name {
+					members[name] = trafficMember(name, serverGroup)
+				}
+			}
+		}
+
+		healthy := 0
+		for _, member := range members {
+			if member.InService {
+				healthy += member.Healthy
+			}
+		}
+		for _, member := range members {
+			if member.InService && healthy > 0 {
+				member.Share = member.Healthy * 100 / healthy
+			}
+			lb.ServerGroups = append(lb.ServerGroups, member)
+		}
+		sort.Slice(lb.ServerGroups, func(i, j int) bool {
+			return lb.ServerGroups[i].Name < lb.ServerGroups[j].Name
+		})
+		traffic = append(traffic, lb)
+	}
+	sort.Slice(traffic, func(i, j int) bool {
+		return traffic[i].key() < traffic[j].key()
+	})
+	return traffic
+}
+
+func (lb trafficLoadBalancer) key() string {
+	return lb.Account + "/" + lb.Region + "/" + lb.Name
+}
+
+// trafficMember reads whether the server group is in service and how many of its instances are
+// healthy, from its instance counts when available and otherwise from its instances.
+func trafficMember(name string, serverGroup map[string]interface{}) trafficServerGroup {
+	disabled, _ := serverGroup["isDisabled"].(bool)
+	member := trafficServerGroup{Name: name, InService: !disabled}
+	if counts, ok := serverGroup["instanceCounts"].(map[string]interface{}); ok {
+		up, _ := counts["up"].(float64)
+		total, _ := counts["total"].(float64)
+		member.Healthy, member.Total = int(up), int(total)
+		return member
+	}
+	instances, _ := serverGroup["instances"].([]interface{})
+	member.Total = len(instances)
+	for _, rawInstance := range instances {
+		if instance, ok := rawInstance.(map[string]interface{}); ok && instanceHealthy(instance) {
+			member.Healthy++
+		}
+	}
+	return member
+}
+
+func instanceHealthy(instance map[string]interface{}) bool {
+	if state, _ := instance["healthState"].(string); state != "" {
+		return state == "Up"
+	}
+	health, _ := instance["health"].(map[string]interface{})
+	state, _ := health["state"].(string)
+	return state == "Up" || state == "InService"
+}
+
+func resourceKey(resource map[string]interface{}) string {
+	account, _ := resource["account"].(string)
+	region, _ := resource["region"].(string)
+	name, _ := resource["name"].(string)
+	return account + "/" + region + "/" + name
+}
+
+func renderTraffic(traffic []trafficLoadBalancer) []string {
+	var lines []string
+	for _, lb := range traffic {
+		header := fmt.Sprintf("%s/%s %s", lb.Account, lb.Region, lb.Name)
+		if lb.CloudProvider != "" {
+			header += fmt.Sprintf(" (%s)", lb.CloudProvider)
+		}
+		lines = append(lines, util.Colorize().Color("[bold]"+header))
+		if len(lb.ServerGroups) == 0 {
+			lines = append(lines, "  no server groups")
+			continue
+		}
+		for _, sg := range lb.ServerGroups {
+			state := util.Colorize().Color("[green]IN SERVICE    [reset]")
+			if !sg.InService {
+				state = util.Colorize().Color("[yellow]OUT OF SERVICE[reset]")
+			}
+			lines = append(lines, fmt.Sprintf("  %-30s %s %d/%d healthy %3d%%", sg.Name, state, sg.Healthy, sg.Total, sg.Share))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spinnaker/spin/util"
+)
+
+func TestApplicationTraffic_basic(t *testing.T) {
+	ts := testGateApplicationTrafficSuccess()
+	defer ts.Close()
+
+	currentCmd := NewTrafficCmd(applicationOptions{})
+	rootCmd := getRootCmdForTest()
+	appCmd := NewApplicationCmd(os.Stdout)
+	appCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(appCmd)
+
+	args := []string{"application", "traffic", APP, "--gate-endpoint=" + ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestApplicationTraffic_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewTrafficCmd(applicationOptions{})
+	rootCmd := getRootCmdForTest()
+	appCmd := NewApplicationCmd(os.Stdout)
+	appCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(appCmd)
+
+	args := []string{"application", "traffic", APP, "--gate-endpoint=" + ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestApplicationTraffic_correlate(t *testing.T) {
+	var loadBalancers, serverGroups []interface{}
+	json.Unmarshal([]byte(trafficLoadBalancersJson), &loadBalancers)
+	json.Unmarshal([]byte(trafficServerGroupsJson), &serverGroups)
+
+	traffic := correlateTraffic(loadBalancers, serverGroups, "", "")
+	var actual []string
+	for _, lb := range traffic {
+		for _, sg := range lb.ServerGroups {
+			actual = append(actual, fmt.Sprintf("%s %s inService=%t %d/%d %d%%", lb.key(), sg.Name, sg.InService, sg.Healthy, sg.Total, sg.Share))
+		}
+	}
+	expected := []string{
+		"prod/default/service app-svc app-replicaset-abc inService=true 2/2 100%",
+		"prod/us-east-1/app-main-frontend app-main-v001 inService=true 1/2 25%",
+		"prod/us-east-1/app-main-frontend app-main-v002 inService=true 3/3 75%",
+		"prod/us-east-1/app-main-frontend app-main-v003 inService=false 0/1 0%",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected traffic:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	filtered := correlateTraffic(loadBalancers, serverGroups, "", "us-east-1")
+	if len(filtered) != 1 || filtered[0].Name != "app-main-frontend" {
+		t.Fatalf("Expected only the us-east-1 load balancer, got: %v", filtered)
+	}
+}
+
+// testGateApplicationTrafficSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves the application's load balancers and server groups.
+func testGateApplicationTrafficSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/"+APP+"/loadBalancers", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(trafficLoadBalancersJson))
+	}))
+	mux.Handle("/applications/"+APP+"/serverGroups", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(trafficServerGroupsJson))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const trafficLoadBalancersJson = `
+[
+  {
+    "name": "app-main-frontend",
+    "account": "prod",
+    "region": "us-east-1",
+    "cloudProvider": "aws",
+    "serverGroups": [
+      {"name": "app-main-v001", "region": "us-east-1", "isDisabled": false},
+      {"name": "app-main-v002", "region": "us-east-1", "isDisabled": false}
+    ]
+  },
+  {
+    "name": "service app-svc",
+    "account": "prod",
+    "region": "default",
+    "cloudProvider": "kubernetes",
+    "serverGroups": [
+      {
+        "name": "app-replicaset-abc",
+        "isDisabled": false,
+        "instances": [
+          {"name": "pod-1", "healthState": "Up"},
+          {"name": "pod-2", "healthState": "Up"}
+        ]
+      }
+    ]
+  }
+]
+`
+
+const trafficServerGroupsJson = `
+[
+  {
+    "name": "app-main-v001",
+    "account": "prod",
+    "region": "us-east-1",
+    "isDisabled": false,
+    "loadBalancers": ["app-main-frontend"],
+    "instanceCounts": {"total": 2, "up": 1}
+  },
+  {
+    "name": "app-main-v002",
+    "account": "prod",
+    "region": "us-east-1",
+    "isDisabled": false,
+    "loadBalancers": ["app-main-frontend"],
+    "instanceCounts": {"total": 3, "up": 3}
+  },
+  {
+    "name": "app-main-v003",
+    "account": "prod",
+    "region": "us-east-1",
+    "isDisabled": true,
+    "loadBalancers": ["app-main-frontend"],
+    "instanceCounts": {"total": 1, "up": 0}
+  },
+  {
+    "name": "app-main-v001",
+    "account": "prod",
+    "region": "eu-west-1",
+    "isDisabled": false,
+    "loadBalancers": ["app-main-frontend"],
+    "instanceCounts": {"total": 2, "up": 2}
+  }
+]
+`
@@ -0,0 +1,165 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package canary_config
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/version"
+)
+
+// canaryConfigApi holds the canary config operations of Gate's V2CanaryConfigControllerApi,
+// which Kayenta serves natively under /canaryConfig.
+type canaryConfigApi interface {
+	GetCanaryConfigsUsingGET(ctx context.Context, localVarOptionals map[string]interface{}) ([]interface{}, *http.Response, error)
+	GetCanaryConfigUsingGET(ctx context.Context, id string, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error)
+	CreateCanaryConfigUsingPOST(ctx context.Context, config interface{}, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error)
+	UpdateCanaryConfigUsingPUT(ctx context.Context, id string, config interface{}, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error)
+	DeleteCanaryConfigUsingDELETE(ctx context.Context, id string, localVarOptionals map[string]interface{}) (*http.Response, error)
+}
+
+type canaryConfigClient struct {
+	Context context.Context
+	Api     canaryConfigApi
+}
+
+// newCanaryConfigClient returns a client for the standalone Kayenta configured as canary.endpoint,
+// or for Gate otherwise.
+func newCanaryConfigClient(flags *pflag.FlagSet) (*canaryConfigClient, error) {
+	cfg, err := gateclient.LoadConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Canary.Endpoint == "" {
+		gateClient, err := gateclient.NewGateClient(flags)
+		if err != nil {
+			return nil, err
+		}
+		return &canaryConfigClient{
+			Context: gateClient.Context,
+			Api:     gateClient.V2CanaryConfigControllerApi,
+		}, nil
+	}
+
+	ignoreCertErrors, err := flags.GetBool("insecure")
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{}
+	if ignoreCertErrors {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return &canaryConfigClient{
+		Context: context.Background(),
+		Api: &kayentaCanaryConfigApi{
+			endpoint: strings.TrimSuffix(cfg.Canary.Endpoint, "/"),
+			client:   client,
+		},
+	}, nil
+}
+
+// kayentaCanaryConfigApi calls the canary config endpoints of a standalone Kayenta.
+type kayentaCanaryConfigApi struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (k *kayentaCanaryConfigApi) GetCanaryConfigsUsingGET(ctx context.Context, localVarOptionals map[string]interface{}) ([]interface{}, *http.Response, error) {
+	var configs []interface{}
+	resp, err := k.call(ctx, http.MethodGet, "/canaryConfig", localVarOptionals, nil, &configs)
+	return configs, resp, err
+}
+
+func (k *kayentaCanaryConfigApi) GetCanaryConfigUsingGET(ctx context.Context, id string, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error) {
+	var config interface{}
+	resp, err := k.call(ctx, http.MethodGet, "/canaryConfig/"+url.PathEscape(id), localVarOptionals, nil, &config)
+	return config, resp, err
+}
+
+func (k *kayentaCanaryConfigApi) CreateCanaryConfigUsingPOST(ctx context.Context, config interface{}, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error) {
+	var created interface{}
+	resp, err := k.call(ctx, http.MethodPost, "/canaryConfig", localVarOptionals, config, &created)
+	return created, resp, err
+}
+
+func (k *kayentaCanaryConfigApi) UpdateCanaryConfigUsingPUT(ctx context.Context, id string, config interface{}, localVarOptionals map[string]interface{}) (interface{}, *http.Response, error) {
+	var updated interface{}
+	resp, err := k.call(ctx, http.MethodPut, "/canaryConfig/"+url.PathEscape(id), localVarOptionals, config, &updated)
+	return updated, resp, err
+}
+
+func (k *kayentaCanaryConfigApi) DeleteCanaryConfigUsingDELETE(ctx context.Context, id string, localVarOptionals map[string]interface{}) (*http.Response, error) {
+	return k.call(ctx, http.MethodDelete, "/canaryConfig/"+url.PathEscape(id), localVarOptionals, nil, nil)
+}
+
+// call performs the request and decodes the response into result. Like the generated Gate client,
+// it returns the response along with an error for non-2xx status codes.
+func (k *kayentaCanaryConfigApi) call(ctx context.Context, method string, path string, query map[string]interface{}, body interface{}, result interface{}) (*http.Response, error) {
+	values := url.Values{}
+	for key, value := range query {
+		if s := fmt.Sprintf("%v", value); s != "" {
+			values.Add(key, s)
+		}
+	}
+	requestUrl := k.endpoint + path
+	if len(values) > 0 {
+		requestUrl += "?" + values.Encode()
+	}
+
+	var requestBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&requestBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, requestUrl, &requestBody)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", version.UserAgent, version.String()))
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("Status: %v, Body: %s", resp.Status, responseBody)
+	}
+	if result != nil && len(bytes.TrimSpace(responseBody)) > 0 {
+		if err = json.Unmarshal(responseBody, result); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
@@ -17,7 +17,6 @@ package canary_config
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"github.com/spinnaker/spin/cmd/gateclient"
 	"github.com/spinnaker/spin/util"
 	"net/http"
 )
@@ -46,7 +45,7 @@ func NewDeleteCmd() *cobra.Command {
 }
 
 func deleteCanaryConfig(cmd *cobra.Command, args []string) error {
-	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	canaryClient, err := newCanaryConfigClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
@@ -56,8 +55,8 @@ func deleteCanaryConfig(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	resp, err := gateClient.V2CanaryConfigControllerApi.DeleteCanaryConfigUsingDELETE(
-		gateClient.Context, id, map[string]interface{}{})
+	resp, err := canaryClient.Api.DeleteCanaryConfigUsingDELETE(
+		canaryClient.Context, id, map[string]interface{}{})
 
 	if err != nil {
 		return err
@@ -18,7 +18,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
-	"github.com/spinnaker/spin/cmd/gateclient"
 	"github.com/spinnaker/spin/util"
 	"net/http"
 )
@@ -52,7 +51,7 @@ func NewGetCmd(canaryConfigOptions canaryConfigOptions) *cobra.Command {
 }
 
 func getCanaryConfig(cmd *cobra.Command, options GetOptions, args []string) error {
-	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	canaryClient, err := newCanaryConfigClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
@@ -68,8 +67,8 @@ func getCanaryConfig(cmd *cobra.Command, options GetOptions, args []string) erro
 		}
 	}
 
-	successPayload, resp, err := gateClient.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(
-		gateClient.Context, id, map[string]interface{}{})
+	successPayload, resp, err := canaryClient.Api.GetCanaryConfigUsingGET(
+		canaryClient.Context, id, map[string]interface{}{})
 
 	if err != nil {
 		return err
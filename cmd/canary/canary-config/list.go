@@ -17,7 +17,6 @@ package canary_config
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"github.com/spinnaker/spin/cmd/gateclient"
 	"github.com/spinnaker/spin/util"
 	"net/http"
 )
@@ -53,13 +52,13 @@ func NewListCmd(canaryConfigOptions canaryConfigOptions) *cobra.Command {
 }
 
 func listCanaryConfig(cmd *cobra.Command, options ListOptions) error {
-	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	canaryClient, err := newCanaryConfigClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
 
-	successPayload, resp, err := gateClient.V2CanaryConfigControllerApi.GetCanaryConfigsUsingGET(
-		gateClient.Context, map[string]interface{}{"application": options.application})
+	successPayload, resp, err := canaryClient.Api.GetCanaryConfigsUsingGET(
+		canaryClient.Context, map[string]interface{}{"application": options.application})
 
 	if err != nil {
 		return err
@@ -95,6 +95,35 @@ func TestCanaryConfigList_fail(t *testing.T) {
 	}
 }
 
+func TestCanaryConfigList_kayenta(t *testing.T) {
+	var application string
+	ts := testKayentaCanaryConfigListSuccess(&application)
+	defer ts.Close()
+
+	configFile := tempCanaryConfigFile(kayentaConfig(ts.URL))
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	currentCmd := NewListCmd(canaryConfigOptions{})
+	rootCmd := getRootCmdForTest()
+	canaryConfigCmd := NewCanaryConfigCmd(os.Stdout)
+	canaryConfigCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(canaryConfigCmd)
+
+	// No Gate is running, the configured Kayenta is called directly.
+	args := []string{"canary-config", "list", "-a", "app", "--config", configFile.Name()}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if application != "app" {
+		t.Fatalf("Expected configs of application app to be listed, got %q", application)
+	}
+}
+
 // testGateCanaryConfigListSuccess spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with a 200 and a well-formed canaryConfig list.
 func testGateCanaryConfigListSuccess() *httptest.Server {
@@ -118,6 +147,23 @@ func testGateCanaryConfigListMalformed() *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
+// testKayentaCanaryConfigListSuccess spins up a local http server standing in for a standalone
+// Kayenta. Responds with a well-formed canaryConfig list and records the application queried.
+func testKayentaCanaryConfigListSuccess(application *string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle(
+		"/canaryConfig",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*application = r.URL.Query().Get("application")
+			fmt.Fprintln(w, strings.TrimSpace(canaryConfigListJson))
+		}))
+	return httptest.NewServer(mux)
+}
+
+func kayentaConfig(endpoint string) string {
+	return fmt.Sprintf("canary:\n  endpoint: %s\n", endpoint)
+}
+
 // GateServerFail spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with a 500 InternalServerError.
 func GateServerFail() *httptest.Server {
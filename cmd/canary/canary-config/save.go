@@ -17,7 +17,6 @@ package canary_config
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	"github.com/spinnaker/spin/cmd/gateclient"
 	"github.com/spinnaker/spin/util"
 	"net/http"
 )
@@ -54,7 +53,7 @@ func NewSaveCmd(canaryConfigOptions canaryConfigOptions) *cobra.Command {
 }
 
 func saveCanaryConfig(cmd *cobra.Command, options SaveOptions) error {
-	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	canaryClient, err := newCanaryConfigClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
@@ -71,17 +70,17 @@ func saveCanaryConfig(cmd *cobra.Command, options SaveOptions) error {
 
 	templateId := templateJson["id"].(string)
 
-	_, resp, queryErr := gateClient.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(
-		gateClient.Context, templateId, map[string]interface{}{})
+	_, resp, queryErr := canaryClient.Api.GetCanaryConfigUsingGET(
+		canaryClient.Context, templateId, map[string]interface{}{})
 
 	var saveResp *http.Response
 	var saveErr error
 	if resp.StatusCode == http.StatusOK {
-		_, saveResp, saveErr = gateClient.V2CanaryConfigControllerApi.UpdateCanaryConfigUsingPUT(
-			gateClient.Context, templateId, templateJson, map[string]interface{}{})
+		_, saveResp, saveErr = canaryClient.Api.UpdateCanaryConfigUsingPUT(
+			canaryClient.Context, templateId, templateJson, map[string]interface{}{})
 	} else if resp.StatusCode == http.StatusNotFound {
-		_, saveResp, saveErr = gateClient.V2CanaryConfigControllerApi.CreateCanaryConfigUsingPOST(
-			gateClient.Context, templateJson, map[string]interface{}{})
+		_, saveResp, saveErr = canaryClient.Api.CreateCanaryConfigUsingPOST(
+			canaryClient.Context, templateJson, map[string]interface{}{})
 	} else {
 		if queryErr != nil {
 			return queryErr
@@ -15,6 +15,7 @@
 package canary_config
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/spinnaker/spin/util"
 	"io/ioutil"
@@ -175,6 +176,40 @@ func TestCanaryConfigSave_missingid(t *testing.T) {
 	}
 }
 
+func TestCanaryConfigSave_kayenta(t *testing.T) {
+	var created map[string]interface{}
+	ts := kayentaServerCreateSuccess(&created)
+	defer ts.Close()
+
+	configFile := tempCanaryConfigFile(kayentaConfig(ts.URL))
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	tempFile := tempCanaryConfigFile(testCanaryConfigJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp canary config file.")
+	}
+	defer os.Remove(tempFile.Name())
+	args := []string{"canary-config", "save", "--file", tempFile.Name(), "--config", configFile.Name()}
+
+	currentCmd := NewSaveCmd(canaryConfigOptions{})
+	rootCmd := getRootCmdForTest()
+	canaryConfigCmd := NewCanaryConfigCmd(os.Stdout)
+	canaryConfigCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(canaryConfigCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if created["id"] != "exampleCanaryConfigId" {
+		t.Fatalf("Expected the canary config to be created in Kayenta, got: %v", created)
+	}
+}
+
 func tempCanaryConfigFile(canaryConfigContent string) *os.File {
 	tempFile, _ := ioutil.TempFile("" /* /tmp dir. */, "cc-spec")
 	bytes, err := tempFile.Write([]byte(canaryConfigContent))
@@ -216,6 +251,20 @@ func gateServerCreateSuccess() *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
+// kayentaServerCreateSuccess spins up a local http server standing in for a standalone Kayenta.
+// Responds with 404 NotFound to indicate a canary config doesn't exist, and records the created config.
+func kayentaServerCreateSuccess(created *map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/canaryConfig", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(created)
+		w.Write([]byte(responseJson))
+	}))
+	mux.Handle("/canaryConfig/exampleCanaryConfigId", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return httptest.NewServer(mux)
+}
+
 const responseJson = `
 {
   "id": "exampleCanaryConfigId"
@@ -0,0 +1,325 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
+	"github.com/spinnaker/spin/util"
+)
+
+type CleanupOptions struct {
+	*clusterOptions
+	application    string
+	account        string
+	cluster        string
+	keep           int
+	includeEnabled bool
+	dryRun         bool
+	yes            bool
+}
+
+var (
+	cleanupClusterShort   = "Destroy old server groups of the provided cluster"
+	cleanupClusterLong    = "Destroy all but the newest server groups of the provided cluster in each region. Only disabled server groups are destroyed unless --include-enabled is set, and even then server groups that may still receive traffic or are protected by a traffic guard are never destroyed"
+	cleanupClusterExample = "usage: spin cluster cleanup -a app --account prod --cluster app-main --keep 2 [--include-enabled] [--dry-run]"
+)
+
+// destroyTaskAttempts bounds how long to wait for the destroy task, destroying server groups
+// takes longer than most other tasks.
+const destroyTaskAttempts = 10
+
+// serverGroupAction is what cleanup decided to do with a server group, and why.
+type serverGroupAction struct {
+	serverGroup map[string]interface{}
+	destroy     bool
+	reason      string
+}
+
+func NewCleanupCmd(clusterOptions clusterOptions) *cobra.Command {
+	options := CleanupOptions{
+		clusterOptions: &clusterOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "cleanup",
+		Short:   cleanupClusterShort,
+		Long:    cleanupClusterLong,
+		Example: cleanupClusterExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cleanupCluster(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.application, "application", "a", "", "Spinnaker application the cluster belongs to")
+	cmd.PersistentFlags().StringVar(&options.account, "account", "", "account the cluster is deployed to")
+	cmd.PersistentFlags().StringVar(&options.cluster, "cluster", "", "name of the cluster to clean up")
+	cmd.PersistentFlags().IntVar(&options.keep, "keep", 2, "number of newest server groups to keep in each region")
+	cmd.PersistentFlags().BoolVar(&options.includeEnabled, "include-enabled", false, "also destroy enabled server groups without instances in service")
+	cmd.PersistentFlags().BoolVar(&options.dryRun, "dry-run", false, "only print the server groups that would be destroyed")
+	cmd.PersistentFlags().BoolVarP(&options.yes, "yes", "y", false, "destroy without asking for confirmation")
+
+	return cmd
+}
+
+func cleanupCluster(cmd *cobra.Command, options CleanupOptions) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	if options.application == "" || options.account == "" || options.cluster == "" {
+		return errors.New("one of required parameters 'application', 'account' or 'cluster' not set")
+	}
+	if options.keep < 0 {
+		return errors.New("parameter 'keep' must not be negative")
+	}
+
+	serverGroups, resp, err := gateClient.ClusterControllerApi.GetServerGroupsUsingGET1(gateClient.Context,
+		options.application, options.account, options.cluster, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error listing server groups of cluster %s, status code: %d\n",
+			options.cluster,
+			resp.StatusCode)
+	}
+
+	app, resp, err := gateClient.ApplicationControllerApi.GetApplicationUsingGET(gateClient.Context, options.application, map[string]interface{}{"expand": false})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error getting application %s, status code: %d\n",
+			options.application,
+			resp.StatusCode)
+	}
+	attributes, _ := app["attributes"].(map[string]interface{})
+	guards, _ := attributes["trafficGuards"].([]interface{})
+
+	actions := planCleanup(serverGroups, guards, options)
+
+	var destroy []map[string]interface{}
+	for _, action := range actions {
+		verb := "keep"
+		if action.destroy {
+			verb = "destroy"
+			destroy = append(destroy, action.serverGroup)
+		}
+		util.UI.Output(fmt.Sprintf("%-8s %-40s %-16s %s", verb, serverGroupName(action.serverGroup),
+			serverGroupRegion(action.serverGroup), action.reason))
+	}
+
+	if len(destroy) == 0 {
+		util.UI.Info("No server groups to destroy.")
+		return nil
+	}
+	if options.dryRun {
+		util.UI.Info(fmt.Sprintf("Dry run, %d server group(s) would be destroyed.", len(destroy)))
+		return nil
+	}
+	if !options.yes && !util.UI.Confirm(fmt.Sprintf("Destroy %d server group(s) of cluster %s?", len(destroy), options.cluster)) {
+		return errors.New("cluster cleanup aborted")
+	}
+
+	var jobs []interface{}
+	for _, serverGroup := range destroy {
+		jobs = append(jobs, destroyServerGroupJob(serverGroup, options.account))
+	}
+	destroyTask := map[string]interface{}{
+		"job":         jobs,
+		"application": options.application,
+		"description": fmt.Sprintf("Clean up cluster: %s", options.cluster),
+	}
+
+	taskRef, resp, err := gateClient.TaskControllerApi.TaskUsingPOST1(gateClient.Context, destroyTask)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error destroying server groups, status code: %d\n", resp.StatusCode)
+	}
+
+	err = orca_tasks.WaitForSuccessfulTask(gateClient, taskRef, destroyTaskAttempts)
+	if err != nil {
+		return err
+	}
+
+	util.UI.Info(util.Colorize().Color(fmt.Sprintf("[reset][bold][green]Destroyed %d server group(s)", len(destroy))))
+	return nil
+}
+
+// planCleanup decides for every server group whether it is destroyed. The newest server groups of
+// each region are kept, as are enabled server groups unless includeEnabled is set, and server
+// groups that may still receive traffic or are guarded.
+func planCleanup(serverGroups []interface{}, guards []interface{}, options CleanupOptions) []serverGroupAction {
+	byRegion := map[string][]map[string]interface{}{}
+	var regions []string
+	for _, raw := range serverGroups {
+		serverGroup, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		region := serverGroupRegion(serverGroup)
+		if _, exists := byRegion[region]; !exists {
+			regions = append(regions, region)
+		}
+		byRegion[region] = append(byRegion[region], serverGroup)
+	}
+	sort.Strings(regions)
+
+	var actions []serverGroupAction
+	for _, region := range regions {
+		regionGroups := byRegion[region]
+		sort.SliceStable(regionGroups, func(i, j int) bool {
+			return createdTime(regionGroups[i]) > createdTime(regionGroups[j])
+		})
+
+		for i, serverGroup := range regionGroups {
+			action := serverGroupAction{serverGroup: serverGroup}
+			switch {
+			case i < options.keep:
+				action.reason = fmt.Sprintf("one of the %d newest", options.keep)
+			case isGuarded(serverGroup, guards, options.account):
+				action.reason = "protected by a traffic guard"
+			case !isDisabled(serverGroup) && !options.includeEnabled:
+				action.reason = "enabled, pass --include-enabled to destroy"
+			case !isDisabled(serverGroup) && servingInstances(serverGroup) > 0:
+				action.reason = fmt.Sprintf("may be receiving traffic on %d instance(s)", servingInstances(serverGroup))
+			default:
+				action.destroy = true
+				if isDisabled(serverGroup) {
+					action.reason = "disabled"
+				} else {
+					action.reason = "no instances in service"
+				}
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// isGuarded returns true for enabled server groups of a cluster protected by a traffic guard,
+// which Orca would refuse to destroy anyway.
+func isGuarded(serverGroup map[string]interface{}, guards []interface{}, account string) bool {
+	if isDisabled(serverGroup) {
+		return false
+	}
+	moniker, _ := serverGroup["moniker"].(map[string]interface{})
+	for _, raw := range guards {
+		guard, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if enabled, exists := guard["enabled"].(bool); exists && !enabled {
+			continue
+		}
+		if guardMatches(guard["account"], account) &&
+			guardMatches(guard["location"], serverGroupRegion(serverGroup)) &&
+			guardMatches(guard["stack"], moniker["stack"]) &&
+			guardMatches(guard["detail"], moniker["detail"]) {
+			return true
+		}
+	}
+	return false
+}
+
+func guardMatches(guardValue interface{}, value interface{}) bool {
+	g, _ := guardValue.(string)
+	v, _ := value.(string)
+	return g == "*" || g == v
+}
+
+func destroyServerGroupJob(serverGroup map[string]interface{}, account string) map[string]interface{} {
+	region := serverGroupRegion(serverGroup)
+	job := map[string]interface{}{
+		"type":            "destroyServerGroup",
+		"serverGroupName": serverGroupName(serverGroup),
+		"asgName":         serverGroupName(serverGroup),
+		"region":          region,
+		"regions":         []string{region},
+		"credentials":     account,
+		"cloudProvider":   serverGroup["cloudProvider"],
+	}
+	if moniker, exists := serverGroup["moniker"]; exists {
+		job["moniker"] = moniker
+	}
+	if zones, exists := serverGroup["zones"]; exists {
+		job["zones"] = zones
+	}
+	return job
+}
+
+func serverGroupName(serverGroup map[string]interface{}) string {
+	name, _ := serverGroup["name"].(string)
+	return name
+}
+
+// serverGroupRegion returns the region, or namespace for Kubernetes, of a server group.
+func serverGroupRegion(serverGroup map[string]interface{}) string {
+	if region, ok := serverGroup["region"].(string); ok {
+		return region
+	}
+	namespace, _ := serverGroup["namespace"].(string)
+	return namespace
+}
+
+func createdTime(serverGroup map[string]interface{}) float64 {
+	created, _ := serverGroup["createdTime"].(float64)
+	return created
+}
+
+func isDisabled(serverGroup map[string]interface{}) bool {
+	disabled, _ := serverGroup["isDisabled"].(bool)
+	if !disabled {
+		disabled, _ = serverGroup["disabled"].(bool)
+	}
+	return disabled
+}
+
+// servingInstances returns the number of instances of the server group that may be in service.
+// Only instances known to be Down or OutOfService are left out, instances without health checks
+// or load balancers report Unknown health but still serve traffic.
+func servingInstances(serverGroup map[string]interface{}) int {
+	if counts, ok := serverGroup["instanceCounts"].(map[string]interface{}); ok {
+		if total, ok := counts["total"].(float64); ok {
+			down, _ := counts["down"].(float64)
+			outOfService, _ := counts["outOfService"].(float64)
+			return int(total - down - outOfService)
+		}
+		serving := 0
+		for _, state := range []string{"up", "unknown", "starting"} {
+			count, _ := counts[state].(float64)
+			serving += int(count)
+		}
+		return serving
+	}
+	serving := 0
+	instances, _ := serverGroup["instances"].([]interface{})
+	for _, raw := range instances {
+		instance, ok := raw.(map[string]interface{})
+		if ok && instance["healthState"] != "Down" && instance["healthState"] != "OutOfService" {
+			serving++
+		}
+	}
+	return serving
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestClusterCleanup_basic(t *testing.T) {
+	var destroyed []string
+	ts := testGateClusterCleanupSuccess(&destroyed)
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--account", "prod", "--cluster", "app-main", "--keep", "1", "--yes", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	if strings.Join(destroyed, ",") != "app-main-v001" {
+		t.Fatalf("Expected only the disabled app-main-v001 to be destroyed, got %v", destroyed)
+	}
+}
+
+func TestClusterCleanup_includeEnabled(t *testing.T) {
+	var destroyed []string
+	ts := testGateClusterCleanupSuccess(&destroyed)
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--account", "prod", "--cluster", "app-main", "--keep", "1", "--include-enabled", "--yes", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	// app-main-v003 has instances of Unknown health, which may still be serving traffic.
+	sort.Strings(destroyed)
+	expected := []string{"app-main-v001", "app-main-v002"}
+	if strings.Join(destroyed, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v to be destroyed, got %v", expected, destroyed)
+	}
+}
+
+func TestClusterCleanup_servingInstances(t *testing.T) {
+	tests := []struct {
+		serverGroup string
+		expected    int
+	}{
+		{`{"instanceCounts": {"total": 4, "up": 1, "unknown": 1, "down": 1, "outOfService": 1}}`, 2},
+		{`{"instanceCounts": {"up": 0, "unknown": 2}}`, 2},
+		{`{"instanceCounts": {"up": 0}}`, 0},
+		{`{"instances": [{"healthState": "Unknown"}, {"healthState": "Down"}, {"healthState": "OutOfService"}, {"healthState": "Up"}]}`, 2},
+	}
+	for _, test := range tests {
+		var serverGroup map[string]interface{}
+		json.Unmarshal([]byte(test.serverGroup), &serverGroup)
+		if serving := servingInstances(serverGroup); serving != test.expected {
+			t.Errorf("Expected %d serving instances for %s, got %d", test.expected, test.serverGroup, serving)
+		}
+	}
+}
+
+func TestClusterCleanup_applicationFail(t *testing.T) {
+	var destroyed []string
+	ts := testGateClusterCleanupApplicationFail(&destroyed)
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--account", "prod", "--cluster", "app-main", "--keep", "1", "--yes", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure when the application's traffic guards can't be read")
+	}
+	if len(destroyed) != 0 {
+		t.Fatalf("Expected nothing to be destroyed, got %v", destroyed)
+	}
+}
+
+func TestClusterCleanup_dryRun(t *testing.T) {
+	var destroyed []string
+	ts := testGateClusterCleanupSuccess(&destroyed)
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--account", "prod", "--cluster", "app-main", "--keep", "1", "--dry-run", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(destroyed) != 0 {
+		t.Fatalf("Expected nothing to be destroyed on a dry run, got %v", destroyed)
+	}
+}
+
+func TestClusterCleanup_flags(t *testing.T) {
+	var destroyed []string
+	ts := testGateClusterCleanupSuccess(&destroyed)
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--gate-endpoint", ts.URL} // Missing account and cluster.
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestClusterCleanup_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewCleanupCmd(clusterOptions{})
+	rootCmd := getRootCmdForTest()
+	clusterCmd := NewClusterCmd(os.Stdout)
+	clusterCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(clusterCmd)
+
+	args := []string{"cluster", "cleanup", "-a", "app", "--account", "prod", "--cluster", "app-main", "--yes", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+// testGateClusterCleanupSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with the cluster's server groups and records destroyed server groups.
+func testGateClusterCleanupSuccess(destroyed *[]string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/clusters/prod/app-main/serverGroups", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(clusterServerGroupsJson))
+	}))
+	mux.Handle("/applications/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(guardedAppJson))
+	}))
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var task struct {
+			Job []map[string]interface{} `json:"job"`
+		}
+		json.NewDecoder(r.Body).Decode(&task)
+		for _, job := range task.Job {
+			*destroyed = append(*destroyed, job["serverGroupName"].(string))
+		}
+		fmt.Fprintln(w, `{"ref": "/tasks/id"}`)
+	}))
+	mux.Handle("/tasks/id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status": "SUCCEEDED"}`)
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testGateClusterCleanupApplicationFail spins up a local http server that we will configure the
+// GateClient to direct requests to. Lists the cluster's server groups but answers for the application
+// without its attributes and a status other than 200 OK.
+func testGateClusterCleanupApplicationFail(destroyed *[]string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/clusters/prod/app-main/serverGroups", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(clusterServerGroupsJson))
+	}))
+	mux.Handle("/applications/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, `{"name": "app"}`)
+	}))
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*destroyed = append(*destroyed, "task")
+		fmt.Fprintln(w, `{"ref": "/tasks/id"}`)
+	}))
+	mux.Handle("/tasks/id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status": "SUCCEEDED"}`)
+	}))
+	return httptest.NewServer(mux)
+}
+
+// GateServerFail spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with a 500 InternalServerError.
+func GateServerFail() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const guardedAppJson = `
+{
+  "name": "app",
+  "attributes": {
+    "name": "app",
+    "trafficGuards": [
+      {
+        "account": "prod",
+        "location": "us-west-2",
+        "stack": "main",
+        "detail": "*",
+        "enabled": true
+      }
+    ]
+  }
+}
+`
+
+const clusterServerGroupsJson = `
+[
+  {
+    "name": "app-main-v001",
+    "region": "us-east-1",
+    "cloudProvider": "aws",
+    "createdTime": 1000,
+    "isDisabled": true,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"up": 0}
+  },
+  {
+    "name": "app-main-v002",
+    "region": "us-east-1",
+    "cloudProvider": "aws",
+    "createdTime": 2000,
+    "isDisabled": false,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"up": 0}
+  },
+  {
+    "name": "app-main-v003",
+    "region": "us-east-1",
+    "cloudProvider": "aws",
+    "createdTime": 3000,
+    "isDisabled": false,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"total": 2, "up": 0, "unknown": 2}
+  },
+  {
+    "name": "app-main-v004",
+    "region": "us-east-1",
+    "cloudProvider": "aws",
+    "createdTime": 4000,
+    "isDisabled": false,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"up": 3}
+  },
+  {
+    "name": "app-main-v005",
+    "region": "us-west-2",
+    "cloudProvider": "aws",
+    "createdTime": 1000,
+    "isDisabled": false,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"up": 0}
+  },
+  {
+    "name": "app-main-v006",
+    "region": "us-west-2",
+    "cloudProvider": "aws",
+    "createdTime": 2000,
+    "isDisabled": false,
+    "moniker": {"app": "app", "stack": "main", "cluster": "app-main"},
+    "instanceCounts": {"up": 3}
+  }
+]
+`
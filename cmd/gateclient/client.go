@@ -21,8 +21,10 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
@@ -35,6 +37,7 @@ import (
   "syscall"
 
 	"github.com/spinnaker/spin/config"
+	authconfig "github.com/spinnaker/spin/config/auth"
 	iap "github.com/spinnaker/spin/config/auth/iap"
 	"github.com/spinnaker/spin/util"
 	"github.com/spinnaker/spin/version"
@@ -84,6 +87,9 @@ type GatewayClient struct {
 
 	// Raw Http Client to do OAuth2 login.
 	httpClient *http.Client
+
+	// Headers sent with every request to Gate.
+	defaultHeaders map[string]string
 }
 
 func (m *GatewayClient) GateEndpoint() string {
@@ -96,6 +102,20 @@ func (m *GatewayClient) GateEndpoint() string {
 	return m.Config.Gate.Endpoint
 }
 
+// session holds the client shared by the commands run from an interactive shell, so they
+// don't authenticate and probe Gate again. It's replaced when the connection flags change.
+var session struct {
+	enabled bool
+	key     string
+	client  *GatewayClient
+}
+
+// EnableSession makes NewGateClient reuse the client it creates for later calls with the
+// same connection flags.
+func EnableSession() {
+	session.enabled = true
+}
+
 // Create new spinnaker gateway client with flag
 func NewGateClient(flags *pflag.FlagSet) (*GatewayClient, error) {
 	err := configureOutput(flags)
@@ -103,39 +123,46 @@ func NewGateClient(flags *pflag.FlagSet) (*GatewayClient, error) {
 		return nil, err
 	}
 
-	gateClient, err := createClient(flags)
-	if err != nil {
-		return nil, err
+	if !session.enabled {
+		return newGateClient(flags)
 	}
-
-	err = userConfig(flags, gateClient)
+	key, err := sessionKey(flags)
 	if err != nil {
 		return nil, err
 	}
-
-	// Api client initialization.
-	httpClient, err := gateClient.initializeClient()
+	if session.client != nil && session.key == key {
+		return session.client, nil
+	}
+	gateClient, err := newGateClient(flags)
 	if err != nil {
-		util.UI.Error("Could not initialize http client, failing.")
 		return nil, err
 	}
+	session.key = key
+	session.client = gateClient
+	return gateClient, nil
+}
 
-	gateClient.httpClient = httpClient
-
-	err = gateClient.authenticateOAuth2()
-	if err != nil {
-		util.UI.Error("OAuth2 Authentication failed.")
-		return nil, err
+// sessionKey identifies the flags that a client depends on.
+func sessionKey(flags *pflag.FlagSet) (string, error) {
+	var values []string
+	for _, name := range []string{"config", "gate-endpoint", "insecure", "default-headers"} {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return "", fmt.Errorf("flag accessed but not defined: %s", name)
+		}
+		values = append(values, flag.Value.String())
 	}
+	return strings.Join(values, "\x00"), nil
+}
 
-	err = gateClient.authenticateGoogleServiceAccount()
+func newGateClient(flags *pflag.FlagSet) (*GatewayClient, error) {
+	gateClient, err := createClient(flags)
 	if err != nil {
-		util.UI.Error(fmt.Sprintf("Google service account authentication failed: %v", err))
 		return nil, err
 	}
 
-	if err = gateClient.authenticateLdap(); err != nil {
-		util.UI.Error("LDAP Authentication Failed")
+	err = userConfig(flags, gateClient)
+	if err != nil {
 		return nil, err
 	}
 
@@ -156,14 +183,42 @@ func NewGateClient(flags *pflag.FlagSet) (*GatewayClient, error) {
 			m[strings.TrimSpace(header[0])] = strings.TrimSpace(header[1])
 		}
 	}
+	gateClient.defaultHeaders = m
 
-	cfg := &gate.Configuration{
-		BasePath:      gateClient.GateEndpoint(),
-		DefaultHeader: m,
-		UserAgent:     fmt.Sprintf("%s/%s", version.UserAgent, version.String()),
-		HTTPClient:    httpClient,
+	// Api client initialization.
+	httpClient, err := gateClient.initializeClient()
+	if err != nil {
+		util.UI.Error("Could not initialize http client, failing.")
+		return nil, err
 	}
-	gateClient.APIClient = gate.NewAPIClient(cfg)
+
+	gateClient.httpClient = httpClient
+
+	if gateClient.Config.Auth.HasMethods() {
+		if err = gateClient.authenticateMethods(); err != nil {
+			util.UI.Error("Authentication failed.")
+			return nil, err
+		}
+	} else {
+		err = gateClient.authenticateOAuth2()
+		if err != nil {
+			util.UI.Error("OAuth2 Authentication failed.")
+			return nil, err
+		}
+
+		err = gateClient.authenticateGoogleServiceAccount()
+		if err != nil {
+			util.UI.Error(fmt.Sprintf("Google service account authentication failed: %v", err))
+			return nil, err
+		}
+
+		if err = gateClient.authenticateLdap(); err != nil {
+			util.UI.Error("LDAP Authentication Failed")
+			return nil, err
+		}
+	}
+
+	gateClient.APIClient = gateClient.newAPIClient()
 
 	// TODO: Verify version compatibility between Spin CLI and Gate.
 	_, _, err = gateClient.VersionControllerApi.GetVersionUsingGET(gateClient.Context)
@@ -175,6 +230,24 @@ func NewGateClient(flags *pflag.FlagSet) (*GatewayClient, error) {
 	return gateClient, nil
 }
 
+// LoadConfig reads the CLI configuration and sets up output without connecting to Gate,
+// for commands that can talk to other Spinnaker services directly.
+func LoadConfig(flags *pflag.FlagSet) (config.Config, error) {
+	if err := configureOutput(flags); err != nil {
+		return config.Config{}, err
+	}
+
+	gateClient, err := createClient(flags)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	if err = userConfig(flags, gateClient); err != nil {
+		return config.Config{}, err
+	}
+	return gateClient.Config, nil
+}
+
 func userConfig(flags *pflag.FlagSet, gateClient *GatewayClient) error {
 	configLocationFlag, err := flags.GetString("config")
 	if err != nil {
@@ -256,51 +329,11 @@ func (m *GatewayClient) initializeClient() (*http.Client, error) {
 		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
-	if auth != nil && auth.Enabled && auth.X509 != nil {
-		X509 := auth.X509
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{},
-		}
-
-		if !X509.IsValid() {
-			// Misconfigured.
-			return nil, errors.New("Incorrect x509 auth configuration.\nMust specify certPath/keyPath or cert/key pair.")
-		}
-
-		if X509.CertPath != "" && X509.KeyPath != "" {
-			certPath, err := homedir.Expand(X509.CertPath)
-			if err != nil {
-				return nil, err
-			}
-			keyPath, err := homedir.Expand(X509.KeyPath)
-			if err != nil {
-				return nil, err
-			}
-
-			cert, err := tls.LoadX509KeyPair(certPath, keyPath)
-			if err != nil {
-				return nil, err
-			}
-
-			clientCA, err := ioutil.ReadFile(certPath)
-			if err != nil {
-				return nil, err
-			}
-
-			return m.initializeX509Config(client, clientCA, cert), nil
-		} else if X509.Cert != "" && X509.Key != "" {
-			certBytes := []byte(X509.Cert)
-			keyBytes := []byte(X509.Key)
-			cert, err := tls.X509KeyPair(certBytes, keyBytes)
-			if err != nil {
-				return nil, err
-			}
-
-			return m.initializeX509Config(client, certBytes, cert), nil
-		} else {
-			// Misconfigured.
-			return nil, errors.New("Incorrect x509 auth configuration.\nMust specify certPath/keyPath or cert/key pair.")
-		}
+	if auth.HasMethods() {
+		// The configured methods are tried in order by authenticateMethods.
+		return &client, nil
+	} else if auth != nil && auth.Enabled && auth.X509 != nil {
+		return m.initializeX509(client)
 	} else if auth != nil && auth.Enabled && auth.Iap != nil {
 		accessToken, err := m.authenticateIAP()
 		m.Context = context.WithValue(context.Background(), gate.ContextAccessToken, accessToken)
@@ -319,6 +352,53 @@ func (m *GatewayClient) initializeClient() (*http.Client, error) {
 	}
 }
 
+func (m *GatewayClient) initializeX509(client http.Client) (*http.Client, error) {
+	X509 := m.Config.Auth.X509
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{},
+	}
+
+	if !X509.IsValid() {
+		// Misconfigured.
+		return nil, errors.New("Incorrect x509 auth configuration.\nMust specify certPath/keyPath or cert/key pair.")
+	}
+
+	if X509.CertPath != "" && X509.KeyPath != "" {
+		certPath, err := homedir.Expand(X509.CertPath)
+		if err != nil {
+			return nil, err
+		}
+		keyPath, err := homedir.Expand(X509.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCA, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return m.initializeX509Config(client, clientCA, cert), nil
+	} else if X509.Cert != "" && X509.Key != "" {
+		certBytes := []byte(X509.Cert)
+		keyBytes := []byte(X509.Key)
+		cert, err := tls.X509KeyPair(certBytes, keyBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return m.initializeX509Config(client, certBytes, cert), nil
+	} else {
+		// Misconfigured.
+		return nil, errors.New("Incorrect x509 auth configuration.\nMust specify certPath/keyPath or cert/key pair.")
+	}
+}
+
 func (m *GatewayClient) initializeX509Config(client http.Client, clientCA []byte, cert tls.Certificate) *http.Client {
 	clientCertPool := x509.NewCertPool()
 	clientCertPool.AppendCertsFromPEM(clientCA)
@@ -332,6 +412,115 @@ func (m *GatewayClient) initializeX509Config(client http.Client, clientCA []byte
 	return &client
 }
 
+// newAPIClient returns a generated API client sending requests with the client's current
+// http client and default headers.
+func (m *GatewayClient) newAPIClient() *gate.APIClient {
+	return gate.NewAPIClient(&gate.Configuration{
+		BasePath:      m.GateEndpoint(),
+		DefaultHeader: m.defaultHeaders,
+		UserAgent:     fmt.Sprintf("%s/%s", version.UserAgent, version.String()),
+		HTTPClient:    m.httpClient,
+	})
+}
+
+// authenticateMethods tries each of the configured auth.methods in order and keeps
+// the credentials of the first method Gate accepts.
+func (m *GatewayClient) authenticateMethods() error {
+	methods := m.Config.Auth.Methods
+	initialClient := *m.httpClient
+	initialContext := m.Context
+	for _, method := range methods {
+		// Start every method from a clean client, without a failed method's certificates or session.
+		client := initialClient
+		client.Jar, _ = cookiejar.New(nil)
+		m.httpClient = &client
+		m.Context = initialContext
+
+		err := m.authenticateMethod(method)
+		if err == nil {
+			err = m.verifyAuthentication()
+		}
+		if err == nil {
+			util.UI.Info(fmt.Sprintf("Authenticated using %s.", method))
+			return nil
+		}
+		util.UI.Warn(fmt.Sprintf("Authentication using %s failed: %v", method, err))
+	}
+	return fmt.Errorf("none of the configured authentication methods succeeded: %s", strings.Join(methods, ", "))
+}
+
+// verifyAuthentication checks Gate accepts the client's credentials, as wrong passwords or
+// expired sessions are only noticed by Gate.
+func (m *GatewayClient) verifyAuthentication() error {
+	user, resp, err := m.newAPIClient().AuthControllerApi.UserUsingGET(m.Context)
+	if resp != nil && resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("Gate rejected the credentials, status code: %d", resp.StatusCode)
+	}
+	// Gate answers anonymous requests with an empty body.
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if user.Username == "" && user.Email == "" {
+		return errors.New("Gate did not recognize the credentials")
+	}
+	return nil
+}
+
+func (m *GatewayClient) authenticateMethod(method string) error {
+	auth := m.Config.Auth
+	notConfigured := fmt.Errorf("no '%s' auth configuration found", method)
+
+	switch method {
+	case authconfig.MethodX509:
+		if auth.X509 == nil {
+			return notConfigured
+		}
+		client, err := m.initializeX509(*m.httpClient)
+		if err != nil {
+			return err
+		}
+		m.httpClient = client
+	case authconfig.MethodIap:
+		if auth.Iap == nil {
+			return notConfigured
+		}
+		accessToken, err := m.authenticateIAP()
+		if err != nil {
+			return err
+		}
+		m.Context = context.WithValue(context.Background(), gate.ContextAccessToken, accessToken)
+	case authconfig.MethodBasic:
+		if auth.Basic == nil {
+			return notConfigured
+		}
+		if !auth.Basic.IsValid() {
+			return errors.New("Incorrect Basic auth configuration. Must include username and password.")
+		}
+		m.Context = context.WithValue(context.Background(), gate.ContextBasicAuth, gate.BasicAuth{
+			UserName: auth.Basic.Username,
+			Password: auth.Basic.Password,
+		})
+	case authconfig.MethodOAuth2:
+		if auth.OAuth2 == nil {
+			return notConfigured
+		}
+		return m.authenticateOAuth2()
+	case authconfig.MethodGoogleServiceAccount:
+		if auth.GoogleServiceAccount == nil {
+			return notConfigured
+		}
+		return m.authenticateGoogleServiceAccount()
+	case authconfig.MethodLdap:
+		if auth.Ldap == nil {
+			return notConfigured
+		}
+		return m.authenticateLdap()
+	default:
+		return fmt.Errorf("unknown authentication method '%s'", method)
+	}
+	return nil
+}
+
 func (m *GatewayClient) authenticateOAuth2() error {
 	auth := m.Config.Auth
 	if auth != nil && auth.Enabled && auth.OAuth2 != nil {
@@ -395,7 +584,9 @@ func (m *GatewayClient) authenticateOAuth2() error {
 		OAuth2.CachedToken = newToken
 		_ = m.writeYAMLConfig()
 
-		m.login(newToken.AccessToken)
+		if err := m.login(newToken.AccessToken); err != nil {
+			return err
+		}
 		m.Context = context.Background()
 	}
 	return nil
@@ -457,14 +648,72 @@ func (m *GatewayClient) authenticateGoogleServiceAccount() (err error) {
 	return nil
 }
 
+// Health fetches Gate's health endpoint, which isn't part of the generated API client.
+// Gate answers 503 when it is degraded, so the report is returned for any status that has one.
+func (m *GatewayClient) Health() (map[string]interface{}, *http.Response, error) {
+	req, err := http.NewRequest("GET", m.GateEndpoint()+"/health", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.Do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+	defer resp.Body.Close()
+	var health map[string]interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, resp, fmt.Errorf("Could not decode the health of Gate, status code: %d\n", resp.StatusCode)
+	}
+	return health, resp, nil
+}
+
+// Do sends a request Gate's API client has no method for, with the same credentials, user agent
+// and default headers as the API client's requests.
+func (m *GatewayClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", version.UserAgent, version.String()))
+	req, err := m.authorizeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range m.defaultHeaders {
+		req.Header.Set(key, value)
+	}
+	return m.httpClient.Do(req)
+}
+
+// authorizeRequest sets the credentials held in the client's context on a request made outside
+// the generated API client, the same way the generated client does.
+func (m *GatewayClient) authorizeRequest(req *http.Request) (*http.Request, error) {
+	if m.Context == nil {
+		return req, nil
+	}
+	req = req.WithContext(m.Context)
+	if source, ok := m.Context.Value(gate.ContextOAuth2).(oauth2.TokenSource); ok {
+		token, err := source.Token()
+		if err != nil {
+			return nil, err
+		}
+		token.SetAuthHeader(req)
+	}
+	if auth, ok := m.Context.Value(gate.ContextBasicAuth).(gate.BasicAuth); ok {
+		req.SetBasicAuth(auth.UserName, auth.Password)
+	}
+	if accessToken, ok := m.Context.Value(gate.ContextAccessToken).(string); ok {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return req, nil
+}
+
 func (m *GatewayClient) login(accessToken string) error {
 	loginReq, err := http.NewRequest("GET", m.GateEndpoint()+"/login", nil)
 	if err != nil {
 		return err
 	}
 	loginReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	m.httpClient.Do(loginReq) // Login to establish session.
-	return nil
+	_, err = m.httpClient.Do(loginReq) // Login to establish session.
+	return err
 }
 
 func (m *GatewayClient) authenticateLdap() error {
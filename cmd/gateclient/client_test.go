@@ -0,0 +1,215 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package gateclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	gate "github.com/spinnaker/spin/gateapi"
+)
+
+func TestNewGateClient_methodsFallback(t *testing.T) {
+	ts := testGateBasicAuth("user", "pass")
+	defer ts.Close()
+
+	configFile := tempConfigFile(authMethodsConfig)
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	_, err := NewGateClient(testFlags(ts.URL, configFile.Name()))
+	if err != nil {
+		t.Fatalf("Client creation failed with: %s", err)
+	}
+}
+
+func TestNewGateClient_methodsAllFail(t *testing.T) {
+	ts := testGateBasicAuth("user", "pass")
+	defer ts.Close()
+
+	configFile := tempConfigFile(authMethodsUnconfiguredConfig)
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	_, err := NewGateClient(testFlags(ts.URL, configFile.Name()))
+	if err == nil {
+		t.Fatal("Expected client creation to fail when no method is configured.")
+	}
+}
+
+func TestNewGateClient_methodsRejected(t *testing.T) {
+	ts := testGateBasicAuth("user", "pass")
+	defer ts.Close()
+
+	configFile := tempConfigFile(authMethodsWrongPasswordConfig)
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	gateClient, err := NewGateClient(testFlags(ts.URL, configFile.Name()))
+	if err != nil {
+		t.Fatalf("Expected to fall back to iap when Gate rejects the basic credentials, got: %s", err)
+	}
+	if _, ok := gateClient.Context.Value(gate.ContextBasicAuth).(gate.BasicAuth); ok {
+		t.Fatal("Expected the rejected basic credentials to be dropped")
+	}
+}
+
+func TestNewGateClient_methodsAllRejected(t *testing.T) {
+	ts := testGateBasicAuth("user", "pass")
+	defer ts.Close()
+
+	configFile := tempConfigFile(strings.Replace(authMethodsWrongPasswordConfig, "- iap\n", "", 1))
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	_, err := NewGateClient(testFlags(ts.URL, configFile.Name()))
+	if err == nil {
+		t.Fatal("Expected client creation to fail when Gate rejects the only method.")
+	}
+}
+
+func TestGatewayClient_healthBasicAuth(t *testing.T) {
+	ts := testGateBasicAuth("user", "pass")
+	defer ts.Close()
+
+	configFile := tempConfigFile(basicAuthConfig)
+	if configFile == nil {
+		t.Fatal("Could not create temp config file.")
+	}
+	defer os.Remove(configFile.Name())
+
+	gateClient, err := NewGateClient(testFlags(ts.URL, configFile.Name()))
+	if err != nil {
+		t.Fatalf("Client creation failed with: %s", err)
+	}
+	health, resp, err := gateClient.Health()
+	if err != nil {
+		t.Fatalf("Health failed with: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK || health["status"] != "UP" {
+		t.Fatalf("Expected an authenticated health check, got status code %d: %v", resp.StatusCode, health)
+	}
+}
+
+func testFlags(endpoint, configPath string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("config", configPath, "")
+	flags.String("gate-endpoint", endpoint, "")
+	flags.Bool("insecure", false, "")
+	flags.Bool("quiet", true, "")
+	flags.Bool("no-color", true, "")
+	flags.String("output", "", "")
+	flags.String("default-headers", "", "")
+	return flags
+}
+
+func tempConfigFile(content string) *os.File {
+	tempFile, _ := ioutil.TempFile("" /* /tmp dir. */, "spin-config")
+	bytes, err := tempFile.Write([]byte(content))
+	if err != nil || bytes == 0 {
+		fmt.Println("Could not write temp file.")
+		return nil
+	}
+	return tempFile
+}
+
+// testGateBasicAuth spins up a local http server that only answers version and health requests
+// carrying the expected basic auth credentials, or the bearer token "token". Like Gate, it
+// answers user requests without valid credentials with an empty body.
+func testGateBasicAuth(username, password string) *httptest.Server {
+	isAuthorized := func(r *http.Request) bool {
+		if r.Header.Get("Authorization") == "Bearer token" {
+			return true
+		}
+		user, pass, ok := r.BasicAuth()
+		return ok && user == username && pass == password
+	}
+	authorized := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isAuthorized(r) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintln(w, body)
+		})
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/version", authorized(`{"version": "Unknown"}`))
+	mux.Handle("/health", authorized(`{"status": "UP"}`))
+	mux.Handle("/auth/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAuthorized(r) {
+			fmt.Fprintln(w, `{"username": "user"}`)
+		}
+	}))
+	return httptest.NewServer(mux)
+}
+
+const authMethodsConfig = `
+auth:
+  enabled: true
+  methods:
+  - ldap
+  - x509
+  - basic
+  x509:
+    certPath: "/does/not/exist.crt"
+    keyPath: "/does/not/exist.key"
+  basic:
+    username: user
+    password: pass
+`
+
+const authMethodsWrongPasswordConfig = `
+auth:
+  enabled: true
+  methods:
+  - basic
+  - iap
+  basic:
+    username: user
+    password: wrong
+  iap:
+    iapIdToken: token
+`
+
+const basicAuthConfig = `
+auth:
+  enabled: true
+  basic:
+    username: user
+    password: pass
+`
+
+const authMethodsUnconfiguredConfig = `
+auth:
+  enabled: true
+  methods:
+  - ldap
+  - oauth2
+`
@@ -0,0 +1,61 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type DisableOptions struct {
+	*instanceOptions
+	target instanceTarget
+}
+
+var (
+	disableInstanceShort   = "Disable the provided instance in discovery"
+	disableInstanceLong    = "Disable the provided instance in discovery and wait for the operation to complete"
+	disableInstanceExample = "usage: spin instance disable [options] instance-id"
+)
+
+func NewDisableCmd(instanceOptions instanceOptions) *cobra.Command {
+	options := DisableOptions{
+		instanceOptions: &instanceOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "disable",
+		Short:   disableInstanceShort,
+		Long:    disableInstanceLong,
+		Example: disableInstanceExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return disableInstance(cmd, options, args)
+		},
+	}
+
+	addTargetFlags(cmd, &options.target)
+
+	return cmd
+}
+
+func disableInstance(cmd *cobra.Command, options DisableOptions, args []string) error {
+	return runInstanceOperation(cmd, options.target, args, "Disable instance in discovery",
+		func(instanceId string, instance map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"type":            "disableInstancesInDiscovery",
+				"instanceIds":     []string{instanceId},
+				"serverGroupName": instanceServerGroup(instance),
+				"asgName":         instanceServerGroup(instance),
+			}
+		})
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type EnableOptions struct {
+	*instanceOptions
+	target instanceTarget
+}
+
+var (
+	enableInstanceShort   = "Enable the provided instance in discovery"
+	enableInstanceLong    = "Enable the provided instance in discovery and wait for the operation to complete"
+	enableInstanceExample = "usage: spin instance enable [options] instance-id"
+)
+
+func NewEnableCmd(instanceOptions instanceOptions) *cobra.Command {
+	options := EnableOptions{
+		instanceOptions: &instanceOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "enable",
+		Short:   enableInstanceShort,
+		Long:    enableInstanceLong,
+		Example: enableInstanceExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return enableInstance(cmd, options, args)
+		},
+	}
+
+	addTargetFlags(cmd, &options.target)
+
+	return cmd
+}
+
+func enableInstance(cmd *cobra.Command, options EnableOptions, args []string) error {
+	return runInstanceOperation(cmd, options.target, args, "Enable instance in discovery",
+		func(instanceId string, instance map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"type":            "enableInstancesInDiscovery",
+				"instanceIds":     []string{instanceId},
+				"serverGroupName": instanceServerGroup(instance),
+				"asgName":         instanceServerGroup(instance),
+			}
+		})
+}
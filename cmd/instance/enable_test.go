@@ -0,0 +1,65 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInstanceEnable_basic(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateInstanceSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewEnableCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "enable", "i-1234", "--account", "prod", "--region", "us-east-1", "-a", "other", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	job := submittedJob(t, task)
+	if job["type"] != "enableInstancesInDiscovery" {
+		t.Fatalf("Expected enableInstancesInDiscovery job, got %v", job["type"])
+	}
+	if task["application"] != "other" {
+		t.Fatalf("Expected application from flag, got %v", task["application"])
+	}
+}
+
+func TestInstanceEnable_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewEnableCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "enable", "i-1234", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
+	"github.com/spinnaker/spin/util"
+)
+
+type instanceOptions struct{}
+
+var (
+	instanceShort   = ""
+	instanceLong    = ""
+	instanceExample = ""
+)
+
+// instanceTaskAttempts bounds how long to wait for an instance operation to complete.
+const instanceTaskAttempts = 10
+
+// instanceTarget identifies the instance an operation applies to.
+type instanceTarget struct {
+	application string
+	account     string
+	region      string
+}
+
+func NewInstanceCmd(out io.Writer) *cobra.Command {
+	options := instanceOptions{}
+	cmd := &cobra.Command{
+		Use:     "instance",
+		Aliases: []string{"instances", "in"},
+		Short:   instanceShort,
+		Long:    instanceLong,
+		Example: instanceExample,
+	}
+
+	// create subcommands
+	cmd.AddCommand(NewTerminateCmd(options))
+	cmd.AddCommand(NewRebootCmd(options))
+	cmd.AddCommand(NewEnableCmd(options))
+	cmd.AddCommand(NewDisableCmd(options))
+	return cmd
+}
+
+func addTargetFlags(cmd *cobra.Command, target *instanceTarget) {
+	cmd.PersistentFlags().StringVar(&target.account, "account", "", "account the instance runs in")
+	cmd.PersistentFlags().StringVar(&target.region, "region", "", "region the instance runs in")
+	cmd.PersistentFlags().StringVarP(&target.application, "application", "a", "", "(optional) Spinnaker application of the instance, derived from its server group by default")
+}
+
+// runInstanceOperation looks up the instance, submits the Orca operation built by buildJob for
+// it and waits for the operation to complete.
+func runInstanceOperation(cmd *cobra.Command, target instanceTarget, args []string, description string,
+	buildJob func(instanceId string, instance map[string]interface{}) map[string]interface{}) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	instanceId, err := util.ReadArgsOrStdin(args)
+	if err != nil {
+		return err
+	}
+	if instanceId == "" {
+		return errors.New("no instance id supplied, exiting")
+	}
+	if target.account == "" || target.region == "" {
+		return errors.New("one of required parameters 'account' or 'region' not set")
+	}
+
+	details, resp, err := gateClient.InstanceControllerApi.GetInstanceDetailsUsingGET(gateClient.Context,
+		target.account, target.region, instanceId, map[string]interface{}{})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Instance '%s' not found in %s/%s\n", instanceId, target.account, target.region)
+	}
+	if err != nil {
+		return err
+	}
+	instance, _ := details.(map[string]interface{})
+
+	application := target.application
+	if application == "" {
+		application = instanceApplication(instance)
+	}
+	if application == "" {
+		return fmt.Errorf("Could not determine the application of instance '%s', please provide 'application'\n", instanceId)
+	}
+
+	job := buildJob(instanceId, instance)
+	job["credentials"] = target.account
+	job["region"] = target.region
+	job["cloudProvider"] = instanceCloudProvider(instance)
+
+	task := map[string]interface{}{
+		"job":         []interface{}{job},
+		"application": application,
+		"description": fmt.Sprintf("%s: %s", description, instanceId),
+	}
+
+	taskRef, resp, err := gateClient.TaskControllerApi.TaskUsingPOST1(gateClient.Context, task)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error submitting instance operation, status code: %d\n", resp.StatusCode)
+	}
+
+	err = orca_tasks.WaitForSuccessfulTask(gateClient, taskRef, instanceTaskAttempts)
+	if err != nil {
+		return err
+	}
+
+	util.UI.Info(util.Colorize().Color(fmt.Sprintf("[reset][bold][green]%s succeeded for instance %s", description, instanceId)))
+	return nil
+}
+
+func instanceServerGroup(instance map[string]interface{}) string {
+	serverGroup, _ := instance["serverGroup"].(string)
+	return serverGroup
+}
+
+func instanceCloudProvider(instance map[string]interface{}) string {
+	if cloudProvider, ok := instance["cloudProvider"].(string); ok {
+		return cloudProvider
+	}
+	provider, _ := instance["provider"].(string)
+	return provider
+}
+
+// instanceApplication returns the application of the instance's server group, following the
+// app-stack-detail-version naming convention when no moniker is available.
+func instanceApplication(instance map[string]interface{}) string {
+	if moniker, ok := instance["moniker"].(map[string]interface{}); ok {
+		if app, ok := moniker["app"].(string); ok && app != "" {
+			return app
+		}
+	}
+	return strings.SplitN(instanceServerGroup(instance), "-", 2)[0]
+}
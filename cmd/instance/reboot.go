@@ -0,0 +1,60 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type RebootOptions struct {
+	*instanceOptions
+	target instanceTarget
+}
+
+var (
+	rebootInstanceShort   = "Reboot the provided instance"
+	rebootInstanceLong    = "Reboot the provided instance and wait for the operation to complete"
+	rebootInstanceExample = "usage: spin instance reboot [options] instance-id"
+)
+
+func NewRebootCmd(instanceOptions instanceOptions) *cobra.Command {
+	options := RebootOptions{
+		instanceOptions: &instanceOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "reboot",
+		Short:   rebootInstanceShort,
+		Long:    rebootInstanceLong,
+		Example: rebootInstanceExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rebootInstance(cmd, options, args)
+		},
+	}
+
+	addTargetFlags(cmd, &options.target)
+
+	return cmd
+}
+
+func rebootInstance(cmd *cobra.Command, options RebootOptions, args []string) error {
+	return runInstanceOperation(cmd, options.target, args, "Reboot instance",
+		func(instanceId string, instance map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"type":            "rebootInstances",
+				"instanceIds":     []string{instanceId},
+				"serverGroupName": instanceServerGroup(instance),
+			}
+		})
+}
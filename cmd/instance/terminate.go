@@ -0,0 +1,74 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type TerminateOptions struct {
+	*instanceOptions
+	target instanceTarget
+	shrink bool
+}
+
+var (
+	terminateInstanceShort   = "Terminate the provided instance"
+	terminateInstanceLong    = "Terminate the provided instance and wait for the operation to complete. Unless --shrink is set, the server group replaces the instance"
+	terminateInstanceExample = "usage: spin instance terminate [options] instance-id"
+)
+
+func NewTerminateCmd(instanceOptions instanceOptions) *cobra.Command {
+	options := TerminateOptions{
+		instanceOptions: &instanceOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "terminate",
+		Short:   terminateInstanceShort,
+		Long:    terminateInstanceLong,
+		Example: terminateInstanceExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return terminateInstance(cmd, options, args)
+		},
+	}
+
+	addTargetFlags(cmd, &options.target)
+	cmd.PersistentFlags().BoolVar(&options.shrink, "shrink", false, "also decrement the desired capacity of the instance's server group")
+
+	return cmd
+}
+
+func terminateInstance(cmd *cobra.Command, options TerminateOptions, args []string) error {
+	if options.shrink {
+		return runInstanceOperation(cmd, options.target, args, "Terminate instance and shrink server group",
+			func(instanceId string, instance map[string]interface{}) map[string]interface{} {
+				return map[string]interface{}{
+					"type":            "terminateInstanceAndDecrementServerGroup",
+					"instance":        instanceId,
+					"serverGroupName": instanceServerGroup(instance),
+					"asgName":         instanceServerGroup(instance),
+				}
+			})
+	}
+
+	return runInstanceOperation(cmd, options.target, args, "Terminate instance",
+		func(instanceId string, instance map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{
+				"type":            "terminateInstances",
+				"instanceIds":     []string{instanceId},
+				"serverGroupName": instanceServerGroup(instance),
+			}
+		})
+}
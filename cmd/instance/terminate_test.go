@@ -0,0 +1,220 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestInstanceTerminate_basic(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateInstanceSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewTerminateCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "terminate", "i-1234", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	job := submittedJob(t, task)
+	if job["type"] != "terminateInstances" {
+		t.Fatalf("Expected terminateInstances job, got %v", job["type"])
+	}
+	if task["application"] != "app" {
+		t.Fatalf("Expected application derived from server group, got %v", task["application"])
+	}
+	if job["credentials"] != "prod" || job["region"] != "us-east-1" || job["cloudProvider"] != "aws" {
+		t.Fatalf("Unexpected job target: %v", job)
+	}
+}
+
+func TestInstanceTerminate_shrink(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateInstanceSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewTerminateCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "terminate", "i-1234", "--account", "prod", "--region", "us-east-1", "--shrink", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	job := submittedJob(t, task)
+	if job["type"] != "terminateInstanceAndDecrementServerGroup" {
+		t.Fatalf("Expected terminateInstanceAndDecrementServerGroup job, got %v", job["type"])
+	}
+	if job["instance"] != "i-1234" || job["serverGroupName"] != "app-main-v001" {
+		t.Fatalf("Unexpected job: %v", job)
+	}
+}
+
+func TestInstanceTerminate_flags(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateInstanceSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewTerminateCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "terminate", "i-1234", "--gate-endpoint", ts.URL} // Missing account and region.
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestInstanceTerminate_notFound(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateInstanceSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewTerminateCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "terminate", "i-missing", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+	if task != nil {
+		t.Fatalf("Expected no task to be submitted, got %v", task)
+	}
+}
+
+func TestInstanceTerminate_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewTerminateCmd(instanceOptions{})
+	rootCmd := getRootCmdForTest()
+	instanceCmd := NewInstanceCmd(os.Stdout)
+	instanceCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(instanceCmd)
+
+	args := []string{"instance", "terminate", "i-1234", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func submittedJob(t *testing.T, task map[string]interface{}) map[string]interface{} {
+	jobs, _ := task["job"].([]interface{})
+	if len(jobs) != 1 {
+		t.Fatalf("Expected a single job to be submitted, got %v", task["job"])
+	}
+	return jobs[0].(map[string]interface{})
+}
+
+// testGateInstanceSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves the details of instance i-1234 and records the submitted task.
+func testGateInstanceSuccess(task *map[string]interface{}) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/instances/prod/us-east-1/i-1234", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(instanceJson))
+	}))
+	mux.Handle("/instances/prod/us-east-1/i-missing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(task)
+		payload := map[string]string{
+			"ref": "/tasks/id",
+		}
+		b, _ := json.Marshal(&payload)
+		fmt.Fprintln(w, string(b))
+	}))
+	mux.Handle("/tasks/id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]string{
+			"status": "SUCCEEDED",
+		}
+		b, _ := json.Marshal(&payload)
+		fmt.Fprintln(w, string(b))
+	}))
+	return httptest.NewServer(mux)
+}
+
+// GateServerFail spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with a 500 InternalServerError.
+func GateServerFail() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/instances/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const instanceJson = `
+{
+  "name": "i-1234",
+  "instanceId": "i-1234",
+  "serverGroup": "app-main-v001",
+  "cloudProvider": "aws",
+  "account": "prod",
+  "region": "us-east-1",
+  "health": [
+    {
+      "type": "Discovery",
+      "state": "Up"
+    }
+  ]
+}
+`
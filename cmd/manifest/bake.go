@@ -0,0 +1,289 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package manifest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
+	"github.com/spinnaker/spin/util"
+)
+
+type BakeOptions struct {
+	*manifestOptions
+	application   string
+	helm          string
+	helmVersion   string
+	values        []string
+	overrides     []string
+	kustomize     string
+	kustomizeFile string
+	branch        string
+	name          string
+	namespace     string
+	outputFile    string
+}
+
+var (
+	bakeManifestShort   = "Render a Helm chart or Kustomize manifests through Spinnaker"
+	bakeManifestLong    = "Submit a bakeManifest operation that renders a local Helm chart, or the Kustomize manifests in a git repository, with the Helm or Kustomize version installed in Spinnaker, and print the rendered manifests"
+	bakeManifestExample = `usage: spin manifest bake [options]
+	spin manifest bake -a app --helm chart.tgz --values values.yaml --namespace prod --output-file rendered.yaml
+	spin manifest bake -a app --kustomize https://github.com/org/repo.git --kustomize-file overlays/prod/kustomization.yaml`
+)
+
+const (
+	// embeddedArtifactAccount is the artifact account clouddriver reads embedded artifacts with.
+	embeddedArtifactAccount = "embedded-artifact"
+	bakedArtifactId         = "spin-baked-manifest"
+	bakeTaskAttempts        = 10
+)
+
+func NewBakeCmd(manifestOptions manifestOptions) *cobra.Command {
+	options := BakeOptions{
+		manifestOptions: &manifestOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "bake",
+		Short:   bakeManifestShort,
+		Long:    bakeManifestLong,
+		Example: bakeManifestExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return bakeManifest(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.application, "application", "a", "", "Spinnaker application to run the bake in")
+	cmd.PersistentFlags().StringVar(&options.helm, "helm", "", "path to a packaged Helm chart (.tgz) to render")
+	cmd.PersistentFlags().StringVar(&options.helmVersion, "helm-version", "3", "major version of Helm to render the chart with, 2 or 3")
+	cmd.PersistentFlags().StringSliceVar(&options.values, "values", nil, "(optional) path to a Helm values file, may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&options.overrides, "set", nil, "(optional) Helm value override as key=value, may be repeated")
+	cmd.PersistentFlags().StringVar(&options.kustomize, "kustomize", "", "URL of a git repository containing the Kustomize manifests to render")
+	cmd.PersistentFlags().StringVar(&options.kustomizeFile, "kustomize-file", "kustomization.yaml", "path of the kustomization file within the repository")
+	cmd.PersistentFlags().StringVar(&options.branch, "branch", "master", "git branch of the Kustomize repository")
+	cmd.PersistentFlags().StringVar(&options.name, "name", "", "(optional) release name, defaults to the chart or repository name")
+	cmd.PersistentFlags().StringVar(&options.namespace, "namespace", "", "(optional) namespace to render the manifests for")
+	cmd.PersistentFlags().StringVar(&options.outputFile, "output-file", "", "(optional) file to write the rendered manifests to instead of printing them")
+
+	return cmd
+}
+
+func bakeManifest(cmd *cobra.Command, options BakeOptions) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	if options.application == "" {
+		return errors.New("required parameter 'application' not set")
+	}
+	if (options.helm == "") == (options.kustomize == "") {
+		return errors.New("exactly one of 'helm' or 'kustomize' must be set")
+	}
+
+	var job map[string]interface{}
+	if options.helm != "" {
+		job, err = helmBakeJob(options)
+	} else {
+		job, err = kustomizeBakeJob(options)
+	}
+	if err != nil {
+		return err
+	}
+
+	task := map[string]interface{}{
+		"job":         []interface{}{job},
+		"application": options.application,
+		"description": fmt.Sprintf("Bake manifest: %s", job["outputName"]),
+	}
+	taskRef, resp, err := gateClient.TaskControllerApi.TaskUsingPOST1(gateClient.Context, task)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error submitting bake, status code: %d\n", resp.StatusCode)
+	}
+
+	result, err := orca_tasks.WaitForSuccessfulTaskResult(gateClient, taskRef, bakeTaskAttempts)
+	if err != nil {
+		return err
+	}
+	rendered, err := bakedManifest(result)
+	if err != nil {
+		return err
+	}
+
+	if options.outputFile == "" {
+		util.UI.Output(strings.TrimSuffix(rendered, "\n"))
+		return nil
+	}
+	if err := ioutil.WriteFile(options.outputFile, []byte(rendered), 0644); err != nil {
+		return err
+	}
+	util.UI.Info(util.Colorize().Color(fmt.Sprintf("[reset][bold][green]Rendered manifests written to %s", options.outputFile)))
+	return nil
+}
+
+func helmBakeJob(options BakeOptions) (map[string]interface{}, error) {
+	var renderer string
+	switch options.helmVersion {
+	case "2":
+		renderer = "HELM2"
+	case "3":
+		renderer = "HELM3"
+	default:
+		return nil, fmt.Errorf("Unsupported Helm version %s, expected 2 or 3\n", options.helmVersion)
+	}
+
+	chart, err := embeddedArtifact(options.helm)
+	if err != nil {
+		return nil, err
+	}
+	// The chart is the first input artifact, followed by the values files in order.
+	inputArtifacts := []interface{}{chart}
+	for _, path := range options.values {
+		values, err := embeddedArtifact(path)
+		if err != nil {
+			return nil, err
+		}
+		inputArtifacts = append(inputArtifacts, values)
+	}
+
+	overrides := map[string]interface{}{}
+	for _, override := range options.overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid value override %s, expected key=value\n", override)
+		}
+		overrides[parts[0]] = parts[1]
+	}
+
+	name := options.name
+	if name == "" {
+		name = chartName(options.helm)
+	}
+	job := bakeJob(renderer, name, options.namespace)
+	job["inputArtifacts"] = inputArtifacts
+	job["overrides"] = overrides
+	return job, nil
+}
+
+func kustomizeBakeJob(options BakeOptions) (map[string]interface{}, error) {
+	if len(options.values) > 0 || len(options.overrides) > 0 {
+		return nil, errors.New("'values' and 'set' only apply to Helm charts")
+	}
+	name := options.name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(strings.TrimSuffix(options.kustomize, "/")), ".git")
+	}
+	job := bakeJob("KUSTOMIZE", name, options.namespace)
+	job["kustomizeFilePath"] = options.kustomizeFile
+	job["inputArtifact"] = map[string]interface{}{
+		"artifact": map[string]interface{}{
+			"type":      "git/repo",
+			"name":      name,
+			"reference": options.kustomize,
+			"version":   options.branch,
+		},
+	}
+	return job, nil
+}
+
+// bakeJob is a bakeManifest operation producing a single embedded artifact with the rendered manifests.
+func bakeJob(renderer string, name string, namespace string) map[string]interface{} {
+	job := map[string]interface{}{
+		"type":             "bakeManifest",
+		"templateRenderer": renderer,
+		"outputName":       name,
+		"expectedArtifacts": []interface{}{
+			map[string]interface{}{
+				"id":          bakedArtifactId,
+				"displayName": name,
+				"matchArtifact": map[string]interface{}{
+					"type": "embedded/base64",
+					"kind": "base64",
+					"name": name,
+				},
+				"useDefaultArtifact": false,
+				"usePriorArtifact":   false,
+			},
+		},
+	}
+	if namespace != "" {
+		job["namespace"] = namespace
+	}
+	return job
+}
+
+func embeddedArtifact(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"account": embeddedArtifactAccount,
+		"artifact": map[string]interface{}{
+			"type":      "embedded/base64",
+			"name":      filepath.Base(path),
+			"reference": base64.StdEncoding.EncodeToString(content),
+		},
+	}, nil
+}
+
+// chartName strips the extension and version from a packaged chart's file name, e.g. app-1.2.0.tgz.
+func chartName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = strings.TrimSuffix(name, ".tar")
+	if i := strings.LastIndex(name, "-"); i > 0 && i+1 < len(name) && strings.IndexAny(name[i+1:i+2], "0123456789") == 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// bakedManifest decodes the rendered manifests from the artifacts produced by the bake stage.
+func bakedManifest(task map[string]interface{}) (string, error) {
+	stages, _ := task["stages"].([]interface{})
+	for _, rawStage := range stages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok || stage["type"] != "bakeManifest" {
+			continue
+		}
+		for _, key := range []string{"outputs", "context"} {
+			values, _ := stage[key].(map[string]interface{})
+			artifacts, _ := values["artifacts"].([]interface{})
+			for _, rawArtifact := range artifacts {
+				artifact, _ := rawArtifact.(map[string]interface{})
+				if artifact["type"] != "embedded/base64" {
+					continue
+				}
+				reference, _ := artifact["reference"].(string)
+				rendered, err := base64.StdEncoding.DecodeString(reference)
+				if err != nil {
+					return "", fmt.Errorf("Could not decode the baked manifest: %v\n", err)
+				}
+				return string(rendered), nil
+			}
+		}
+	}
+	return "", errors.New("the bake completed without producing a manifest")
+}
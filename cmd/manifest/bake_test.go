@@ -0,0 +1,230 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package manifest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestManifestBake_helm(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateBakeSuccess(&task)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "bake")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	chart := filepath.Join(dir, "app-1.2.0.tgz")
+	values := filepath.Join(dir, "values.yaml")
+	rendered := filepath.Join(dir, "rendered.yaml")
+	ioutil.WriteFile(chart, []byte("chart"), 0644)
+	ioutil.WriteFile(values, []byte("replicas: 2"), 0644)
+
+	currentCmd := NewBakeCmd(manifestOptions{})
+	rootCmd := getRootCmdForTest()
+	manifestCmd := NewManifestCmd(os.Stdout)
+	manifestCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(manifestCmd)
+
+	args := []string{"manifest", "bake", "-a", "app", "--helm", chart, "--values", values, "--set", "image.tag=1.2.0",
+		"--namespace", "prod", "--output-file", rendered, "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	job := task["job"].([]interface{})[0].(map[string]interface{})
+	if job["type"] != "bakeManifest" || job["templateRenderer"] != "HELM3" || job["outputName"] != "app" || job["namespace"] != "prod" {
+		t.Fatalf("Unexpected bake job: %v", job)
+	}
+	inputArtifacts := job["inputArtifacts"].([]interface{})
+	if len(inputArtifacts) != 2 {
+		t.Fatalf("Expected the chart and values file as input artifacts, got: %v", inputArtifacts)
+	}
+	valuesArtifact := inputArtifacts[1].(map[string]interface{})["artifact"].(map[string]interface{})
+	if valuesArtifact["reference"] != base64.StdEncoding.EncodeToString([]byte("replicas: 2")) {
+		t.Fatalf("Unexpected values artifact: %v", valuesArtifact)
+	}
+	if job["overrides"].(map[string]interface{})["image.tag"] != "1.2.0" {
+		t.Fatalf("Unexpected overrides: %v", job["overrides"])
+	}
+
+	content, err := ioutil.ReadFile(rendered)
+	if err != nil {
+		t.Fatalf("Could not read rendered manifests: %s", err)
+	}
+	if string(content) != bakedManifestYaml {
+		t.Fatalf("Expected rendered manifests:\n%s\ngot:\n%s", bakedManifestYaml, content)
+	}
+}
+
+func TestManifestBake_kustomize(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateBakeSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewBakeCmd(manifestOptions{})
+	rootCmd := getRootCmdForTest()
+	manifestCmd := NewManifestCmd(os.Stdout)
+	manifestCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(manifestCmd)
+
+	args := []string{"manifest", "bake", "-a", "app", "--kustomize", "https://github.com/org/deploy.git",
+		"--kustomize-file", "overlays/prod/kustomization.yaml", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	job := task["job"].([]interface{})[0].(map[string]interface{})
+	if job["templateRenderer"] != "KUSTOMIZE" || job["outputName"] != "deploy" || job["kustomizeFilePath"] != "overlays/prod/kustomization.yaml" {
+		t.Fatalf("Unexpected bake job: %v", job)
+	}
+	artifact := job["inputArtifact"].(map[string]interface{})["artifact"].(map[string]interface{})
+	if artifact["type"] != "git/repo" || artifact["version"] != "master" {
+		t.Fatalf("Unexpected repository artifact: %v", artifact)
+	}
+}
+
+func TestManifestBake_flags(t *testing.T) {
+	var task map[string]interface{}
+	ts := testGateBakeSuccess(&task)
+	defer ts.Close()
+
+	currentCmd := NewBakeCmd(manifestOptions{})
+	rootCmd := getRootCmdForTest()
+	manifestCmd := NewManifestCmd(os.Stdout)
+	manifestCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(manifestCmd)
+
+	args := []string{"manifest", "bake", "-a", "app", "--gate-endpoint", ts.URL} // Missing chart or repository.
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestManifestBake_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewBakeCmd(manifestOptions{})
+	rootCmd := getRootCmdForTest()
+	manifestCmd := NewManifestCmd(os.Stdout)
+	manifestCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(manifestCmd)
+
+	args := []string{"manifest", "bake", "-a", "app", "--kustomize", "https://github.com/org/deploy.git", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestManifestBake_chartName(t *testing.T) {
+	for path, expected := range map[string]string{
+		"app-1.2.0.tgz":           "app",
+		"charts/my-app-0.1.tgz":   "my-app",
+		"my-app.tar.gz":           "my-app",
+		"my-app-canary-2.0.0.tgz": "my-app-canary",
+		"mychart-.tgz":            "mychart-",
+		"-1.0.0.tgz":              "-1.0.0",
+	} {
+		if name := chartName(path); name != expected {
+			t.Errorf("Expected chart name %s for %s, got %s", expected, path, name)
+		}
+	}
+}
+
+// testGateBakeSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Records the submitted task and completes it with a baked manifest.
+func testGateBakeSuccess(task *map[string]interface{}) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(task)
+		fmt.Fprintln(w, `{"ref": "/tasks/id"}`)
+	}))
+	mux.Handle("/tasks/id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]interface{}{
+			"status": "SUCCEEDED",
+			"stages": []interface{}{
+				map[string]interface{}{
+					"type": "bakeManifest",
+					"outputs": map[string]interface{}{
+						"artifacts": []interface{}{
+							map[string]interface{}{
+								"type":      "embedded/base64",
+								"name":      "app",
+								"reference": base64.StdEncoding.EncodeToString([]byte(bakedManifestYaml)),
+							},
+						},
+					},
+				},
+			},
+		}
+		b, _ := json.Marshal(&result)
+		fmt.Fprintln(w, string(b))
+	}))
+	return httptest.NewServer(mux)
+}
+
+// GateServerFail spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with a 500 InternalServerError.
+func GateServerFail() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const bakedManifestYaml = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+spec:
+  replicas: 2
+`
@@ -23,6 +23,13 @@ import (
 
 // WaitForSuccessfulTask observes an Orca task to see if it completed successfully.
 func WaitForSuccessfulTask(gateClient *gateclient.GatewayClient, taskRef map[string]interface{}, maxAttempts int) error {
+	_, err := WaitForSuccessfulTaskResult(gateClient, taskRef, maxAttempts)
+	return err
+}
+
+// WaitForSuccessfulTaskResult observes an Orca task like WaitForSuccessfulTask, returning the
+// completed task so its stage outputs can be read.
+func WaitForSuccessfulTaskResult(gateClient *gateclient.GatewayClient, taskRef map[string]interface{}, maxAttempts int) (map[string]interface{}, error) {
 	id := idFromTaskRef(taskRef)
 	task, resp, err := gateClient.TaskControllerApi.GetTaskUsingGET1(gateClient.Context, id)
 
@@ -35,15 +42,15 @@ func WaitForSuccessfulTask(gateClient *gateclient.GatewayClient, taskRef map[str
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("Encountered an error saving application, status code: %d\n", resp.StatusCode)
+		return nil, fmt.Errorf("Encountered an error watching task %s, status code: %d\n", id, resp.StatusCode)
 	}
 	if !taskSucceeded(task) {
-		return fmt.Errorf("Encountered an error saving application, task output was: %v\n", task)
+		return nil, fmt.Errorf("Encountered an error running task %s, task output was: %v\n", id, task)
 	}
-	return nil
+	return task, nil
 }
 
 func taskCompleted(task map[string]interface{}) bool {
@@ -0,0 +1,185 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline_template
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Origins a planned stage or field can be annotated with.
+const (
+	originTemplate       = "template"
+	originDefault        = "default"
+	originConfig         = "config"
+	originConfigOverride = "config-override"
+	originVariable       = "variable"
+	originGenerated      = "generated"
+)
+
+// provenanceKey is the key annotations are added under in the planned pipeline and its stages.
+const provenanceKey = "_provenance"
+
+var templateVariableRegex = regexp.MustCompile(`templateVariables(?:\.(\w+)|\[['"](\w+)['"]\])`)
+
+// templateReference returns the id and tag of the Front50 stored template a
+// pipeline config uses.
+func templateReference(config map[string]interface{}) (string, string, error) {
+	template, _ := config["template"].(map[string]interface{})
+	reference, _ := template["reference"].(string)
+	if reference == "" {
+		reference, _ = template["source"].(string)
+	}
+	if !strings.HasPrefix(reference, "spinnaker://") {
+		return "", "", fmt.Errorf("Only templates stored in Spinnaker can be annotated, found template reference '%s'\n", reference)
+	}
+
+	id := strings.TrimPrefix(reference, "spinnaker://")
+	tag := ""
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		id, tag = id[:i], id[i+1:]
+	}
+	return id, tag, nil
+}
+
+// annotatePlan marks every stage and stage field of a planned pipeline with where its value came
+// from by comparing the plan with the template and the pipeline config it was planned from.
+func annotatePlan(plan, template, config map[string]interface{}) map[string]interface{} {
+	templatePipeline, _ := template["pipeline"].(map[string]interface{})
+	templateStages := stagesByRef(templatePipeline)
+	configStages := stagesByRef(config)
+	configVariables, _ := config["variables"].(map[string]interface{})
+
+	plannedRefs := map[string]bool{}
+	rawStages, _ := plan["stages"].([]interface{})
+	for _, rawStage := range rawStages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref := fmt.Sprint(stage["refId"])
+		plannedRefs[ref] = true
+		templateStage := templateStages[ref]
+		configStage := configStages[ref]
+
+		stageOrigin := originGenerated
+		switch {
+		case templateStage != nil && configStage != nil:
+			stageOrigin = originConfigOverride
+		case templateStage != nil:
+			stageOrigin = originTemplate
+		case configStage != nil:
+			stageOrigin = originConfig
+		}
+
+		fields := map[string]string{}
+		for key, value := range stage {
+			fields[key] = fieldOrigin(key, value, templateStage, configStage, configVariables)
+		}
+		stage[provenanceKey] = map[string]interface{}{
+			"origin": stageOrigin,
+			"fields": fields,
+		}
+	}
+
+	var excluded []string
+	for ref := range templateStages {
+		if !plannedRefs[ref] {
+			excluded = append(excluded, ref)
+		}
+	}
+	sort.Strings(excluded)
+
+	fields := map[string]string{}
+	for key, value := range plan {
+		if key == "stages" || key == provenanceKey {
+			continue
+		}
+		fields[key] = fieldOrigin(key, value, templatePipeline, config, configVariables)
+	}
+	plan[provenanceKey] = map[string]interface{}{
+		"fields":         fields,
+		"excludedStages": excluded,
+	}
+	return plan
+}
+
+// fieldOrigin determines where a planned value came from. Config values take precedence over
+// template values, and template values referencing template variables are attributed to the
+// variable, or to its default when the config does not set it.
+func fieldOrigin(key string, value interface{}, templateParent, configParent, configVariables map[string]interface{}) string {
+	if configValue, exists := configParent[key]; exists && reflect.DeepEqual(configValue, value) {
+		if _, inTemplate := templateParent[key]; inTemplate {
+			return originConfigOverride
+		}
+		return originConfig
+	}
+
+	templateValue, exists := templateParent[key]
+	if !exists {
+		return originGenerated
+	}
+	if reflect.DeepEqual(templateValue, value) {
+		return originTemplate
+	}
+
+	if variables := referencedVariables(templateValue); len(variables) > 0 {
+		for _, variable := range variables {
+			if _, set := configVariables[variable]; !set {
+				return fmt.Sprintf("%s:%s", originDefault, variable)
+			}
+		}
+		return fmt.Sprintf("%s:%s", originVariable, strings.Join(variables, ","))
+	}
+	// Changed while planning, e.g. dependencies rewired by stage injection.
+	return originGenerated
+}
+
+// referencedVariables returns the template variables referenced by SpEL in a template value.
+func referencedVariables(value interface{}) []string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var variables []string
+	for _, match := range templateVariableRegex.FindAllStringSubmatch(string(raw), -1) {
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+		if !seen[name] {
+			seen[name] = true
+			variables = append(variables, name)
+		}
+	}
+	return variables
+}
+
+func stagesByRef(pipeline map[string]interface{}) map[string]map[string]interface{} {
+	stages := map[string]map[string]interface{}{}
+	rawStages, _ := pipeline["stages"].([]interface{})
+	for _, rawStage := range rawStages {
+		if stage, ok := rawStage.(map[string]interface{}); ok && stage["refId"] != nil {
+			stages[fmt.Sprint(stage["refId"])] = stage
+		}
+	}
+	return stages
+}
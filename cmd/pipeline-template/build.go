@@ -0,0 +1,366 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline_template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+	"gopkg.in/yaml.v2"
+)
+
+type BuildOptions struct {
+	*pipelineTemplateOptions
+	dir  string
+	save bool
+	tag  string
+}
+
+var (
+	buildTemplateShort = "Build a pipeline template from a directory of template files"
+	buildTemplateLong  = `Assembles a v2 pipeline template from separate YAML or JSON files:
+
+  template.yml     id, schema and other top level keys (required)
+  metadata.yml     the template metadata
+  variables.yml    the list of template variables
+  pipeline.yml     the pipeline, apart from its stages
+  stages/*.yml     stage fragments, each a stage or a list of stages, appended in file name order
+
+Any value of the form {"$ref": "path"} is replaced by the contents of that file, resolved
+relative to the file containing it. A $ref in a list that resolves to a list is spliced in.
+
+Template variables referenced by the stages must be declared in the variables. The
+assembled template is printed, or saved to Front50 with --save.`
+	buildTemplateExample = `spin pipeline-template build --dir template/
+spin pipeline-template build --dir template/ --save --tag stable`
+)
+
+const refKey = "$ref"
+
+func NewBuildCmd(pipelineTemplateOptions pipelineTemplateOptions) *cobra.Command {
+	options := BuildOptions{
+		pipelineTemplateOptions: &pipelineTemplateOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "build",
+		Short:   buildTemplateShort,
+		Long:    buildTemplateLong,
+		Example: buildTemplateExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return buildPipelineTemplate(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&options.dir, "dir", "", "directory containing the template files")
+	cmd.PersistentFlags().BoolVar(&options.save, "save", false, "save the assembled template to Front50")
+	cmd.PersistentFlags().StringVar(&options.tag, "tag", "",
+		"(optional) specific tag to tag pipeline template with when saving")
+
+	return cmd
+}
+
+func buildPipelineTemplate(cmd *cobra.Command, options BuildOptions) error {
+	if options.dir == "" {
+		return errors.New("Required flag --dir not set")
+	}
+	if options.tag != "" && !options.save {
+		return errors.New("--tag is only used with --save")
+	}
+
+	template, err := assembleTemplate(options.dir)
+	if err != nil {
+		return err
+	}
+	if err := validateTemplateVariables(template); err != nil {
+		return err
+	}
+
+	if !options.save {
+		if _, err := gateclient.LoadConfig(cmd.InheritedFlags()); err != nil {
+			return err
+		}
+		util.UI.JsonOutput(template, util.UI.OutputFormat)
+		return nil
+	}
+
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+	return publishPipelineTemplate(gateClient, template, options.tag)
+}
+
+// assembleTemplate builds the single template document from the files in dir.
+func assembleTemplate(dir string) (map[string]interface{}, error) {
+	root := templatePart(dir, "template")
+	if root == "" {
+		return nil, fmt.Errorf("No template.yml, template.yaml or template.json found in %s\n", dir)
+	}
+	value, err := readTemplateFragment(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	template, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Could not parse %s: not a mapping\n", root)
+	}
+	if _, exists := template["schema"]; !exists {
+		template["schema"] = "v2"
+	}
+
+	for _, key := range []string{"metadata", "variables", "pipeline"} {
+		path := templatePart(dir, key)
+		if path == "" {
+			continue
+		}
+		if _, exists := template[key]; exists {
+			return nil, fmt.Errorf("%s is set in both %s and %s\n", key, root, path)
+		}
+		if template[key], err = readTemplateFragment(path, nil); err != nil {
+			return nil, err
+		}
+	}
+	if variables, exists := template["variables"]; exists {
+		if _, ok := variables.([]interface{}); !ok {
+			return nil, errors.New("Template variables must be a list")
+		}
+	}
+
+	stages, err := readStageFragments(filepath.Join(dir, "stages"))
+	if err != nil {
+		return nil, err
+	}
+	if len(stages) > 0 {
+		if template["pipeline"] == nil {
+			template["pipeline"] = map[string]interface{}{}
+		}
+		pipeline, ok := template["pipeline"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Template pipeline must be a mapping")
+		}
+		existing, _ := pipeline["stages"].([]interface{})
+		pipeline["stages"] = append(existing, stages...)
+	}
+	return template, nil
+}
+
+// templatePart returns the YAML or JSON file for a part of the template, or "" if there is none.
+func templatePart(dir, name string) string {
+	for _, ext := range []string{".yml", ".yaml", ".json"} {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readStageFragments reads the stages from each YAML or JSON file in dir, ordered by file name.
+func readStageFragments(dir string) ([]interface{}, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stages []interface{}
+	for _, file := range files {
+		if file.IsDir() || !isTemplateFile(file.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		fragment, err := readTemplateFragment(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		switch f := fragment.(type) {
+		case []interface{}:
+			stages = append(stages, f...)
+		case map[string]interface{}:
+			stages = append(stages, f)
+		default:
+			return nil, fmt.Errorf("Could not parse %s: expected a stage or a list of stages\n", path)
+		}
+	}
+	return stages, nil
+}
+
+func isTemplateFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml", ".json":
+		return true
+	}
+	return false
+}
+
+// readTemplateFragment reads a YAML or JSON file and resolves the $ref includes in it.
+// including is the chain of files currently being read, used to detect cycles.
+func readTemplateFragment(path string, including []string) (interface{}, error) {
+	for _, included := range including {
+		if included == path {
+			return nil, fmt.Errorf("Circular $ref: %s -> %s\n", strings.Join(including, " -> "), path)
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yml" || ext == ".yaml" {
+		err = yaml.Unmarshal(content, &value)
+		value = normalizeYaml(value)
+	} else {
+		err = json.Unmarshal(content, &value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %v\n", path, err)
+	}
+	return resolveRefs(value, path, append(including, path))
+}
+
+// resolveRefs replaces each {"$ref": "path"} in value with the contents of that file.
+func resolveRefs(value interface{}, path string, including []string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := includeRef(v); ok {
+			return readTemplateFragment(filepath.Join(filepath.Dir(path), ref), including)
+		}
+		for key, child := range v {
+			resolved, err := resolveRefs(child, path, including)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			_, isRef := includeRef(child)
+			r, err := resolveRefs(child, path, including)
+			if err != nil {
+				return nil, err
+			}
+			if list, ok := r.([]interface{}); ok && isRef {
+				resolved = append(resolved, list...)
+			} else {
+				resolved = append(resolved, r)
+			}
+		}
+		return resolved, nil
+	default:
+		return v, nil
+	}
+}
+
+// includeRef returns the file a {"$ref": "path"} value includes.
+func includeRef(value interface{}) (string, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	ref, ok := m[refKey].(string)
+	return ref, ok
+}
+
+// normalizeYaml converts the maps decoded from YAML to the string keyed maps JSON uses.
+func normalizeYaml(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, child := range v {
+			m[fmt.Sprint(key)] = normalizeYaml(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalizeYaml(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// validateTemplateVariables checks each template variable the stages reference is declared,
+// and warns about declared variables nothing uses.
+func validateTemplateVariables(template map[string]interface{}) error {
+	declared := map[string]bool{}
+	variables, _ := template["variables"].([]interface{})
+	for i, rawVariable := range variables {
+		variable, _ := rawVariable.(map[string]interface{})
+		name, _ := variable["name"].(string)
+		if name == "" {
+			return fmt.Errorf("Template variable %d has no name\n", i)
+		}
+		declared[name] = true
+	}
+
+	used := map[string]bool{}
+	var undeclared []string
+	seenRefIds := map[string]bool{}
+	pipeline, _ := template["pipeline"].(map[string]interface{})
+	stages, _ := pipeline["stages"].([]interface{})
+	for i, rawStage := range stages {
+		stage, _ := rawStage.(map[string]interface{})
+		stageName := fmt.Sprintf("stage %d", i)
+		if refId, ok := stage["refId"]; ok {
+			stageName = fmt.Sprint(refId)
+			if seenRefIds[stageName] {
+				return fmt.Errorf("Duplicate stage refId %s\n", stageName)
+			}
+			seenRefIds[stageName] = true
+		}
+		for _, name := range referencedVariables(stage) {
+			used[name] = true
+			if !declared[name] {
+				undeclared = append(undeclared, fmt.Sprintf("%s (in %s)", name, stageName))
+			}
+		}
+	}
+
+	// Variables may also be used outside the stages, e.g. in triggers.
+	for _, name := range referencedVariables(pipeline) {
+		used[name] = true
+	}
+	var unused []string
+	for name := range declared {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		util.UI.Warn(fmt.Sprintf("Template variable %s is declared but not used by the pipeline\n", name))
+	}
+
+	if len(undeclared) > 0 {
+		return fmt.Errorf("Stages reference undeclared template variables: %s\n", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline_template
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipelineTemplateBuild_basic(t *testing.T) {
+	dir := tempTemplateDir(t, testTemplateFiles)
+	defer os.RemoveAll(dir)
+
+	args := []string{"pipeline-template", "build", "--dir", dir}
+	currentCmd := NewBuildCmd(pipelineTemplateOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineTemplateCmd := NewPipelineTemplateCmd(os.Stdout)
+	pipelineTemplateCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineTemplateCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineTemplateBuild_save(t *testing.T) {
+	ts := gateServerCreateSuccess()
+	defer ts.Close()
+
+	dir := tempTemplateDir(t, testTemplateFiles)
+	defer os.RemoveAll(dir)
+
+	args := []string{"pipeline-template", "build", "--dir", dir, "--save", "--tag", "stable", "--gate-endpoint", ts.URL}
+	currentCmd := NewBuildCmd(pipelineTemplateOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineTemplateCmd := NewPipelineTemplateCmd(os.Stdout)
+	pipelineTemplateCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineTemplateCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineTemplateBuild_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	dir := tempTemplateDir(t, testTemplateFiles)
+	defer os.RemoveAll(dir)
+
+	args := []string{"pipeline-template", "build", "--dir", dir, "--save", "--gate-endpoint", ts.URL}
+	currentCmd := NewBuildCmd(pipelineTemplateOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineTemplateCmd := NewPipelineTemplateCmd(os.Stdout)
+	pipelineTemplateCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineTemplateCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestPipelineTemplateBuild_flags(t *testing.T) {
+	args := []string{"pipeline-template", "build"} // Missing --dir.
+	currentCmd := NewBuildCmd(pipelineTemplateOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineTemplateCmd := NewPipelineTemplateCmd(os.Stdout)
+	pipelineTemplateCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineTemplateCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestPipelineTemplateBuild_assemble(t *testing.T) {
+	dir := tempTemplateDir(t, testTemplateFiles)
+	defer os.RemoveAll(dir)
+
+	template, err := assembleTemplate(dir)
+	if err != nil {
+		t.Fatalf("Failed to assemble template: %s", err)
+	}
+	if template["schema"] != "v2" {
+		t.Errorf("Expected schema v2, got %v", template["schema"])
+	}
+	metadata := template["metadata"].(map[string]interface{})
+	if metadata["name"] != "Default Bake and Tag" {
+		t.Errorf("Expected metadata from metadata.yml, got %v", metadata)
+	}
+	pipeline := template["pipeline"].(map[string]interface{})
+	if pipeline["limitConcurrent"] != true {
+		t.Errorf("Expected pipeline from pipeline.json, got %v", pipeline)
+	}
+
+	var refIds []string
+	for _, stage := range pipeline["stages"].([]interface{}) {
+		refIds = append(refIds, stage.(map[string]interface{})["refId"].(string))
+	}
+	expected := "bake,wait1,wait2,tag"
+	if strings.Join(refIds, ",") != expected {
+		t.Fatalf("Expected stages %s, got %s", expected, strings.Join(refIds, ","))
+	}
+	tag := pipeline["stages"].([]interface{})[3].(map[string]interface{})
+	if tags := tag["tags"].([]interface{}); len(tags) != 1 {
+		t.Errorf("Expected tags included from common/tags.yml, got %v", tag["tags"])
+	}
+
+	if err := validateTemplateVariables(template); err != nil {
+		t.Fatalf("Expected template variables to validate, got: %s", err)
+	}
+}
+
+func TestPipelineTemplateBuild_emptyLists(t *testing.T) {
+	dir := tempTemplateDir(t, testTemplateFiles)
+	defer os.RemoveAll(dir)
+
+	template, err := assembleTemplate(dir)
+	if err != nil {
+		t.Fatalf("Failed to assemble template: %s", err)
+	}
+	built, err := json.Marshal(template["pipeline"])
+	if err != nil {
+		t.Fatalf("Failed to marshal pipeline: %s", err)
+	}
+	for _, expected := range []string{`"notifications":[]`, `"triggers":[]`, `"requisiteStageRefIds":[]`} {
+		if !strings.Contains(string(built), expected) {
+			t.Errorf("Expected %s in the built pipeline, got: %s", expected, built)
+		}
+	}
+}
+
+func TestPipelineTemplateBuild_undeclaredVariable(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testTemplateFiles {
+		files[name] = content
+	}
+	files["stages/03-notify.yml"] = `
+refId: notify
+type: wait
+waitTime: ${ templateVariables['notifyDelay'] }
+`
+	dir := tempTemplateDir(t, files)
+	defer os.RemoveAll(dir)
+
+	template, err := assembleTemplate(dir)
+	if err != nil {
+		t.Fatalf("Failed to assemble template: %s", err)
+	}
+	err = validateTemplateVariables(template)
+	if err == nil || !strings.Contains(err.Error(), "notifyDelay (in notify)") {
+		t.Fatalf("Expected undeclared variable notifyDelay to be reported, got: %v", err)
+	}
+}
+
+func TestPipelineTemplateBuild_circularRef(t *testing.T) {
+	dir := tempTemplateDir(t, map[string]string{
+		"template.yml": "id: loop\nmetadata:\n  $ref: a.yml\n",
+		"a.yml":        "nested:\n  $ref: b.yml\n",
+		"b.yml":        "$ref: a.yml\n",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := assembleTemplate(dir)
+	if err == nil || !strings.Contains(err.Error(), "Circular $ref") {
+		t.Fatalf("Expected a circular $ref error, got: %v", err)
+	}
+}
+
+func TestPipelineTemplateBuild_missingRoot(t *testing.T) {
+	dir := tempTemplateDir(t, map[string]string{"metadata.yml": "name: orphan\n"})
+	defer os.RemoveAll(dir)
+
+	if _, err := assembleTemplate(dir); err == nil {
+		t.Fatalf("Expected an error without template.yml")
+	}
+}
+
+// tempTemplateDir writes the files, keyed by path relative to the directory, to a temp directory.
+func tempTemplateDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("" /* /tmp dir. */, "pipeline-template")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Could not create temp dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(strings.TrimSpace(content)+"\n"), 0644); err != nil {
+			t.Fatalf("Could not write temp file: %s", err)
+		}
+	}
+	return dir
+}
+
+var testTemplateFiles = map[string]string{
+	"template.yml": `
+id: testSpelTemplate
+protect: false
+`,
+	"metadata.yml": `
+name: Default Bake and Tag
+description: A generic application bake and tag pipeline.
+owner: example@example.com
+scopes:
+  - global
+`,
+	"variables.yml": `
+- name: waitTime
+  type: int
+  defaultValue: 42
+  description: The time a wait stage shall pauseth
+- name: tagName
+  type: string
+  defaultValue: latest
+`,
+	"pipeline.json": `
+{
+  "keepWaitingPipelines": false,
+  "limitConcurrent": true,
+  "notifications": [],
+  "triggers": [],
+  "stages": [
+    {
+      "refId": "bake",
+      "requisiteStageRefIds": [],
+      "type": "bake"
+    }
+  ]
+}
+`,
+	"stages/01-waits.yml": `
+$ref: ../common/waits.yml
+`,
+	"stages/02-tag.json": `
+{
+  "refId": "tag",
+  "requisiteStageRefIds": ["wait2"],
+  "type": "upsertImageTags",
+  "tags": {"$ref": "../common/tags.yml"}
+}
+`,
+	"stages/README.md": `
+Stage fragments, appended in file name order.
+`,
+	"common/waits.yml": `
+- refId: wait1
+  requisiteStageRefIds: [bake]
+  type: wait
+  waitTime: ${ templateVariables.waitTime }
+- $ref: wait2.yml
+`,
+	"common/wait2.yml": `
+refId: wait2
+requisiteStageRefIds: [wait1]
+type: wait
+waitTime: 5
+`,
+	"common/tags.yml": `
+- name: release
+  value: ${ templateVariables.tagName }
+`,
+}
@@ -45,6 +45,7 @@ func NewPipelineTemplateCmd(out io.Writer) *cobra.Command {
 	cmd.AddCommand(NewDeleteCmd(options))
 	cmd.AddCommand(NewPlanCmd(options))
 	cmd.AddCommand(NewUseCmd(options))
+	cmd.AddCommand(NewBuildCmd(options))
 
 	return cmd
 }
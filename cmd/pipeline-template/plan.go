@@ -26,6 +26,7 @@ import (
 type PlanOptions struct {
 	*pipelineTemplateOptions
 	configPath string
+	annotate   bool
 }
 
 const (
@@ -47,6 +48,7 @@ func NewPlanCmd(pipelineTemplateOptions pipelineTemplateOptions) *cobra.Command
 	}
 
 	cmd.PersistentFlags().StringVarP(&options.configPath, "file", "f", "", "path to the pipeline template config file")
+	cmd.PersistentFlags().BoolVar(&options.annotate, "annotate", false, "mark every stage and field of the plan with its origin")
 
 	return cmd
 }
@@ -77,6 +79,27 @@ func planPipelineTemplate(cmd *cobra.Command, options PlanOptions) error {
 			resp.StatusCode)
 	}
 
+	if options.annotate {
+		id, tag, err := templateReference(configJson)
+		if err != nil {
+			return err
+		}
+		queryParams := map[string]interface{}{}
+		if tag != "" {
+			queryParams["tag"] = tag
+		}
+		template, resp, err := gateClient.V2PipelineTemplatesControllerApi.GetUsingGET2(gateClient.Context, id, queryParams)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error getting pipeline template with id %s, status code: %d\n",
+				id,
+				resp.StatusCode)
+		}
+		successPayload = annotatePlan(successPayload, template, configJson)
+	}
+
 	util.UI.JsonOutput(successPayload, util.UI.OutputFormat)
 	return nil
 }
@@ -15,6 +15,7 @@
 package pipeline_template
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -121,6 +122,75 @@ func TestPipelineTemplatePlan_flags(t *testing.T) {
 	}
 }
 
+func TestPipelineTemplatePlan_annotate(t *testing.T) {
+	ts := gateServerPlanSuccess()
+	defer ts.Close()
+
+	tempFile := tempPipelineTemplateFile(testPlanConfig)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline template file.")
+	}
+	defer os.Remove(tempFile.Name())
+	args := []string{"pipeline-template", "plan", "--annotate", "--file", tempFile.Name(), "--gate-endpoint", ts.URL}
+
+	currentCmd := NewPlanCmd(pipelineTemplateOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineTemplateCmd := NewPipelineTemplateCmd(os.Stdout)
+	pipelineTemplateCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineTemplateCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineTemplatePlan_annotateOrigins(t *testing.T) {
+	var plan, template, config map[string]interface{}
+	json.Unmarshal([]byte(testPipelineTemplatePlanResp), &plan)
+	json.Unmarshal([]byte(testPipelineTemplateJsonStr), &template)
+	json.Unmarshal([]byte(testPlanConfig), &config)
+
+	annotated := annotatePlan(plan, template, config)
+	stages := annotated["stages"].([]interface{})
+	expected := []struct {
+		origin string
+		fields map[string]string
+	}{
+		{originConfig, map[string]string{"waitTime": originConfig, "notifications": originGenerated}},
+		{originTemplate, map[string]string{"waitTime": "variable:waitTime", "name": originTemplate, "requisiteStageRefIds": originGenerated}},
+		{originConfig, map[string]string{"requisiteStageRefIds": originConfig}},
+	}
+	for i, e := range expected {
+		provenance := stages[i].(map[string]interface{})[provenanceKey].(map[string]interface{})
+		if provenance["origin"] != e.origin {
+			t.Errorf("Expected stage %d origin %s, got %v", i, e.origin, provenance["origin"])
+		}
+		fields := provenance["fields"].(map[string]string)
+		for field, origin := range e.fields {
+			if fields[field] != origin {
+				t.Errorf("Expected stage %d field %s origin %s, got %s", i, field, origin, fields[field])
+			}
+		}
+	}
+}
+
+func TestPipelineTemplatePlan_annotateDefault(t *testing.T) {
+	var plan, template, config map[string]interface{}
+	json.Unmarshal([]byte(testPipelineTemplatePlanResp), &plan)
+	json.Unmarshal([]byte(testPipelineTemplateJsonStr), &template)
+	json.Unmarshal([]byte(testPlanConfig), &config)
+	delete(config, "variables")
+
+	annotated := annotatePlan(plan, template, config)
+	stage := annotated["stages"].([]interface{})[1].(map[string]interface{})
+	fields := stage[provenanceKey].(map[string]interface{})["fields"].(map[string]string)
+	if fields["waitTime"] != "default:waitTime" {
+		t.Fatalf("Expected waitTime to come from the variable default, got %s", fields["waitTime"])
+	}
+}
+
 // gateServerUpdateSuccess spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with 404 NotFound to indicate a pipeline template doesn't exist,
 // and Accepts POST calls.
@@ -133,6 +203,9 @@ func gateServerPlanSuccess() *httptest.Server {
 			w.WriteHeader(http.StatusNotFound)
 		}
 	}))
+	mux.Handle("/v2/pipelineTemplates/newSpelTemplate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(testPipelineTemplateJsonStr))
+	}))
 	return httptest.NewServer(mux)
 }
 
@@ -67,6 +67,11 @@ func savePipelineTemplate(cmd *cobra.Command, options SaveOptions) error {
 		return err
 	}
 
+	return publishPipelineTemplate(gateClient, templateJson, options.tag)
+}
+
+// publishPipelineTemplate creates or updates the template in Front50 under the given tag.
+func publishPipelineTemplate(gateClient *gateclient.GatewayClient, templateJson map[string]interface{}, tag string) error {
 	valid := true
 	if _, exists := templateJson["id"]; !exists {
 		util.UI.Error("Required pipeline template key 'id' missing...\n")
@@ -83,8 +88,8 @@ func savePipelineTemplate(cmd *cobra.Command, options SaveOptions) error {
 	templateId := templateJson["id"].(string)
 
 	queryParams := map[string]interface{}{}
-	if options.tag != "" {
-		queryParams["tag"] = options.tag
+	if tag != "" {
+		queryParams["tag"] = tag
 	}
 
 	_, resp, queryErr := gateClient.V2PipelineTemplatesControllerApi.GetUsingGET2(gateClient.Context, templateId, queryParams)
@@ -0,0 +1,218 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+// accountKeys are the stage keys naming the cloud account a stage operates on. Deploy stages
+// nest them in their cluster definitions, so stages are searched recursively.
+var accountKeys = map[string]bool{
+	"account":     true,
+	"credentials": true,
+}
+
+// stageAccounts returns the accounts referenced by the pipeline's stages, mapped to the names
+// of the stages referencing them. Accounts resolved at runtime through SpEL are skipped.
+func stageAccounts(pipeline map[string]interface{}) map[string][]string {
+	accounts := map[string][]string{}
+	stages, _ := pipeline["stages"].([]interface{})
+	for _, rawStage := range stages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := stage["name"].(string)
+		if name == "" {
+			name = stageRef(stage)
+		}
+		found := map[string]bool{}
+		collectAccounts(stage, found)
+		for account := range found {
+			accounts[account] = append(accounts[account], name)
+		}
+	}
+	return accounts
+}
+
+func collectAccounts(value interface{}, found map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if account, ok := child.(string); ok && accountKeys[key] {
+				if account != "" && !strings.Contains(account, "${") {
+					found[account] = true
+				}
+				continue
+			}
+			collectAccounts(child, found)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectAccounts(child, found)
+		}
+	}
+}
+
+// checkStageAccounts compares the accounts referenced by the pipeline's stages with the accounts
+// the pipeline will run with: those of each trigger's service account, or the current user's
+// when no trigger runs as a service account. Inaccessible accounts are reported as warnings,
+// or fail the check when strict is set. Otherwise they only surface as "Access denied" stage
+// failures in the middle of an execution.
+func checkStageAccounts(gateClient *gateclient.GatewayClient, pipeline map[string]interface{}, strict bool) error {
+	runAsUsers := map[string]bool{}
+	for _, trigger := range pipelineTriggers(pipeline) {
+		if runAs, _ := trigger["runAsUser"].(string); runAs != "" {
+			runAsUsers[runAs] = true
+		}
+	}
+	return checkAccounts(gateClient, stageAccounts(pipeline), runAsUsers, strict)
+}
+
+// checkAccounts reports the accounts, mapped to the stages referencing them, that the service
+// accounts in runAsUsers, or the current user when there are none, cannot access.
+func checkAccounts(gateClient *gateclient.GatewayClient, accounts map[string][]string, runAsUsers map[string]bool, strict bool) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	var identities []string
+	allowed := map[string]map[string]bool{}
+	if len(runAsUsers) == 0 {
+		user, resp, err := gateClient.AuthControllerApi.UserUsingGET(gateClient.Context)
+		if err == io.EOF || (err == nil && user.Username == "" && len(user.AllowedAccounts) == 0) {
+			// Gate has no user to check when authentication or Fiat is disabled, and then
+			// doesn't restrict accounts either.
+			return nil
+		}
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Encountered an error querying the current user, status code: %d\n", resp.StatusCode)
+		}
+		if err != nil {
+			return accountCheckFailed(err, strict)
+		}
+		identity := user.Username
+		if identity == "" {
+			identity = "current user"
+		}
+		identities = append(identities, identity)
+		allowed[identity] = stringSet(user.AllowedAccounts)
+	} else {
+		serviceAccountAllowed, err := serviceAccountAllowedAccounts(gateClient, runAsUsers)
+		if err != nil {
+			return accountCheckFailed(err, strict)
+		}
+		for runAs := range runAsUsers {
+			identities = append(identities, runAs)
+		}
+		sort.Strings(identities)
+		allowed = serviceAccountAllowed
+	}
+
+	inaccessible := false
+	for _, identity := range identities {
+		// Missing entries are service accounts whose roles Gate does not expose.
+		identityAllowed, known := allowed[identity]
+		if !known {
+			continue
+		}
+		var denied []string
+		for account := range accounts {
+			if !identityAllowed[account] {
+				denied = append(denied, account)
+			}
+		}
+		if len(denied) == 0 {
+			continue
+		}
+		inaccessible = true
+		sort.Strings(denied)
+		report := util.UI.Warn
+		if strict {
+			report = util.UI.Error
+		}
+		message := fmt.Sprintf("Pipeline references accounts that %s cannot access:", identity)
+		for _, account := range denied {
+			message += fmt.Sprintf("\n  %s (stages: %s)", account, strings.Join(accounts[account], ", "))
+		}
+		report(message + "\n")
+	}
+
+	if inaccessible && strict {
+		return fmt.Errorf("Pipeline references inaccessible accounts\n")
+	}
+	return nil
+}
+
+// serviceAccountAllowedAccounts resolves the accounts each service account may deploy to from
+// its roles and the accounts' WRITE permissions.
+func serviceAccountAllowedAccounts(gateClient *gateclient.GatewayClient, serviceAccounts map[string]bool) (map[string]map[string]bool, error) {
+	rawServiceAccounts, resp, err := gateClient.AuthControllerApi.GetServiceAccountsUsingGET(gateClient.Context)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing service accounts, status code: %d\n", resp.StatusCode)
+	}
+	accountRoles := serviceAccountRoles(rawServiceAccounts)
+
+	cloudAccounts, resp, err := gateClient.CredentialsControllerApi.GetAccountsUsingGET(gateClient.Context, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing accounts, status code: %d\n", resp.StatusCode)
+	}
+
+	allowed := map[string]map[string]bool{}
+	for serviceAccount := range serviceAccounts {
+		roles, exists := accountRoles[serviceAccount]
+		if !exists || roles == nil {
+			continue
+		}
+		allowed[serviceAccount] = map[string]bool{}
+		for _, account := range cloudAccounts {
+			writeRoles := account.Permissions["WRITE"]
+			if len(writeRoles) == 0 || hasAnyRole(roles, writeRoles) {
+				allowed[serviceAccount][account.Name] = true
+			}
+		}
+	}
+	return allowed, nil
+}
+
+func accountCheckFailed(err error, strict bool) error {
+	if strict {
+		return err
+	}
+	util.UI.Warn(fmt.Sprintf("Skipping account permission check: %v\n", err))
+	return nil
+}
+
+func stringSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
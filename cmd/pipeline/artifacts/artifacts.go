@@ -0,0 +1,44 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package artifacts
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+type artifactsOptions struct{}
+
+var (
+	artifactsShort   = ""
+	artifactsLong    = ""
+	artifactsExample = ""
+)
+
+func NewArtifactsCmd(out io.Writer) *cobra.Command {
+	options := artifactsOptions{}
+	cmd := &cobra.Command{
+		Use:     "artifacts",
+		Aliases: []string{"artifact", "ar"},
+		Short:   artifactsShort,
+		Long:    artifactsLong,
+		Example: artifactsExample,
+	}
+
+	// create subcommands
+	cmd.AddCommand(NewResolveCmd(options))
+	return cmd
+}
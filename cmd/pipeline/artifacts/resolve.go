@@ -0,0 +1,338 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type ResolveOptions struct {
+	*artifactsOptions
+	application        string
+	name               string
+	pipelineFile       string
+	artifactsFile      string
+	priorArtifactsFile string
+}
+
+var (
+	resolveArtifactsShort   = "Show how trigger artifacts would bind to a pipeline's expected artifacts"
+	resolveArtifactsLong    = "Match the artifacts in an artifacts file against the expected artifacts of a pipeline the way Orca does when the pipeline is triggered manually, without starting it, and show which artifact each expected artifact binds to, whether from the trigger, the prior execution or its default. Fails with Orca's error when an expected artifact can't be resolved"
+	resolveArtifactsExample = `usage: spin pipeline artifacts resolve [options]
+	spin pipeline artifacts resolve -a app -n deploy --artifacts-file trigger-artifacts.json
+	spin pipeline artifacts resolve --file pipeline.json --artifacts-file trigger-artifacts.json --prior-artifacts-file prior.json`
+)
+
+const (
+	bindingTrigger = "trigger"
+	bindingPrior   = "prior execution"
+	bindingDefault = "default"
+)
+
+// matchFields are the fields of an expected artifact's match artifact that Orca compares,
+// as regular expressions that must match the whole value.
+var matchFields = []string{"type", "name", "version", "location", "reference"}
+
+// artifactBinding is the artifact an expected artifact resolves to, or why it doesn't.
+type artifactBinding struct {
+	ExpectedArtifactId string                 `json:"expectedArtifactId"`
+	DisplayName        string                 `json:"displayName,omitempty"`
+	Source             string                 `json:"source,omitempty"`
+	Artifact           map[string]interface{} `json:"artifact,omitempty"`
+	Error              string                 `json:"error,omitempty"`
+}
+
+func NewResolveCmd(artifactsOptions artifactsOptions) *cobra.Command {
+	options := ResolveOptions{
+		artifactsOptions: &artifactsOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "resolve",
+		Short:   resolveArtifactsShort,
+		Long:    resolveArtifactsLong,
+		Example: resolveArtifactsExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resolveArtifacts(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.application, "application", "a", "", "Spinnaker application the pipeline lives in")
+	cmd.PersistentFlags().StringVarP(&options.name, "name", "n", "", "name of the pipeline")
+	cmd.PersistentFlags().StringVarP(&options.pipelineFile, "file", "f", "", "(optional) path to the pipeline JSON, instead of fetching the pipeline from Gate")
+	cmd.PersistentFlags().StringVarP(&options.artifactsFile, "artifacts-file", "t", "", "file to load the trigger artifacts from, as passed to pipeline execute")
+	cmd.PersistentFlags().StringVar(&options.priorArtifactsFile, "prior-artifacts-file", "", "(optional) file to load the prior execution's artifacts from, defaults to those of the pipeline's latest execution in Gate")
+
+	return cmd
+}
+
+func resolveArtifacts(cmd *cobra.Command, options ResolveOptions) error {
+	var gateClient *gateclient.GatewayClient
+	var pipeline map[string]interface{}
+	var err error
+	if options.pipelineFile != "" {
+		if _, err = gateclient.LoadConfig(cmd.InheritedFlags()); err != nil {
+			return err
+		}
+		pipeline, err = util.ParseJsonFromFile(options.pipelineFile, false)
+		if err != nil {
+			return fmt.Errorf("Could not parse supplied pipeline: %v.\n", err)
+		}
+	} else {
+		if options.application == "" || options.name == "" {
+			return errors.New("one of required parameters 'application' or 'name' not set")
+		}
+		gateClient, err = gateclient.NewGateClient(cmd.InheritedFlags())
+		if err != nil {
+			return err
+		}
+		var resp *http.Response
+		pipeline, resp, err = gateClient.ApplicationControllerApi.GetPipelineConfigUsingGET(gateClient.Context, options.application, options.name)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error querying pipeline, status code: %d\n", resp.StatusCode)
+		}
+	}
+
+	received, err := readArtifacts(options.artifactsFile)
+	if err != nil {
+		return fmt.Errorf("Could not parse supplied artifacts: %v.\n", err)
+	}
+
+	// Like Orca, only look up the prior execution when an expected artifact asks for it.
+	var prior []interface{}
+	priorLoaded := false
+	priorArtifacts := func() ([]interface{}, error) {
+		if priorLoaded {
+			return prior, nil
+		}
+		priorLoaded = true
+		var err error
+		switch {
+		case options.priorArtifactsFile != "":
+			if prior, err = readArtifacts(options.priorArtifactsFile); err != nil {
+				return nil, fmt.Errorf("Could not parse supplied prior artifacts: %v.\n", err)
+			}
+		case gateClient != nil:
+			prior, err = latestExecutionArtifacts(gateClient, pipeline)
+		}
+		return prior, err
+	}
+
+	expected, _ := pipeline["expectedArtifacts"].([]interface{})
+	bindings, err := bindExpectedArtifacts(expected, received, priorArtifacts)
+	if err != nil {
+		return err
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(bindings, util.UI.OutputFormat)
+	} else {
+		for _, line := range renderBindings(bindings, received) {
+			util.UI.Output(line)
+		}
+	}
+
+	// Orca stops at the first expected artifact it can't resolve.
+	for _, binding := range bindings {
+		if binding.Error != "" {
+			return errors.New(binding.Error)
+		}
+	}
+	return nil
+}
+
+// readArtifacts reads the artifacts list of an artifacts file, as pipeline execute takes it.
+func readArtifacts(path string) ([]interface{}, error) {
+	artifactsFile, err := util.ParseJsonFromFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, _ := artifactsFile["artifacts"].([]interface{})
+	return artifacts, nil
+}
+
+// latestExecutionArtifacts returns the artifacts of the pipeline's latest execution in the order
+// Orca searches them for prior artifacts: those emitted by later stages first, then the trigger's.
+func latestExecutionArtifacts(gateClient *gateclient.GatewayClient, pipeline map[string]interface{}) ([]interface{}, error) {
+	id, _ := pipeline["id"].(string)
+	if id == "" {
+		return nil, nil
+	}
+	executions, resp, err := gateClient.ExecutionsControllerApi.GetLatestExecutionsByConfigIdsUsingGET(gateClient.Context,
+		map[string]interface{}{"pipelineConfigIds": id, "limit": int32(1)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error getting the latest execution, status code: %d\n", resp.StatusCode)
+	}
+	if len(executions) == 0 {
+		return nil, nil
+	}
+	execution, _ := executions[0].(map[string]interface{})
+
+	var artifacts []interface{}
+	stages, _ := execution["stages"].([]interface{})
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage, _ := stages[i].(map[string]interface{})
+		outputs, _ := stage["outputs"].(map[string]interface{})
+		emitted, _ := outputs["artifacts"].([]interface{})
+		for j := len(emitted) - 1; j >= 0; j-- {
+			artifacts = append(artifacts, emitted[j])
+		}
+	}
+	trigger, _ := execution["trigger"].(map[string]interface{})
+	triggerArtifacts, _ := trigger["artifacts"].([]interface{})
+	return append(artifacts, triggerArtifacts...), nil
+}
+
+// bindExpectedArtifacts resolves each expected artifact like Orca's ArtifactResolver: to the one
+// received artifact it matches, else to the first matching prior artifact when usePriorArtifact is
+// set, else to its default artifact when useDefaultArtifact is set.
+func bindExpectedArtifacts(expected []interface{}, received []interface{}, prior func() ([]interface{}, error)) ([]artifactBinding, error) {
+	var bindings []artifactBinding
+	for _, e := range expected {
+		expectedArtifact, _ := e.(map[string]interface{})
+		binding := artifactBinding{}
+		binding.ExpectedArtifactId, _ = expectedArtifact["id"].(string)
+		binding.DisplayName, _ = expectedArtifact["displayName"].(string)
+
+		matches, err := matchingArtifacts(expectedArtifact, received)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(matches) > 1:
+			var described []string
+			for _, match := range matches {
+				described = append(described, describeArtifact(match))
+			}
+			binding.Error = fmt.Sprintf("Expected artifact %s matches multiple artifacts [%s]", describeExpected(binding), strings.Join(described, ", "))
+		case len(matches) == 1:
+			binding.Source, binding.Artifact = bindingTrigger, matches[0]
+		}
+
+		if binding.Artifact == nil && binding.Error == "" {
+			if usePrior, _ := expectedArtifact["usePriorArtifact"].(bool); usePrior {
+				priorArtifacts, err := prior()
+				if err != nil {
+					return nil, err
+				}
+				matches, err := matchingArtifacts(expectedArtifact, priorArtifacts)
+				if err != nil {
+					return nil, err
+				}
+				if len(matches) > 0 {
+					binding.Source, binding.Artifact = bindingPrior, matches[0]
+				}
+			}
+		}
+		if binding.Artifact == nil && binding.Error == "" {
+			useDefault, _ := expectedArtifact["useDefaultArtifact"].(bool)
+			if defaultArtifact, ok := expectedArtifact["defaultArtifact"].(map[string]interface{}); useDefault && ok {
+				binding.Source, binding.Artifact = bindingDefault, defaultArtifact
+			} else {
+				binding.Error = fmt.Sprintf("Unmatched expected artifact %s could not be resolved.", describeExpected(binding))
+			}
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+func matchingArtifacts(expectedArtifact map[string]interface{}, artifacts []interface{}) ([]map[string]interface{}, error) {
+	matchArtifact, _ := expectedArtifact["matchArtifact"].(map[string]interface{})
+	patterns := map[string]*regexp.Regexp{}
+	for _, field := range matchFields {
+		pattern, _ := matchArtifact[field].(string)
+		if pattern == "" {
+			continue
+		}
+		compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Expected artifact %v has an invalid %s pattern %s: %v\n", expectedArtifact["id"], field, pattern, err)
+		}
+		patterns[field] = compiled
+	}
+
+	var matches []map[string]interface{}
+	for _, a := range artifacts {
+		artifact, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matched := true
+		for field, pattern := range patterns {
+			value, ok := artifact[field].(string)
+			if !ok || !pattern.MatchString(value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, artifact)
+		}
+	}
+	return matches, nil
+}
+
+func describeExpected(binding artifactBinding) string {
+	if binding.DisplayName == "" {
+		return binding.ExpectedArtifactId
+	}
+	return fmt.Sprintf("%s (%s)", binding.DisplayName, binding.ExpectedArtifactId)
+}
+
+func describeArtifact(artifact map[string]interface{}) string {
+	var parts []string
+	for _, field := range []string{"type", "name", "version", "reference"} {
+		if value, _ := artifact[field].(string); value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func renderBindings(bindings []artifactBinding, received []interface{}) []string {
+	var lines []string
+	bound := map[string]bool{}
+	for _, binding := range bindings {
+		if binding.Error != "" {
+			lines = append(lines, util.Colorize().Color("[reset][bold][red]"+binding.Error))
+			continue
+		}
+		if binding.Source == bindingTrigger {
+			bound[describeArtifact(binding.Artifact)] = true
+		}
+		lines = append(lines, fmt.Sprintf("%s <- %s (%s)", describeExpected(binding), describeArtifact(binding.Artifact), binding.Source))
+	}
+	for _, r := range received {
+		artifact, _ := r.(map[string]interface{})
+		if !bound[describeArtifact(artifact)] {
+			lines = append(lines, util.Colorize().Color(fmt.Sprintf("[reset][yellow]Trigger artifact %s is not bound to any expected artifact", describeArtifact(artifact))))
+		}
+	}
+	return lines
+}
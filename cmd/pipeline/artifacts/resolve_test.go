@@ -0,0 +1,258 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestArtifactsResolve_basic(t *testing.T) {
+	ts := testGateResolveSuccess()
+	defer ts.Close()
+
+	artifactsFile := tempArtifactsFile(t, testTriggerArtifactsJson)
+	defer os.Remove(artifactsFile)
+
+	args := []string{"artifacts", "resolve", "-a", "app", "-n", "deploy", "--artifacts-file", artifactsFile, "--gate-endpoint", ts.URL}
+	err := executeResolveCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestArtifactsResolve_file(t *testing.T) {
+	pipelineFile := tempArtifactsFile(t, testArtifactsPipelineJson)
+	defer os.Remove(pipelineFile)
+	artifactsFile := tempArtifactsFile(t, testTriggerArtifactsJson)
+	defer os.Remove(artifactsFile)
+	priorFile := tempArtifactsFile(t, testPriorArtifactsJson)
+	defer os.Remove(priorFile)
+
+	// Resolving a local pipeline doesn't need Gate.
+	args := []string{"artifacts", "resolve", "--file", pipelineFile, "--artifacts-file", artifactsFile, "--prior-artifacts-file", priorFile}
+	err := executeResolveCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestArtifactsResolve_unmatched(t *testing.T) {
+	ts := testGateResolveSuccess()
+	defer ts.Close()
+
+	artifactsFile := tempArtifactsFile(t, `{"artifacts": []}`)
+	defer os.Remove(artifactsFile)
+
+	args := []string{"artifacts", "resolve", "-a", "app", "-n", "deploy", "--artifacts-file", artifactsFile, "--gate-endpoint", ts.URL}
+	err := executeResolveCmd(args)
+	if err == nil {
+		t.Fatalf("Expected an unmatched expected artifact to fail the command")
+	}
+	expected := "Unmatched expected artifact app image (image) could not be resolved."
+	if err.Error() != expected {
+		t.Fatalf("Expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestArtifactsResolve_flags(t *testing.T) {
+	ts := testGateResolveSuccess()
+	defer ts.Close()
+
+	args := []string{"artifacts", "resolve", "-a", "app", "--gate-endpoint", ts.URL} // Missing pipeline name.
+	err := executeResolveCmd(args)
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestArtifactsResolve_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	args := []string{"artifacts", "resolve", "-a", "app", "-n", "deploy", "--gate-endpoint", ts.URL}
+	err := executeResolveCmd(args)
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestArtifactsResolve_bind(t *testing.T) {
+	var pipeline, trigger, prior map[string]interface{}
+	json.Unmarshal([]byte(testArtifactsPipelineJson), &pipeline)
+	json.Unmarshal([]byte(testTriggerArtifactsJson), &trigger)
+	json.Unmarshal([]byte(testPriorArtifactsJson), &prior)
+	expected := pipeline["expectedArtifacts"].([]interface{})
+	priorLookups := 0
+	priorArtifacts := func() ([]interface{}, error) {
+		priorLookups++
+		return prior["artifacts"].([]interface{}), nil
+	}
+
+	bindings, err := bindExpectedArtifacts(expected, trigger["artifacts"].([]interface{}), priorArtifacts)
+	if err != nil {
+		t.Fatalf("Could not bind artifacts: %s", err)
+	}
+	var lines []string
+	for _, binding := range bindings {
+		lines = append(lines, fmt.Sprintf("%s %s %s%s", binding.ExpectedArtifactId, binding.Source, describeArtifact(binding.Artifact), binding.Error))
+	}
+	expectedLines := []string{
+		"image trigger docker/image gcr.io/project/app gcr.io/project/app:1.4.2",
+		"config prior execution gcs/object gs://bucket/config-41.yml",
+		"chart default helm/chart app 1.0.0",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+		t.Fatalf("Expected bindings:\n%s\ngot:\n%s", strings.Join(expectedLines, "\n"), strings.Join(lines, "\n"))
+	}
+
+	// Two images make the image expected artifact ambiguous.
+	ambiguous := append(trigger["artifacts"].([]interface{}), map[string]interface{}{
+		"type": "docker/image", "name": "gcr.io/project/app", "reference": "gcr.io/project/app:1.4.3",
+	})
+	bindings, err = bindExpectedArtifacts(expected, ambiguous, priorArtifacts)
+	if err != nil {
+		t.Fatalf("Could not bind artifacts: %s", err)
+	}
+	if !strings.HasPrefix(bindings[0].Error, "Expected artifact app image (image) matches multiple artifacts") {
+		t.Fatalf("Expected the image binding to be ambiguous, got: %+v", bindings[0])
+	}
+	if priorLookups != 2 {
+		t.Fatalf("Expected prior artifacts to be looked up once per resolution, got %d", priorLookups)
+	}
+}
+
+func executeResolveCmd(args []string) error {
+	currentCmd := NewResolveCmd(artifactsOptions{})
+	rootCmd := getRootCmdForTest()
+	artifactsCmd := NewArtifactsCmd(os.Stdout)
+	artifactsCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(artifactsCmd)
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+func tempArtifactsFile(t *testing.T, content string) string {
+	tempFile, err := ioutil.TempFile("", "artifacts*.json")
+	if err != nil {
+		t.Fatalf("Could not create temp file: %s", err)
+	}
+	defer tempFile.Close()
+	tempFile.WriteString(strings.TrimSpace(content))
+	return tempFile.Name()
+}
+
+// testGateResolveSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves the pipeline and its latest execution.
+func testGateResolveSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(testArtifactsPipelineJson))
+	}))
+	mux.Handle("/executions", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(testLatestExecutionJson))
+	}))
+	return httptest.NewServer(mux)
+}
+
+// GateServerFail spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with a 500 InternalServerError.
+func GateServerFail() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const testArtifactsPipelineJson = `
+{
+  "id": "deploy-id",
+  "application": "app",
+  "name": "deploy",
+  "expectedArtifacts": [
+    {
+      "id": "image",
+      "displayName": "app image",
+      "matchArtifact": {"type": "docker/image", "name": "gcr.io/project/app"}
+    },
+    {
+      "id": "config",
+      "matchArtifact": {"type": "gcs/object", "name": "gs://bucket/config-.*\\.yml"},
+      "usePriorArtifact": true
+    },
+    {
+      "id": "chart",
+      "matchArtifact": {"type": "helm/chart", "name": "app"},
+      "useDefaultArtifact": true,
+      "defaultArtifact": {"type": "helm/chart", "name": "app", "version": "1.0.0"}
+    }
+  ]
+}
+`
+
+const testTriggerArtifactsJson = `
+{
+  "artifacts": [
+    {"type": "docker/image", "name": "gcr.io/project/app", "reference": "gcr.io/project/app:1.4.2"},
+    {"type": "docker/image", "name": "gcr.io/project/app-sidecar", "reference": "gcr.io/project/app-sidecar:2.0.0"}
+  ]
+}
+`
+
+const testPriorArtifactsJson = `
+{
+  "artifacts": [
+    {"type": "gcs/object", "name": "gs://bucket/config-41.yml"},
+    {"type": "gcs/object", "name": "gs://bucket/config-40.yml"}
+  ]
+}
+`
+
+const testLatestExecutionJson = `
+[
+  {
+    "id": "exec-41",
+    "trigger": {
+      "artifacts": [{"type": "gcs/object", "name": "gs://bucket/config-40.yml"}]
+    },
+    "stages": [
+      {"outputs": {"artifacts": [{"type": "gcs/object", "name": "gs://bucket/config-41.yml"}]}}
+    ]
+  }
+]
+`
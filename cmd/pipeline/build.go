@@ -0,0 +1,80 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+type BuildOptions struct {
+	*pipelineOptions
+	base      string
+	overlays  []string
+	mergeType string
+}
+
+var (
+	buildPipelineShort   = "Build a pipeline from a base pipeline and environment overlays"
+	buildPipelineLong    = "Merge overlays onto a base pipeline and print the result. Strategic merges match stages by refId or name, parameters by name and expected artifacts by id, and remove elements marked with \"$patch\": \"delete\"; JSON merges follow RFC 7386. Both remove fields set to null"
+	buildPipelineExample = "usage: spin pipeline build --base pipeline.yaml --overlay overlays/prod.yaml"
+)
+
+func NewBuildCmd(pipelineOptions pipelineOptions) *cobra.Command {
+	options := BuildOptions{
+		pipelineOptions: &pipelineOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "build",
+		Short:   buildPipelineShort,
+		Long:    buildPipelineLong,
+		Example: buildPipelineExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return buildPipeline(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&options.base, "base", "", "path to the base pipeline file, JSON or YAML")
+	cmd.PersistentFlags().StringSliceVar(&options.overlays, "overlay", nil, "path to an overlay file, JSON or YAML, may be repeated and applied in order")
+	cmd.PersistentFlags().StringVar(&options.mergeType, "merge-type", mergeStrategic, "how overlays are merged, strategic or json")
+
+	return cmd
+}
+
+func buildPipeline(cmd *cobra.Command, options BuildOptions) error {
+	util.InitUI(false, false, "")
+
+	if options.base == "" {
+		return errors.New("required parameter 'base' not set")
+	}
+	pipeline, err := readPipelineDocument(options.base)
+	if err != nil {
+		return err
+	}
+	pipeline, err = applyOverlays(pipeline, options.overlays, options.mergeType)
+	if err != nil {
+		return err
+	}
+
+	built, err := encodeDocument(pipeline)
+	if err != nil {
+		return err
+	}
+	util.UI.Output(strings.TrimSuffix(string(built), "\n"))
+	return nil
+}
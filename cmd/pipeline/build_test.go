@@ -0,0 +1,181 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestPipelineBuild_basic(t *testing.T) {
+	base := tempOverlayFile(testBasePipelineYaml)
+	overlay := tempOverlayFile(testProdOverlayYaml)
+	if base == "" || overlay == "" {
+		t.Fatal("Could not create temp pipeline files.")
+	}
+	defer os.Remove(base)
+	defer os.Remove(overlay)
+
+	args := []string{"pipeline", "build", "--base", base, "--overlay", overlay}
+	currentCmd := NewBuildCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineBuild_flags(t *testing.T) {
+	args := []string{"pipeline", "build", "--overlay", "prod.yaml"} // Missing base.
+	currentCmd := NewBuildCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestPipelineBuild_strategicMerge(t *testing.T) {
+	base := tempOverlayFile(testBasePipelineYaml)
+	overlay := tempOverlayFile(testProdOverlayYaml)
+	if base == "" || overlay == "" {
+		t.Fatal("Could not create temp pipeline files.")
+	}
+	defer os.Remove(base)
+	defer os.Remove(overlay)
+
+	pipeline, err := readPipelineDocument(base)
+	if err != nil {
+		t.Fatalf("Could not read base: %s", err)
+	}
+	pipeline, err = applyOverlays(pipeline, []string{overlay}, mergeStrategic)
+	if err != nil {
+		t.Fatalf("Could not apply overlay: %s", err)
+	}
+	assertPipeline(t, pipeline, testProdPipelineJson)
+}
+
+func TestPipelineBuild_jsonMerge(t *testing.T) {
+	base := tempOverlayFile(testBasePipelineYaml)
+	overlay := tempOverlayFile(testProdOverlayYaml)
+	if base == "" || overlay == "" {
+		t.Fatal("Could not create temp pipeline files.")
+	}
+	defer os.Remove(base)
+	defer os.Remove(overlay)
+
+	pipeline, err := readPipelineDocument(base)
+	if err != nil {
+		t.Fatalf("Could not read base: %s", err)
+	}
+	pipeline, err = applyOverlays(pipeline, []string{overlay}, mergeJson)
+	if err != nil {
+		t.Fatalf("Could not apply overlay: %s", err)
+	}
+	stages := pipeline["stages"].([]interface{})
+	if len(stages) != 3 {
+		t.Fatalf("Expected a JSON merge to replace the stages, got: %v", stages)
+	}
+	if _, exists := pipeline["notifications"]; exists {
+		t.Fatalf("Expected null to remove notifications, got: %v", pipeline["notifications"])
+	}
+}
+
+func assertPipeline(t *testing.T, pipeline map[string]interface{}, expectedJson string) {
+	var expected interface{}
+	json.Unmarshal([]byte(expectedJson), &expected)
+	actualJson, _ := json.Marshal(pipeline)
+	var actual interface{}
+	json.Unmarshal(actualJson, &actual)
+	expectedCanonical, _ := json.MarshalIndent(expected, "", "  ")
+	actualCanonical, _ := json.MarshalIndent(actual, "", "  ")
+	if string(expectedCanonical) != string(actualCanonical) {
+		t.Fatalf("Expected pipeline:\n%s\ngot:\n%s", expectedCanonical, actualCanonical)
+	}
+}
+
+const testBasePipelineYaml = `
+application: app
+name: deploy
+keepWaitingPipelines: false
+notifications:
+- type: slack
+  address: dev-deploys
+parameterConfig:
+- name: version
+  default: latest
+- name: dryRun
+  default: "false"
+stages:
+- refId: "1"
+  name: Bake
+  type: bake
+  requisiteStageRefIds: []
+- refId: "2"
+  name: Deploy
+  type: deployManifest
+  account: staging
+  namespace: app-staging
+  requisiteStageRefIds: ["1"]
+- refId: "3"
+  name: Smoke test
+  type: runJobManifest
+  account: staging
+  requisiteStageRefIds: ["2"]
+`
+
+const testProdOverlayYaml = `
+notifications: null
+parameterConfig:
+- name: dryRun
+  $patch: delete
+stages:
+- name: Deploy
+  account: prod
+  namespace: app-prod
+- refId: "3"
+  $patch: delete
+- refId: "4"
+  name: Manual judgment
+  type: manualJudgment
+  requisiteStageRefIds: ["2"]
+`
+
+const testProdPipelineJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "keepWaitingPipelines": false,
+  "parameterConfig": [
+    {"name": "version", "default": "latest"}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": []},
+    {"refId": "2", "name": "Deploy", "type": "deployManifest", "account": "prod", "namespace": "app-prod", "requisiteStageRefIds": ["1"]},
+    {"refId": "4", "name": "Manual judgment", "type": "manualJudgment", "requisiteStageRefIds": ["2"]}
+  ]
+}
+`
@@ -18,11 +18,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spinnaker/spin/cmd/gateclient"
 	"github.com/spinnaker/spin/util"
+	"github.com/spinnaker/spin/util/ci"
 )
 
 type ExecuteOptions struct {
@@ -32,6 +34,11 @@ type ExecuteOptions struct {
 	name          string
 	parameterFile string
 	artifactsFile string
+	checkAccounts bool
+	strict        bool
+	ciMetadata    bool
+	wait          bool
+	waitTimeout   time.Duration
 }
 
 var (
@@ -39,6 +46,18 @@ var (
 	executePipelineLong  = "Execute the provided pipeline"
 )
 
+// executionPollInterval is how often --wait checks whether the execution has completed.
+var executionPollInterval = 10 * time.Second
+
+// activeStatuses are the statuses of executions that haven't completed yet.
+var activeStatuses = map[string]bool{
+	"NOT_STARTED": true,
+	"BUFFERED":    true,
+	"RUNNING":     true,
+	"PAUSED":      true,
+	"SUSPENDED":   true,
+}
+
 func NewExecuteCmd(pipelineOptions pipelineOptions) *cobra.Command {
 	options := ExecuteOptions{
 		pipelineOptions: &pipelineOptions,
@@ -57,6 +76,11 @@ func NewExecuteCmd(pipelineOptions pipelineOptions) *cobra.Command {
 	cmd.PersistentFlags().StringVarP(&options.name, "name", "n", "", "name of the pipeline to execute")
 	cmd.PersistentFlags().StringVarP(&options.parameterFile, "parameter-file", "f", "", "file to load pipeline parameter values from")
 	cmd.PersistentFlags().StringVarP(&options.artifactsFile, "artifacts-file", "t", "", "file to load pipeline artifacts from")
+	cmd.PersistentFlags().BoolVar(&options.checkAccounts, "check-accounts", false, "warn when stages reference accounts you cannot access before executing")
+	cmd.PersistentFlags().BoolVar(&options.strict, "strict-accounts", false, "check accounts like --check-accounts, but fail instead of warning")
+	cmd.PersistentFlags().BoolVar(&options.ciMetadata, "ci-metadata", true, "attach CI build metadata to the trigger and write step outputs when running in GitHub Actions, GitLab CI or Jenkins")
+	cmd.PersistentFlags().BoolVar(&options.wait, "wait", false, "wait for the execution to complete, failing unless it succeeds")
+	cmd.PersistentFlags().DurationVar(&options.waitTimeout, "wait-timeout", 2*time.Hour, "how long --wait waits for the execution to complete")
 
 	return cmd
 }
@@ -95,6 +119,17 @@ func executePipeline(cmd *cobra.Command, options ExecuteOptions) error {
 		}
 	}
 
+	ciEnv := ci.Detect()
+	if ciEnv != nil && options.ciMetadata {
+		trigger["ci"] = ciEnv.TriggerMetadata()
+	}
+
+	if options.checkAccounts || options.strict {
+		if err = checkExecutionAccounts(gateClient, options); err != nil {
+			return err
+		}
+	}
+
 	_, resp, err := gateClient.PipelineControllerApi.InvokePipelineConfigUsingPOST1(gateClient.Context,
 		options.application,
 		options.name,
@@ -135,6 +170,74 @@ func executePipeline(cmd *cobra.Command, options ExecuteOptions) error {
 		return fmt.Errorf("Started more than one execution: %v", executions)
 	}
 
-	util.UI.JsonOutput(executions[0], util.UI.OutputFormat)
+	execution, _ := executions[0].(map[string]interface{})
+	if options.wait {
+		id, _ := execution["id"].(string)
+		if execution, err = waitForExecution(gateClient, id, options.waitTimeout); err != nil {
+			return err
+		}
+	}
+
+	if ciEnv != nil && options.ciMetadata {
+		if err = writeExecutionOutputs(gateClient, ciEnv, options.application, execution); err != nil {
+			util.UI.Warn(fmt.Sprintf("Could not write CI step outputs: %v\n", err))
+		}
+	}
+
+	util.UI.JsonOutput(execution, util.UI.OutputFormat)
+	if status, _ := execution["status"].(string); options.wait && status != "SUCCEEDED" {
+		return fmt.Errorf("Execution %v completed with status %s\n", execution["id"], status)
+	}
 	return nil
 }
+
+// waitForExecution polls the execution until it completes or the timeout passes.
+func waitForExecution(gateClient *gateclient.GatewayClient, id string, timeout time.Duration) (map[string]interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		execution, resp, err := gateClient.PipelineControllerApi.GetPipelineUsingGET(gateClient.Context, id)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Encountered an error querying pipeline execution %s, status code: %d\n", id, resp.StatusCode)
+		}
+		details, _ := execution.(map[string]interface{})
+		if status, _ := details["status"].(string); !activeStatuses[status] {
+			return details, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Execution %s did not complete within %s\n", id, timeout)
+		}
+		time.Sleep(executionPollInterval)
+	}
+}
+
+// checkExecutionAccounts checks the accounts the pipeline's stages reference. Manual executions
+// run as the current user rather than a trigger's service account.
+func checkExecutionAccounts(gateClient *gateclient.GatewayClient, options ExecuteOptions) error {
+	pipeline, resp, err := gateClient.ApplicationControllerApi.GetPipelineConfigUsingGET(gateClient.Context, options.application, options.name)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Encountered an error querying pipeline, status code: %d\n", resp.StatusCode)
+	}
+	if err != nil {
+		return accountCheckFailed(err, options.strict)
+	}
+	return checkAccounts(gateClient, stageAccounts(pipeline), nil, options.strict)
+}
+
+// writeExecutionOutputs exposes the execution to later steps of the CI build. Its status is only
+// written once the execution has completed, e.g. with --wait.
+func writeExecutionOutputs(gateClient *gateclient.GatewayClient, ciEnv *ci.Environment, application string, execution map[string]interface{}) error {
+	id, _ := execution["id"].(string)
+	outputs := map[string]string{
+		"execution_id": id,
+	}
+	if status, _ := execution["status"].(string); status != "" && !activeStatuses[status] {
+		outputs["execution_status"] = status
+	}
+	if deck := strings.TrimSuffix(gateClient.Config.Deck.Endpoint, "/"); deck != "" && id != "" {
+		outputs["execution_url"] = fmt.Sprintf("%s/#/applications/%s/executions/details/%s", deck, application, id)
+	}
+	return ciEnv.WriteOutputs(outputs)
+}
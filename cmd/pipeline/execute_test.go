@@ -17,16 +17,33 @@ package pipeline
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	gate "github.com/spinnaker/spin/gateapi"
 	"github.com/spinnaker/spin/util"
+	"github.com/spinnaker/spin/util/ci"
 )
 
+// TestMain hides the CI build running the tests from execute, which would otherwise attach its
+// metadata to the mock Gate's triggers and append step outputs to the build's outputs file.
+func TestMain(m *testing.M) {
+	restore := setEnvironment(map[string]string{
+		"GITHUB_ACTIONS": "",
+		"GITLAB_CI":      "",
+		"JENKINS_URL":    "",
+		ci.OutputFileEnv: "",
+	})
+	code := m.Run()
+	restore()
+	os.Exit(code)
+}
+
 // TODO(jacobkiefer): This test overlaps heavily with pipeline_save_test.go,
 // consider factoring common testing code out.
 func TestPipelineExecute_basic(t *testing.T) {
@@ -143,6 +160,215 @@ func TestPipelineExecute_missingapp(t *testing.T) {
 	}
 }
 
+func TestPipelineExecute_inaccessibleAccountsStrict(t *testing.T) {
+	ts := testGatePipelineExecuteSuccess()
+	defer ts.Close()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "deploy", "--strict-accounts", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected execute to fail for a pipeline deploying to an inaccessible account")
+	}
+}
+
+func TestPipelineExecute_accountCheckOptIn(t *testing.T) {
+	var requests []string
+	ts := testGatePipelineExecuteAccounts(&requests, currentUserJson)
+	defer ts.Close()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "deploy", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("Expected no account check without --check-accounts, got requests: %v", requests)
+	}
+}
+
+func TestPipelineExecute_checkAccountsAnonymous(t *testing.T) {
+	var requests []string
+	ts := testGatePipelineExecuteAccounts(&requests, "")
+	defer ts.Close()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "deploy", "--strict-accounts", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Expected the account check to be skipped without a user to check, got: %s", err)
+	}
+	expected := "/applications/app/pipelineConfigs/deploy,/auth/user"
+	if strings.Join(requests, ",") != expected {
+		t.Fatalf("Expected requests %s, got %v", expected, requests)
+	}
+}
+
+func TestPipelineExecute_ciMetadata(t *testing.T) {
+	var trigger map[string]interface{}
+	ts := testGateCiPipelineExecuteSuccess(&trigger)
+	defer ts.Close()
+
+	outputFile, err := ioutil.TempFile("" /* /tmp dir. */, "github-output")
+	if err != nil {
+		t.Fatalf("Could not create temp output file: %s", err)
+	}
+	defer os.Remove(outputFile.Name())
+
+	ciEnv := map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_REPOSITORY": "spinnaker/spin",
+		"GITHUB_SHA":        "abc123",
+		"GITHUB_HEAD_REF":   "",
+		"GITHUB_REF_NAME":   "",
+		"GITHUB_REF":        "refs/heads/main",
+		"GITHUB_ACTOR":      "octocat",
+		"GITHUB_SERVER_URL": "https://github.com",
+		"GITHUB_RUN_ID":     "42",
+		"GITHUB_OUTPUT":     outputFile.Name(),
+	}
+	defer setEnvironment(ciEnv)()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "one", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	metadata, _ := trigger["ci"].(map[string]interface{})
+	expected := map[string]string{
+		"provider":   "github-actions",
+		"repository": "spinnaker/spin",
+		"sha":        "abc123",
+		"branch":     "main",
+		"actor":      "octocat",
+		"buildUrl":   "https://github.com/spinnaker/spin/actions/runs/42",
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("Expected trigger ci.%s to be %s, got %v", key, value, metadata[key])
+		}
+	}
+
+	outputs, err := ioutil.ReadFile(outputFile.Name())
+	if err != nil {
+		t.Fatalf("Could not read output file: %s", err)
+	}
+	// The execution is still running, so its status isn't known yet.
+	if string(outputs) != "execution_id=asdflkj\n" {
+		t.Fatalf("Unexpected step outputs: %q", outputs)
+	}
+}
+
+func TestPipelineExecute_wait(t *testing.T) {
+	ts := testGatePipelineExecuteWait([]string{"RUNNING", "SUCCEEDED"})
+	defer ts.Close()
+	defer func(interval time.Duration) { executionPollInterval = interval }(executionPollInterval)
+	executionPollInterval = time.Millisecond
+
+	outputFile, err := ioutil.TempFile("" /* /tmp dir. */, "github-output")
+	if err != nil {
+		t.Fatalf("Could not create temp output file: %s", err)
+	}
+	defer os.Remove(outputFile.Name())
+	defer setEnvironment(map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_OUTPUT":  outputFile.Name(),
+	})()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "one", "--wait", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	outputs, err := ioutil.ReadFile(outputFile.Name())
+	if err != nil {
+		t.Fatalf("Could not read output file: %s", err)
+	}
+	if string(outputs) != "execution_id=asdflkj\nexecution_status=SUCCEEDED\n" {
+		t.Fatalf("Unexpected step outputs: %q", outputs)
+	}
+}
+
+func TestPipelineExecute_waitTerminal(t *testing.T) {
+	ts := testGatePipelineExecuteWait([]string{"TERMINAL"})
+	defer ts.Close()
+
+	args := []string{"pipeline", "execute", "--application", "app", "--name", "one", "--wait", "--gate-endpoint", ts.URL}
+	currentCmd := NewExecuteCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "TERMINAL") {
+		t.Fatalf("Expected a failed execution to fail the command, got: %v", err)
+	}
+}
+
+// setEnvironment sets the environment variables, unsetting those given as empty, and returns a
+// function restoring their previous values.
+func setEnvironment(env map[string]string) func() {
+	previous := map[string]*string{}
+	for name, value := range env {
+		if old, exists := os.LookupEnv(name); exists {
+			previous[name] = &old
+		} else {
+			previous[name] = nil
+		}
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+	}
+	return func() {
+		for name, value := range previous {
+			if value == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *value)
+			}
+		}
+	}
+}
+
 // testGatePipelineExecuteSuccess spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with successful responses to pipeline execute API calls.
 func testGatePipelineExecuteSuccess() *httptest.Server {
@@ -154,6 +380,12 @@ func testGatePipelineExecuteSuccess() *httptest.Server {
 		w.WriteHeader(http.StatusAccepted)
 		fmt.Fprintln(w, string(b)) // Write empty 201.
 	}))
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(testDeployPipelineJsonStr))
+	}))
+	mux.Handle("/auth/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(currentUserJson))
+	}))
 	mux.Handle("/applications/app/executions/search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintln(w, strings.TrimSpace(executions))
@@ -161,10 +393,72 @@ func testGatePipelineExecuteSuccess() *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
+// testGatePipelineExecuteAccounts spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds like testGatePipelineExecuteSuccess with the given current user,
+// and records the requests made to check accounts.
+func testGatePipelineExecuteAccounts(requests *[]string, user string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/pipelines/app/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "{}")
+	}))
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+		fmt.Fprintln(w, strings.TrimSpace(testDeployPipelineJsonStr))
+	}))
+	mux.Handle("/auth/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+		fmt.Fprint(w, strings.TrimSpace(user))
+	}))
+	mux.Handle("/applications/app/executions/search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(executions))
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testGatePipelineExecuteWait spins up a local http server that we will configure the GateClient
+// to direct requests to. Starts an execution that reports each of the given statuses in turn.
+func testGatePipelineExecuteWait(statuses []string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/pipelines/app/one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "{}")
+	}))
+	mux.Handle("/applications/app/executions/search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(executions))
+	}))
+	polls := 0
+	mux.Handle("/pipelines/asdflkj", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[len(statuses)-1]
+		if polls < len(statuses) {
+			status = statuses[polls]
+		}
+		polls++
+		fmt.Fprintf(w, `{"id": "asdflkj", "status": "%s"}`, status)
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testGateCiPipelineExecuteSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds like testGatePipelineExecuteSuccess and records the submitted trigger.
+func testGateCiPipelineExecuteSuccess(trigger *map[string]interface{}) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/pipelines/app/one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(trigger)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "{}")
+	}))
+	mux.Handle("/applications/app/executions/search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(executions))
+	}))
+	return httptest.NewServer(mux)
+}
+
 const executions = `
 [
   {
-    "id": "asdflkj"
+    "id": "asdflkj",
+    "status": "RUNNING"
   }
 ]
 `
@@ -15,7 +15,6 @@
 package execution
 
 import (
-	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spinnaker/spin/cmd/gateclient"
@@ -25,29 +24,33 @@ import (
 
 var (
 	cancelExecutionShort = "Cancel the executions for the provided execution id"
-	cancelExecutionLong  = "Cancel the executions for the provided execution id"
+	cancelExecutionLong  = "Cancel the executions for the provided execution id, or the one picked with -a, -n and --last, --running or --nth"
 )
 
 func NewCancelCmd() *cobra.Command {
+	selector := executionSelector{}
 	cmd := &cobra.Command{
 		Use:   "cancel",
 		Short: cancelExecutionShort,
 		Long:  cancelExecutionLong,
-		RunE:  cancelExecution,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cancelExecution(cmd, selector, args)
+		},
 	}
+	addSelectorFlags(cmd, &selector)
 
 	return cmd
 }
 
-func cancelExecution(cmd *cobra.Command, args []string) error {
+func cancelExecution(cmd *cobra.Command, selector executionSelector, args []string) error {
 	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
 
-	executionId, err := util.ReadArgsOrStdin(args)
-	if executionId == "" {
-		return errors.New("no execution id supplied, exiting")
+	executionId, err := selector.executionId(gateClient, args)
+	if err != nil {
+		return err
 	}
 
 	resp, err := gateClient.PipelineControllerApi.CancelPipelineUsingPUT1(gateClient.Context,
@@ -39,7 +39,10 @@ func NewExecutionCmd(out io.Writer) *cobra.Command {
 
 	// create subcommands
 	cmd.AddCommand(NewCancelCmd())
+	cmd.AddCommand(NewExportTraceCmd())
 	cmd.AddCommand(NewGetCmd())
 	cmd.AddCommand(NewListCmd(options))
+	cmd.AddCommand(NewTreeCmd())
+	cmd.AddCommand(NewZombiesCmd())
 	return cmd
 }
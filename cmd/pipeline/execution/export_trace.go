@@ -0,0 +1,430 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type ExportTraceOptions struct {
+	selector executionSelector
+	format   string
+	send     bool
+	endpoint string
+}
+
+var (
+	exportTraceShort   = "Export the specified execution as a trace"
+	exportTraceLong    = "Convert the execution with the provided id into a trace with a span for the execution, each of its stages and each of their tasks, so it can be loaded into tracing tools. Use --send to post the trace to the OTLP/HTTP collector configured as trace.endpoint, or given with --endpoint"
+	exportTraceExample = "usage: spin pipeline execution export-trace [options] <execution-id>"
+)
+
+const (
+	traceFormatOtlp   = "otlp-json"
+	traceFormatJaeger = "jaeger"
+
+	traceServiceName = "spinnaker"
+
+	// OTLP span status codes.
+	spanStatusUnset = 0
+	spanStatusOk    = 1
+	spanStatusError = 2
+)
+
+// traceSpan is an execution, stage or task as a span, independent of the export format.
+type traceSpan struct {
+	traceId       string
+	spanId        string
+	parentSpanId  string
+	name          string
+	startMs       int64
+	endMs         int64
+	status        int
+	statusMessage string
+	attributes    map[string]string
+}
+
+func NewExportTraceCmd() *cobra.Command {
+	options := ExportTraceOptions{}
+	cmd := &cobra.Command{
+		Use:     "export-trace",
+		Short:   exportTraceShort,
+		Long:    exportTraceLong,
+		Example: exportTraceExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportTrace(cmd, options, args)
+		},
+	}
+
+	addSelectorFlags(cmd, &options.selector)
+	cmd.PersistentFlags().StringVar(&options.format, "format", traceFormatOtlp, "trace format, one of otlp-json or jaeger")
+	cmd.PersistentFlags().BoolVar(&options.send, "send", false, "send the trace to the configured OTLP/HTTP collector instead of printing it")
+	cmd.PersistentFlags().StringVar(&options.endpoint, "endpoint", "", "(optional) OTLP/HTTP collector endpoint to send the trace to, overrides trace.endpoint in the config and implies --send")
+
+	return cmd
+}
+
+func exportTrace(cmd *cobra.Command, options ExportTraceOptions, args []string) error {
+	if options.format != traceFormatOtlp && options.format != traceFormatJaeger {
+		return fmt.Errorf("Unknown trace format %s, expected %s or %s\n", options.format, traceFormatOtlp, traceFormatJaeger)
+	}
+
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	id, err := options.selector.executionId(gateClient, args)
+	if err != nil {
+		return err
+	}
+
+	endpoint := options.endpoint
+	if endpoint == "" && options.send {
+		endpoint = gateClient.Config.Trace.Endpoint
+		if endpoint == "" {
+			return errors.New("no trace endpoint configured, set trace.endpoint in the config or pass --endpoint")
+		}
+	}
+	if endpoint != "" && options.format != traceFormatOtlp {
+		return fmt.Errorf("Only %s traces can be sent to a collector\n", traceFormatOtlp)
+	}
+
+	execution, err := getPipelineExecution(gateClient, id)
+	if err != nil {
+		return err
+	}
+	spans := executionSpans(execution, time.Now())
+
+	if endpoint == "" {
+		if options.format == traceFormatJaeger {
+			util.UI.JsonOutput(jaegerTrace(spans), util.UI.OutputFormat)
+		} else {
+			util.UI.JsonOutput(otlpTrace(spans), util.UI.OutputFormat)
+		}
+		return nil
+	}
+
+	insecure, err := cmd.InheritedFlags().GetBool("insecure")
+	if err != nil {
+		return err
+	}
+	if err := sendOtlpTrace(endpoint, otlpTrace(spans), insecure); err != nil {
+		return err
+	}
+	util.UI.Info(util.Colorize().Color(fmt.Sprintf("[reset][bold][green]Sent trace with %d spans for execution %s to %s", len(spans), id, endpoint)))
+	return nil
+}
+
+// executionSpans converts the execution into a root span with a child span for each stage, nested
+// under its parent stage for synthetic stages, and a child span for each task of a stage. Spans
+// that haven't finished end at now, and stages and tasks that never started have no span.
+func executionSpans(execution map[string]interface{}, now time.Time) []traceSpan {
+	executionId, _ := execution["id"].(string)
+	traceId := traceIdentifier(executionId, 16)
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+
+	newSpan := func(key string, parentSpanId string, name string, element map[string]interface{}) traceSpan {
+		status, _ := element["status"].(string)
+		start, end := spanTimes(element, nowMs)
+		span := traceSpan{
+			traceId:      traceId,
+			spanId:       traceIdentifier(executionId+"/"+key, 8),
+			parentSpanId: parentSpanId,
+			name:         name,
+			startMs:      start,
+			endMs:        end,
+			attributes: map[string]string{
+				"spinnaker.execution.id": executionId,
+				"spinnaker.status":       status,
+			},
+		}
+		span.status, span.statusMessage = spanStatus(status)
+		return span
+	}
+
+	name, _ := execution["name"].(string)
+	root := newSpan("", "", name, execution)
+	if application, _ := execution["application"].(string); application != "" {
+		root.attributes["spinnaker.application"] = application
+	}
+	if name != "" {
+		root.attributes["spinnaker.pipeline.name"] = name
+	}
+	if configId, _ := execution["pipelineConfigId"].(string); configId != "" {
+		root.attributes["spinnaker.pipeline.config_id"] = configId
+	}
+	if trigger, ok := execution["trigger"].(map[string]interface{}); ok {
+		if triggerType, _ := trigger["type"].(string); triggerType != "" {
+			root.attributes["spinnaker.trigger.type"] = triggerType
+		}
+		if user, _ := trigger["user"].(string); user != "" {
+			root.attributes["spinnaker.trigger.user"] = user
+		}
+	}
+	spans := []traceSpan{root}
+
+	stages, _ := execution["stages"].([]interface{})
+	stageSpanIds := map[string]string{}
+	for _, rawStage := range stages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if stageId, _ := stage["id"].(string); stageId != "" && hasStarted(stage) {
+			stageSpanIds[stageId] = traceIdentifier(executionId+"/"+stageId, 8)
+		}
+	}
+	for _, rawStage := range stages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok || !hasStarted(stage) {
+			continue
+		}
+		stageId, _ := stage["id"].(string)
+		parentSpanId := root.spanId
+		if parentStageId, _ := stage["parentStageId"].(string); stageSpanIds[parentStageId] != "" {
+			parentSpanId = stageSpanIds[parentStageId]
+		}
+		stageName, _ := stage["name"].(string)
+		stageSpan := newSpan(stageId, parentSpanId, stageName, stage)
+		if stageType, _ := stage["type"].(string); stageType != "" {
+			stageSpan.attributes["spinnaker.stage.type"] = stageType
+		}
+		if refId, _ := stage["refId"].(string); refId != "" {
+			stageSpan.attributes["spinnaker.stage.ref_id"] = refId
+		}
+		if exception := stageException(stage); exception != "" && stageSpan.status == spanStatusError {
+			stageSpan.statusMessage = exception
+		}
+		spans = append(spans, stageSpan)
+
+		tasks, _ := stage["tasks"].([]interface{})
+		for _, rawTask := range tasks {
+			task, ok := rawTask.(map[string]interface{})
+			if !ok || !hasStarted(task) {
+				continue
+			}
+			taskId, _ := task["id"].(string)
+			taskName, _ := task["name"].(string)
+			taskSpan := newSpan(stageId+"/"+taskId, stageSpan.spanId, taskName, task)
+			if class, _ := task["implementingClass"].(string); class != "" {
+				taskSpan.attributes["spinnaker.task.class"] = class
+			}
+			spans = append(spans, taskSpan)
+		}
+	}
+	return spans
+}
+
+// traceIdentifier derives a stable trace or span id of the given number of bytes, so exporting
+// an execution twice produces the same trace.
+func traceIdentifier(key string, size int) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:size])
+}
+
+// hasStarted returns false for stages and tasks that were not started or were skipped before starting.
+func hasStarted(element map[string]interface{}) bool {
+	start, _ := element["startTime"].(float64)
+	return start > 0
+}
+
+func spanTimes(element map[string]interface{}, nowMs int64) (int64, int64) {
+	start, _ := element["startTime"].(float64)
+	end, _ := element["endTime"].(float64)
+	if start == 0 {
+		return 0, 0
+	}
+	if end == 0 {
+		return int64(start), nowMs
+	}
+	return int64(start), int64(end)
+}
+
+func spanStatus(status string) (int, string) {
+	switch strings.ToUpper(status) {
+	case "SUCCEEDED", "SKIPPED":
+		return spanStatusOk, ""
+	case "TERMINAL", "STOPPED", "FAILED_CONTINUE":
+		return spanStatusError, strings.ToUpper(status)
+	}
+	return spanStatusUnset, ""
+}
+
+func stageException(stage map[string]interface{}) string {
+	context, _ := stage["context"].(map[string]interface{})
+	exception, _ := context["exception"].(map[string]interface{})
+	details, _ := exception["details"].(map[string]interface{})
+	if message, _ := details["error"].(string); message != "" {
+		return message
+	}
+	reasons, _ := details["errors"].([]interface{})
+	var messages []string
+	for _, e := range reasons {
+		if message, ok := e.(string); ok {
+			messages = append(messages, message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// otlpTrace encodes the spans as an OTLP/JSON ExportTraceServiceRequest.
+func otlpTrace(spans []traceSpan) map[string]interface{} {
+	var otlpSpans []interface{}
+	for _, span := range spans {
+		otlpSpan := map[string]interface{}{
+			"traceId":           span.traceId,
+			"spanId":            span.spanId,
+			"name":              span.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(span.startMs*int64(time.Millisecond), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.endMs*int64(time.Millisecond), 10),
+			"attributes":        otlpAttributes(span.attributes),
+			"status":            map[string]interface{}{"code": span.status},
+		}
+		if span.parentSpanId != "" {
+			otlpSpan["parentSpanId"] = span.parentSpanId
+		}
+		if span.statusMessage != "" {
+			otlpSpan["status"].(map[string]interface{})["message"] = span.statusMessage
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]string{"service.name": traceServiceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "spin"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attributes map[string]string) []interface{} {
+	var otlp []interface{}
+	for _, key := range sortedKeys(attributes) {
+		otlp = append(otlp, map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": attributes[key]},
+		})
+	}
+	return otlp
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jaegerTrace encodes the spans in the JSON format the Jaeger UI loads traces from.
+func jaegerTrace(spans []traceSpan) map[string]interface{} {
+	var jaegerSpans []interface{}
+	traceId := ""
+	for _, span := range spans {
+		traceId = span.traceId
+		var tags []interface{}
+		for _, key := range sortedKeys(span.attributes) {
+			tags = append(tags, map[string]interface{}{"key": key, "type": "string", "value": span.attributes[key]})
+		}
+		if span.status == spanStatusError {
+			tags = append(tags, map[string]interface{}{"key": "error", "type": "bool", "value": true})
+			if span.statusMessage != "" {
+				tags = append(tags, map[string]interface{}{"key": "otel.status_description", "type": "string", "value": span.statusMessage})
+			}
+		}
+		references := []interface{}{}
+		if span.parentSpanId != "" {
+			references = append(references, map[string]interface{}{
+				"refType": "CHILD_OF",
+				"traceID": span.traceId,
+				"spanID":  span.parentSpanId,
+			})
+		}
+		jaegerSpans = append(jaegerSpans, map[string]interface{}{
+			"traceID":       span.traceId,
+			"spanID":        span.spanId,
+			"operationName": span.name,
+			"references":    references,
+			"startTime":     span.startMs * 1000,
+			"duration":      (span.endMs - span.startMs) * 1000,
+			"tags":          tags,
+			"logs":          []interface{}{},
+			"processID":     "p1",
+		})
+	}
+	return map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"traceID": traceId,
+				"spans":   jaegerSpans,
+				"processes": map[string]interface{}{
+					"p1": map[string]interface{}{"serviceName": traceServiceName, "tags": []interface{}{}},
+				},
+			},
+		},
+	}
+}
+
+// sendOtlpTrace posts the trace to the traces path of an OTLP/HTTP collector.
+func sendOtlpTrace(endpoint string, trace map[string]interface{}, insecure bool) error {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	body, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{}
+	if insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Encountered an error sending trace to %s, status code: %d\n", url, resp.StatusCode)
+	}
+	return nil
+}
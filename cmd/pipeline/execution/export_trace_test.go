@@ -0,0 +1,335 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spinnaker/spin/util"
+)
+
+func TestExecutionExportTrace_basic(t *testing.T) {
+	ts := testGateExecutionTraceSuccess()
+	defer ts.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestExecutionExportTrace_jaeger(t *testing.T) {
+	ts := testGateExecutionTraceSuccess()
+	defer ts.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--format", "jaeger", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestExecutionExportTrace_flags(t *testing.T) {
+	ts := testGateExecutionTraceSuccess()
+	defer ts.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--format", "zipkin", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestExecutionExportTrace_send(t *testing.T) {
+	ts := testGateExecutionTraceSuccess()
+	defer ts.Close()
+	var received map[string]interface{}
+	collector := testTraceCollector(&received)
+	defer collector.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--endpoint", collector.URL, "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	resourceSpans, _ := received["resourceSpans"].([]interface{})
+	if len(resourceSpans) != 1 {
+		t.Fatalf("Expected the collector to receive one resource, got: %v", received)
+	}
+	scopeSpans := resourceSpans[0].(map[string]interface{})["scopeSpans"].([]interface{})
+	spans := scopeSpans[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 7 {
+		t.Fatalf("Expected the collector to receive 7 spans, got %d", len(spans))
+	}
+}
+
+func TestExecutionExportTrace_sendUnconfigured(t *testing.T) {
+	ts := testGateExecutionTraceSuccess()
+	defer ts.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--send", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestExecutionExportTrace_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+	currentCmd := NewExportTraceCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "export-trace", "01DEPLOY", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestExecutionExportTrace_spans(t *testing.T) {
+	var execution map[string]interface{}
+	if err := json.Unmarshal([]byte(traceExecutionJson), &execution); err != nil {
+		t.Fatalf("Could not parse execution: %s", err)
+	}
+	now := time.Unix(0, 1556000500000*int64(time.Millisecond))
+	spans := executionSpans(execution, now)
+
+	names := map[string]string{}
+	for _, span := range spans {
+		names[span.spanId] = span.name
+	}
+	expected := []string{
+		"deploy <- (root) status=2",
+		"Bake <- deploy status=1",
+		"bakeTask <- Bake status=1",
+		"Deploy to prod <- deploy status=2",
+		"Disable old server group <- Deploy to prod status=2",
+		"Create server group <- Deploy to prod status=0",
+		"createServerGroupTask <- Create server group status=0",
+	}
+	var actual []string
+	for _, span := range spans {
+		parent := "(root)"
+		if span.parentSpanId != "" {
+			parent = names[span.parentSpanId]
+		}
+		if span.traceId != spans[0].traceId {
+			t.Errorf("Expected span %s to share the execution's trace id", span.name)
+		}
+		actual = append(actual, fmt.Sprintf("%s <- %s status=%d", span.name, parent, span.status))
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected spans:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	running := spans[6]
+	if running.endMs != 1556000500000 {
+		t.Errorf("Expected a running task to end now, got %d", running.endMs)
+	}
+	if spans[4].statusMessage != "Server group is still taking traffic" {
+		t.Errorf("Expected the stage exception as status message, got %q", spans[4].statusMessage)
+	}
+}
+
+func TestExecutionExportTrace_notStarted(t *testing.T) {
+	var execution map[string]interface{}
+	if err := json.Unmarshal([]byte(traceNotStartedExecutionJson), &execution); err != nil {
+		t.Fatalf("Could not parse execution: %s", err)
+	}
+	now := time.Unix(0, 1556000500000*int64(time.Millisecond))
+	spans := executionSpans(execution, now)
+
+	var names []string
+	for _, span := range spans {
+		if span.startMs == 0 {
+			t.Errorf("Expected span %s to have a start time", span.name)
+		}
+		names = append(names, span.name)
+	}
+	expected := "deploy,Bake,bakeTask"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected spans %s, got %s", expected, strings.Join(names, ","))
+	}
+}
+
+// testGateExecutionTraceSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves an execution with stages and tasks.
+func testGateExecutionTraceSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/pipelines/01DEPLOY", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(traceExecutionJson))
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testTraceCollector spins up a local http server standing in for an OTLP/HTTP collector,
+// keeping the last trace it received.
+func testTraceCollector(received *map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/traces", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(received)
+		fmt.Fprintln(w, "{}")
+	}))
+	return httptest.NewServer(mux)
+}
+
+const traceExecutionJson = `
+{
+  "id": "01DEPLOY",
+  "name": "deploy",
+  "application": "app",
+  "status": "TERMINAL",
+  "startTime": 1556000000000,
+  "endTime": 1556000400000,
+  "trigger": {"type": "manual", "user": "someone@example.com"},
+  "stages": [
+    {
+      "id": "bake",
+      "refId": "1",
+      "type": "bake",
+      "name": "Bake",
+      "status": "SUCCEEDED",
+      "startTime": 1556000000000,
+      "endTime": 1556000100000,
+      "tasks": [
+        {"id": "1", "name": "bakeTask", "status": "SUCCEEDED", "startTime": 1556000000000, "endTime": 1556000100000}
+      ]
+    },
+    {
+      "id": "deploy",
+      "refId": "2",
+      "type": "deploy",
+      "name": "Deploy to prod",
+      "status": "TERMINAL",
+      "startTime": 1556000100000,
+      "endTime": 1556000400000,
+      "context": {"exception": {"details": {"errors": ["Deployment failed"]}}}
+    },
+    {
+      "id": "disable",
+      "refId": "2<1",
+      "type": "disableServerGroup",
+      "name": "Disable old server group",
+      "status": "TERMINAL",
+      "parentStageId": "deploy",
+      "startTime": 1556000200000,
+      "endTime": 1556000300000,
+      "context": {"exception": {"details": {"error": "Server group is still taking traffic"}}}
+    },
+    {
+      "id": "create",
+      "refId": "2<2",
+      "type": "createServerGroup",
+      "name": "Create server group",
+      "status": "RUNNING",
+      "parentStageId": "deploy",
+      "startTime": 1556000300000,
+      "tasks": [
+        {"id": "1", "name": "createServerGroupTask", "status": "RUNNING", "startTime": 1556000300000}
+      ]
+    }
+  ]
+}
+`
+
+const traceNotStartedExecutionJson = `
+{
+  "id": "01WAITING",
+  "name": "deploy",
+  "application": "app",
+  "status": "RUNNING",
+  "startTime": 1556000000000,
+  "stages": [
+    {
+      "id": "bake",
+      "name": "Bake",
+      "status": "RUNNING",
+      "startTime": 1556000000000,
+      "tasks": [
+        {"id": "1", "name": "bakeTask", "status": "RUNNING", "startTime": 1556000000000},
+        {"id": "2", "name": "monitorBake", "status": "NOT_STARTED"}
+      ]
+    },
+    {
+      "id": "deploy",
+      "name": "Deploy to prod",
+      "status": "NOT_STARTED",
+      "tasks": [
+        {"id": "1", "name": "createServerGroupTask", "status": "NOT_STARTED"}
+      ]
+    },
+    {
+      "id": "notify",
+      "name": "Notify",
+      "status": "SKIPPED"
+    }
+  ]
+}
+`
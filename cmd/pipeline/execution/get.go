@@ -25,26 +25,30 @@ import (
 
 var (
 	getExecutionShort = "Get the specified execution"
-	getExecutionLong  = "Get the execution with the provided id "
+	getExecutionLong  = "Get the execution with the provided id, or the one picked with -a, -n and --last, --last-failed, --last-succeeded, --running or --nth"
 )
 
 func NewGetCmd() *cobra.Command {
+	selector := executionSelector{}
 	cmd := &cobra.Command{
 		Use:   "get",
 		Short: getExecutionShort,
 		Long:  getExecutionLong,
-		RunE:  getExecution,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return getExecution(cmd, selector, args)
+		},
 	}
+	addSelectorFlags(cmd, &selector)
 	return cmd
 }
 
-func getExecution(cmd *cobra.Command, args []string) error {
+func getExecution(cmd *cobra.Command, selector executionSelector, args []string) error {
 	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
 	if err != nil {
 		return err
 	}
 
-	id, err := util.ReadArgsOrStdin(args)
+	id, err := selector.executionId(gateClient, args)
 	if err != nil {
 		return err
 	}
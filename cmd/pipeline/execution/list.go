@@ -83,10 +83,10 @@ func listExecution(cmd *cobra.Command, options ListOptions) error {
 		statuses = append(statuses, "RUNNING")
 	}
 	if options.succeeded {
-		statuses = append(statuses, "SUCCEEDED", "STOPPED", "SKIPPED")
+		statuses = append(statuses, succeededStatuses...)
 	}
 	if options.failed {
-		statuses = append(statuses, "TERMINAL", "STOPPED", "FAILED_CONTINUE")
+		statuses = append(statuses, failedStatuses...)
 	}
 	if options.canceled {
 		statuses = append(statuses, "CANCELED")
@@ -0,0 +1,190 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+var (
+	succeededStatuses = []string{"SUCCEEDED", "STOPPED", "SKIPPED"}
+	failedStatuses    = []string{"TERMINAL", "STOPPED", "FAILED_CONTINUE"}
+)
+
+// executionSelector picks one of the executions of a pipeline, so commands can be given
+// `-a app -n pipeline --last-failed` instead of an execution id.
+type executionSelector struct {
+	application   string
+	pipeline      string
+	last          bool
+	lastFailed    bool
+	lastSucceeded bool
+	running       bool
+	nth           int
+}
+
+func addSelectorFlags(cmd *cobra.Command, selector *executionSelector) {
+	cmd.PersistentFlags().StringVarP(&selector.application, "application", "a", "", "application of the pipeline to select an execution of, instead of giving an execution id")
+	cmd.PersistentFlags().StringVarP(&selector.pipeline, "name", "n", "", "name of the pipeline to select an execution of, instead of giving an execution id")
+	cmd.PersistentFlags().BoolVar(&selector.last, "last", false, "select the most recent execution of the pipeline")
+	cmd.PersistentFlags().BoolVar(&selector.lastFailed, "last-failed", false, "select the most recent failed execution of the pipeline")
+	cmd.PersistentFlags().BoolVar(&selector.lastSucceeded, "last-succeeded", false, "select the most recent succeeded execution of the pipeline")
+	cmd.PersistentFlags().BoolVar(&selector.running, "running", false, "select the most recent running execution of the pipeline")
+	cmd.PersistentFlags().IntVar(&selector.nth, "nth", 0, "select the nth most recent execution of the pipeline, counting from 1, among those matching the other selectors")
+}
+
+// selected reports whether the selector flags ask for an execution rather than an id.
+func (s *executionSelector) selected() bool {
+	return s.last || s.lastFailed || s.lastSucceeded || s.running || s.nth != 0
+}
+
+// ExecutionSelector lets commands outside this package take an execution id or the same
+// selector flags, such as webhook test's --execution.
+type ExecutionSelector struct {
+	selector executionSelector
+}
+
+// AddFlags adds the selector flags to the command.
+func (s *ExecutionSelector) AddFlags(cmd *cobra.Command) {
+	addSelectorFlags(cmd, &s.selector)
+}
+
+// IsSet reports whether any of the selector flags is set.
+func (s *ExecutionSelector) IsSet() bool {
+	return s.selector.application != "" || s.selector.pipeline != "" || s.selector.selected()
+}
+
+// ExecutionId returns id when it is given, or the execution the selector flags pick.
+func (s *ExecutionSelector) ExecutionId(gateClient *gateclient.GatewayClient, id string) (string, error) {
+	if id != "" {
+		return s.selector.executionId(gateClient, []string{id})
+	}
+	if s.selector.application == "" || s.selector.pipeline == "" {
+		return "", errors.New("selecting an execution requires the pipeline's application (-a) and name (-n)")
+	}
+	return s.selector.executionId(gateClient, nil)
+}
+
+// executionId returns the execution id given as an argument or on stdin, or the one the
+// selector picks. With -a and -n alone, it picks the most recent execution.
+func (s *executionSelector) executionId(gateClient *gateclient.GatewayClient, args []string) (string, error) {
+	if !s.selected() {
+		if len(args) > 0 || s.application == "" || s.pipeline == "" {
+			id, err := util.ReadArgsOrStdin(args)
+			if err != nil {
+				return "", err
+			}
+			if id == "" {
+				return "", errors.New("no execution id supplied, exiting")
+			}
+			return id, nil
+		}
+	}
+	if len(args) > 0 {
+		return "", errors.New("cannot use an execution id together with --last, --last-failed, --last-succeeded, --running or --nth")
+	}
+	if s.application == "" || s.pipeline == "" {
+		return "", errors.New("selecting an execution requires the pipeline's application (-a) and name (-n)")
+	}
+
+	var statuses []string
+	count := 0
+	for _, choice := range []struct {
+		set      bool
+		statuses []string
+	}{
+		{s.last, nil},
+		{s.lastFailed, failedStatuses},
+		{s.lastSucceeded, succeededStatuses},
+		{s.running, []string{"RUNNING"}},
+	} {
+		if choice.set {
+			count++
+			statuses = choice.statuses
+		}
+	}
+	if count > 1 {
+		return "", errors.New("only one of --last, --last-failed, --last-succeeded and --running can be used")
+	}
+	nth := s.nth
+	if nth == 0 {
+		nth = 1
+	}
+	if nth < 0 {
+		return "", fmt.Errorf("--nth counts from 1, got %d\n", nth)
+	}
+
+	pipeline, resp, err := gateClient.ApplicationControllerApi.GetPipelineConfigUsingGET(gateClient.Context, s.application, s.pipeline)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("Pipeline %s not found in application %s\n", s.pipeline, s.application)
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Encountered an error querying pipeline %s, status code: %d\n", s.pipeline, resp.StatusCode)
+	}
+	configId, _ := pipeline["id"].(string)
+	if configId == "" {
+		return "", fmt.Errorf("Pipeline %s not found in application %s\n", s.pipeline, s.application)
+	}
+
+	query := map[string]interface{}{
+		"pipelineConfigIds": configId,
+		"limit":             int32(nth),
+	}
+	if len(statuses) > 0 {
+		query["statuses"] = strings.Join(statuses, ",")
+	}
+	executions, resp, err := gateClient.ExecutionsControllerApi.GetLatestExecutionsByConfigIdsUsingGET(gateClient.Context, query)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Encountered an error listing executions of pipeline %s, status code: %d\n", s.pipeline, resp.StatusCode)
+	}
+	if len(executions) < nth {
+		return "", fmt.Errorf("Pipeline %s has no %s\n", s.pipeline, s.describe(nth))
+	}
+	execution, _ := executions[nth-1].(map[string]interface{})
+	id, _ := execution["id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("Pipeline %s has no %s\n", s.pipeline, s.describe(nth))
+	}
+	return id, nil
+}
+
+func (s *executionSelector) describe(nth int) string {
+	kind := "execution"
+	switch {
+	case s.lastFailed:
+		kind = "failed execution"
+	case s.lastSucceeded:
+		kind = "succeeded execution"
+	case s.running:
+		kind = "running execution"
+	}
+	if nth == 1 {
+		return kind
+	}
+	return fmt.Sprintf("%s number %d", kind, nth)
+}
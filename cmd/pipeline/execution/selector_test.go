@@ -0,0 +1,164 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func TestExecutionSelector_lastFailed(t *testing.T) {
+	var requests []string
+	ts := testGateExecutionSelectorSuccess(&requests)
+	defer ts.Close()
+
+	rootCmd := getSelectorRootCmdForTest(NewGetCmd())
+	rootCmd.SetArgs([]string{"ex", "get", "-a", "app", "-n", "deploy", "--last-failed", "--gate-endpoint", ts.URL})
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	expected := []string{
+		"GET /executions limit=1 pipelineConfigIds=deploy-id statuses=TERMINAL,STOPPED,FAILED_CONTINUE",
+		"GET /executions executionIds=exec-1 limit=1",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestExecutionSelector_nth(t *testing.T) {
+	var requests []string
+	ts := testGateExecutionSelectorSuccess(&requests)
+	defer ts.Close()
+
+	rootCmd := getSelectorRootCmdForTest(NewCancelCmd())
+	rootCmd.SetArgs([]string{"ex", "cancel", "-a", "app", "-n", "deploy", "--running", "--nth", "2", "--gate-endpoint", ts.URL})
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	expected := []string{
+		"GET /executions limit=2 pipelineConfigIds=deploy-id statuses=RUNNING",
+		"PUT /pipelines/exec-2/cancel",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestExecutionSelector_latest(t *testing.T) {
+	var requests []string
+	ts := testGateExecutionSelectorSuccess(&requests)
+	defer ts.Close()
+
+	// Without a selector or an id, -a and -n pick the most recent execution.
+	rootCmd := getSelectorRootCmdForTest(NewGetCmd())
+	rootCmd.SetArgs([]string{"ex", "get", "-a", "app", "-n", "deploy", "--gate-endpoint", ts.URL})
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(requests) == 0 || requests[0] != "GET /executions limit=1 pipelineConfigIds=deploy-id" {
+		t.Fatalf("Expected the latest execution to be selected, got: %v", requests)
+	}
+}
+
+func TestExecutionSelector_id(t *testing.T) {
+	var requests []string
+	ts := testGateExecutionSelectorSuccess(&requests)
+	defer ts.Close()
+
+	// An id takes precedence over -a and -n, which the shell may add to every command.
+	rootCmd := getSelectorRootCmdForTest(NewGetCmd())
+	rootCmd.SetArgs([]string{"ex", "get", "exec-9", "-a", "app", "-n", "deploy", "--gate-endpoint", ts.URL})
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	expected := []string{"GET /executions executionIds=exec-9 limit=1"}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestExecutionSelector_flags(t *testing.T) {
+	var requests []string
+	ts := testGateExecutionSelectorSuccess(&requests)
+	defer ts.Close()
+
+	for _, args := range [][]string{
+		{"ex", "get", "exec-1", "-a", "app", "-n", "deploy", "--last"},
+		{"ex", "get", "-n", "deploy", "--last"},
+		{"ex", "get", "-a", "app", "-n", "deploy", "--last", "--running"},
+		{"ex", "get", "-a", "app", "-n", "deploy", "--nth", "-1"},
+		{"ex", "get", "-a", "app", "-n", "deploy", "--last-succeeded", "--nth", "3"},
+		{"ex", "get", "-a", "app", "-n", "missing", "--last"},
+	} {
+		rootCmd := getSelectorRootCmdForTest(NewGetCmd())
+		rootCmd.SetArgs(append(args, "--gate-endpoint", ts.URL))
+		if err := rootCmd.Execute(); err == nil {
+			t.Errorf("Expected %v to fail but it succeeded", args)
+		}
+	}
+}
+
+func getSelectorRootCmdForTest(currentCmd *cobra.Command) *cobra.Command {
+	rootCmd := getRootCmdForTest()
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(executionCmd)
+	return rootCmd
+}
+
+// testGateExecutionSelectorSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves a pipeline with two executions of each status and records the requests.
+func testGateExecutionSelectorSuccess(requests *[]string) *httptest.Server {
+	record := func(r *http.Request) {
+		request := r.Method + " " + r.URL.Path
+		if query := r.URL.Query(); len(query) > 0 {
+			var params []string
+			for _, key := range []string{"executionIds", "limit", "pipelineConfigIds", "statuses"} {
+				if value := query.Get(key); value != "" {
+					params = append(params, key+"="+value)
+				}
+			}
+			request += " " + strings.Join(params, " ")
+		}
+		*requests = append(*requests, request)
+	}
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"id": "deploy-id", "name": "deploy", "application": "app"}`)
+	}))
+	mux.Handle("/applications/app/pipelineConfigs/missing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	mux.Handle("/executions", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		fmt.Fprintln(w, `[{"id": "exec-1", "status": "TERMINAL"}, {"id": "exec-2", "status": "TERMINAL"}]`)
+	}))
+	mux.Handle("/pipelines/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+	}))
+	return httptest.NewServer(mux)
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+var (
+	treeExecutionShort = "Show the tree of parent and child executions of the specified execution"
+	treeExecutionLong  = "Show the execution with the provided id along with the execution that triggered it and every child pipeline execution, with their statuses and durations. Use --output jsonpath=... to get the tree as JSON"
+)
+
+// executionNode is an execution in a tree of parent and child pipeline executions.
+type executionNode struct {
+	Id          string           `json:"id"`
+	Name        string           `json:"name"`
+	Application string           `json:"application"`
+	Status      string           `json:"status"`
+	DurationMs  int64            `json:"durationMs,omitempty"`
+	Stage       string           `json:"stage,omitempty"`
+	Children    []*executionNode `json:"children,omitempty"`
+}
+
+func NewTreeCmd() *cobra.Command {
+	selector := executionSelector{}
+	cmd := &cobra.Command{
+		Use:   "tree",
+		Short: treeExecutionShort,
+		Long:  treeExecutionLong,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return treeExecution(cmd, selector, args)
+		},
+	}
+	addSelectorFlags(cmd, &selector)
+	return cmd
+}
+
+func treeExecution(cmd *cobra.Command, selector executionSelector, args []string) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	id, err := selector.executionId(gateClient, args)
+	if err != nil {
+		return err
+	}
+
+	fetched := map[string]map[string]interface{}{}
+	fetch := func(id string) (map[string]interface{}, error) {
+		if execution, exists := fetched[id]; exists {
+			return execution, nil
+		}
+		execution, err := getPipelineExecution(gateClient, id)
+		if err != nil {
+			return nil, err
+		}
+		fetched[id] = execution
+		return execution, nil
+	}
+
+	// Walk up to the execution that started the hierarchy.
+	rootId := id
+	for {
+		execution, err := fetch(rootId)
+		if err != nil {
+			return err
+		}
+		parentId := parentExecutionId(execution)
+		if parentId == "" || fetched[parentId] != nil {
+			break
+		}
+		rootId = parentId
+	}
+
+	root, err := buildExecutionTree(rootId, "", fetch, map[string]bool{})
+	if err != nil {
+		return err
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(root, util.UI.OutputFormat)
+		return nil
+	}
+	for _, line := range renderExecutionTree(root, id) {
+		util.UI.Output(line)
+	}
+	return nil
+}
+
+func getPipelineExecution(gateClient *gateclient.GatewayClient, id string) (map[string]interface{}, error) {
+	payload, resp, err := gateClient.PipelineControllerApi.GetPipelineUsingGET(gateClient.Context, id)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("Execution %s not found\n", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error getting execution %s, status code: %d\n", id, resp.StatusCode)
+	}
+	execution, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Unexpected response for execution %s: %v\n", id, payload)
+	}
+	return execution, nil
+}
+
+// buildExecutionTree follows the pipeline stages of the execution down to their child executions.
+func buildExecutionTree(id string, stage string, fetch func(string) (map[string]interface{}, error), visited map[string]bool) (*executionNode, error) {
+	visited[id] = true
+	execution, err := fetch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &executionNode{
+		Id:         id,
+		Stage:      stage,
+		DurationMs: executionDuration(execution),
+	}
+	node.Name, _ = execution["name"].(string)
+	node.Application, _ = execution["application"].(string)
+	node.Status, _ = execution["status"].(string)
+
+	stages, _ := execution["stages"].([]interface{})
+	for _, rawStage := range stages {
+		s, ok := rawStage.(map[string]interface{})
+		if !ok || s["type"] != "pipeline" {
+			continue
+		}
+		context, _ := s["context"].(map[string]interface{})
+		childId, _ := context["executionId"].(string)
+		if childId == "" || visited[childId] {
+			continue
+		}
+		stageName, _ := s["name"].(string)
+		child, err := buildExecutionTree(childId, stageName, fetch, visited)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+	return node, nil
+}
+
+// parentExecutionId returns the id of the execution whose pipeline stage started the execution.
+func parentExecutionId(execution map[string]interface{}) string {
+	trigger, _ := execution["trigger"].(map[string]interface{})
+	if trigger["type"] != "pipeline" {
+		return ""
+	}
+	if parent, ok := trigger["parentExecution"].(map[string]interface{}); ok {
+		if id, _ := parent["id"].(string); id != "" {
+			return id
+		}
+	}
+	id, _ := trigger["parentExecutionId"].(string)
+	return id
+}
+
+// executionDuration returns how long the execution ran, or has been running, in milliseconds.
+func executionDuration(execution map[string]interface{}) int64 {
+	start, _ := execution["startTime"].(float64)
+	if start == 0 {
+		return 0
+	}
+	end, _ := execution["endTime"].(float64)
+	if end == 0 {
+		end = float64(time.Now().UnixNano() / int64(time.Millisecond))
+	}
+	return int64(end - start)
+}
+
+// renderExecutionTree draws the tree, marking the requested execution.
+func renderExecutionTree(root *executionNode, selectedId string) []string {
+	var lines []string
+	var render func(node *executionNode, prefix string, connector string, childPrefix string)
+	render = func(node *executionNode, prefix string, connector string, childPrefix string) {
+		lines = append(lines, prefix+connector+describeExecution(node, node.Id == selectedId))
+		for i, child := range node.Children {
+			if i == len(node.Children)-1 {
+				render(child, prefix+childPrefix, "└── ", "    ")
+			} else {
+				render(child, prefix+childPrefix, "├── ", "│   ")
+			}
+		}
+	}
+	render(root, "", "", "")
+	return lines
+}
+
+func describeExecution(node *executionNode, selected bool) string {
+	name := node.Name
+	if node.Application != "" {
+		name = node.Application + "/" + name
+	}
+	description := fmt.Sprintf("%s %s %s", name, colorizeStatus(node.Status), node.Id)
+	if node.DurationMs > 0 {
+		description += " " + (time.Duration(node.DurationMs) * time.Millisecond).Round(time.Second).String()
+	}
+	if node.Stage != "" && node.Stage != node.Name {
+		description += fmt.Sprintf(" (stage: %s)", node.Stage)
+	}
+	if selected {
+		description += " <="
+	}
+	return description
+}
+
+func colorizeStatus(status string) string {
+	color := "[yellow]"
+	switch strings.ToUpper(status) {
+	case "SUCCEEDED":
+		color = "[green]"
+	case "TERMINAL", "CANCELED", "STOPPED", "FAILED_CONTINUE":
+		color = "[red]"
+	}
+	return util.Colorize().Color(fmt.Sprintf("%s%s[reset]", color, status))
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spinnaker/spin/util"
+)
+
+func TestExecutionTree_basic(t *testing.T) {
+	ts := testGateExecutionTreeSuccess()
+	defer ts.Close()
+	currentCmd := NewTreeCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	// Exclude 'pipeline' since we are testing only the 'execution' subcommand.
+	args := []string{"ex", "tree", "child1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestExecutionTree_render(t *testing.T) {
+	util.InitUI(false, false, "")
+	executions := map[string]map[string]interface{}{}
+	for id, body := range treeExecutions {
+		var execution map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &execution); err != nil {
+			t.Fatalf("Could not parse execution %s: %s", id, err)
+		}
+		executions[id] = execution
+	}
+	fetch := func(id string) (map[string]interface{}, error) {
+		return executions[id], nil
+	}
+
+	root, err := buildExecutionTree("parent", "", fetch, map[string]bool{})
+	if err != nil {
+		t.Fatalf("Could not build tree: %s", err)
+	}
+	expected := []string{
+		"app/release SUCCEEDED parent 5m0s",
+		"├── app/deploy-us SUCCEEDED child1 1m0s <=",
+		"└── app/deploy-eu TERMINAL child2 2m0s",
+		"    └── app/smoke-test TERMINAL grandchild 30s (stage: Run smoke tests)",
+	}
+	lines := renderExecutionTree(root, "child1")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected tree:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestExecutionTree_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+	currentCmd := NewTreeCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "tree", "child1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+// testGateExecutionTreeSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves a parent execution with child and grandchild pipeline executions.
+func testGateExecutionTreeSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	for id, body := range treeExecutions {
+		execution := body
+		mux.Handle("/pipelines/"+id, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, strings.TrimSpace(execution))
+		}))
+	}
+	return httptest.NewServer(mux)
+}
+
+var treeExecutions = map[string]string{
+	"parent": `
+{
+  "id": "parent",
+  "name": "release",
+  "application": "app",
+  "status": "SUCCEEDED",
+  "startTime": 1000000,
+  "endTime": 1300000,
+  "trigger": {"type": "manual"},
+  "stages": [
+    {"name": "deploy-us", "type": "pipeline", "context": {"executionId": "child1"}},
+    {"name": "deploy-eu", "type": "pipeline", "context": {"executionId": "child2"}},
+    {"name": "Wait", "type": "wait", "context": {}}
+  ]
+}
+`,
+	"child1": `
+{
+  "id": "child1",
+  "name": "deploy-us",
+  "application": "app",
+  "status": "SUCCEEDED",
+  "startTime": 1000000,
+  "endTime": 1060000,
+  "trigger": {"type": "pipeline", "parentExecution": {"id": "parent"}},
+  "stages": []
+}
+`,
+	"child2": `
+{
+  "id": "child2",
+  "name": "deploy-eu",
+  "application": "app",
+  "status": "TERMINAL",
+  "startTime": 1000000,
+  "endTime": 1120000,
+  "trigger": {"type": "pipeline", "parentExecution": {"id": "parent"}},
+  "stages": [
+    {"name": "Run smoke tests", "type": "pipeline", "context": {"executionId": "grandchild"}}
+  ]
+}
+`,
+	"grandchild": `
+{
+  "id": "grandchild",
+  "name": "smoke-test",
+  "application": "app",
+  "status": "TERMINAL",
+  "startTime": 1000000,
+  "endTime": 1030000,
+  "trigger": {"type": "pipeline", "parentExecution": {"id": "child2"}},
+  "stages": []
+}
+`,
+}
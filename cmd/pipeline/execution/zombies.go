@@ -0,0 +1,304 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type ZombiesOptions struct {
+	application string
+	stuckFor    time.Duration
+	cancel      bool
+	yes         bool
+}
+
+var (
+	zombiesShort   = "Find running executions that have stopped making progress"
+	zombiesLong    = "Find executions that are still RUNNING but whose stages and tasks have not started or finished anything for at least --stuck-for, and print what each one is stuck on. Executions waiting on a manual judgment, wait or bake stage are not considered stuck. Scans every application unless --application is given. Use --cancel to force cancel them, after confirming each one unless --yes is set"
+	zombiesExample = "usage: spin pipeline execution zombies [options]"
+)
+
+// zombieExecution is a running execution that has not progressed for longer than the threshold.
+type zombieExecution struct {
+	Id           string `json:"id"`
+	Application  string `json:"application"`
+	Name         string `json:"name"`
+	LastProgress int64  `json:"lastProgress,omitempty"`
+	StuckForMs   int64  `json:"stuckForMs"`
+	Stage        string `json:"stage,omitempty"`
+	Task         string `json:"task,omitempty"`
+	Canceled     bool   `json:"canceled,omitempty"`
+}
+
+func NewZombiesCmd() *cobra.Command {
+	options := ZombiesOptions{}
+	cmd := &cobra.Command{
+		Use:     "zombies",
+		Short:   zombiesShort,
+		Long:    zombiesLong,
+		Example: zombiesExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return findZombies(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.application, "application", "a", "", "(optional) application to look for stuck executions in, defaults to all applications")
+	cmd.PersistentFlags().DurationVar(&options.stuckFor, "stuck-for", 6*time.Hour, "how long an execution must have gone without progress to be reported")
+	cmd.PersistentFlags().BoolVar(&options.cancel, "cancel", false, "force cancel each stuck execution")
+	cmd.PersistentFlags().BoolVarP(&options.yes, "yes", "y", false, "cancel without asking for confirmation")
+
+	return cmd
+}
+
+func findZombies(cmd *cobra.Command, options ZombiesOptions) error {
+	if options.stuckFor <= 0 {
+		return fmt.Errorf("--stuck-for must be positive, got %s\n", options.stuckFor)
+	}
+
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	applications := []string{options.application}
+	if options.application == "" {
+		applications, err = applicationNames(gateClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	var zombies []zombieExecution
+	for _, application := range applications {
+		executions, resp, err := gateClient.ApplicationControllerApi.GetPipelinesUsingGET(gateClient.Context,
+			application,
+			map[string]interface{}{"statuses": "RUNNING"})
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error listing running executions of application %s, status code: %d\n",
+				application,
+				resp.StatusCode)
+		}
+		zombies = append(zombies, zombieExecutions(executions, now, options.stuckFor)...)
+	}
+
+	if options.cancel {
+		for i, zombie := range zombies {
+			if !options.yes && !util.UI.Confirm(fmt.Sprintf("Force cancel execution %s of %s/%s?", zombie.Id, zombie.Application, zombie.Name)) {
+				continue
+			}
+			reason := fmt.Sprintf("No progress for %s", formatStuckFor(zombie.StuckForMs))
+			resp, err := gateClient.PipelineControllerApi.CancelPipelineUsingPUT1(gateClient.Context,
+				zombie.Id,
+				map[string]interface{}{"reason": reason, "force": true})
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("Encountered an error canceling execution %s, status code: %d\n", zombie.Id, resp.StatusCode)
+			}
+			zombies[i].Canceled = true
+		}
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(zombies, util.UI.OutputFormat)
+		return nil
+	}
+	if len(zombies) == 0 {
+		util.UI.Info(util.Colorize().Color(fmt.Sprintf("[reset][bold][green]No executions have been stuck for %s", options.stuckFor)))
+		return nil
+	}
+	for _, zombie := range zombies {
+		util.UI.Output(describeZombie(zombie))
+	}
+	return nil
+}
+
+func applicationNames(gateClient *gateclient.GatewayClient) ([]string, error) {
+	applications, resp, err := gateClient.ApplicationControllerApi.GetAllApplicationsUsingGET(gateClient.Context, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error listing applications, status code: %d\n", resp.StatusCode)
+	}
+	var names []string
+	for _, application := range applications {
+		if name, _ := application.(map[string]interface{})["name"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// zombieExecutions returns the running executions whose last stage or task
+// start or end is more than stuckFor before now, longest stuck first. Executions
+// parked on a stage that waits by design are never zombies.
+func zombieExecutions(executions []interface{}, now time.Time, stuckFor time.Duration) []zombieExecution {
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	var zombies []zombieExecution
+	for _, e := range executions {
+		execution, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if status, _ := execution["status"].(string); status != "RUNNING" {
+			continue
+		}
+		if isWaiting(execution) {
+			continue
+		}
+		lastProgress := lastExecutionProgress(execution)
+		if lastProgress == 0 || nowMs-lastProgress < int64(stuckFor/time.Millisecond) {
+			continue
+		}
+		zombie := zombieExecution{
+			LastProgress: lastProgress,
+			StuckForMs:   nowMs - lastProgress,
+		}
+		zombie.Id, _ = execution["id"].(string)
+		zombie.Application, _ = execution["application"].(string)
+		zombie.Name, _ = execution["name"].(string)
+		zombie.Stage, zombie.Task = runningStep(execution)
+		zombies = append(zombies, zombie)
+	}
+	sort.SliceStable(zombies, func(i, j int) bool {
+		return zombies[i].StuckForMs > zombies[j].StuckForMs
+	})
+	return zombies
+}
+
+// lastExecutionProgress is the latest start or end time of the execution, its stages and their tasks.
+func lastExecutionProgress(execution map[string]interface{}) int64 {
+	var last int64
+	progress := func(element map[string]interface{}) {
+		for _, key := range []string{"startTime", "endTime"} {
+			if t, _ := element[key].(float64); int64(t) > last {
+				last = int64(t)
+			}
+		}
+	}
+	progress(execution)
+	stages, _ := execution["stages"].([]interface{})
+	for _, s := range stages {
+		stage, _ := s.(map[string]interface{})
+		progress(stage)
+		tasks, _ := stage["tasks"].([]interface{})
+		for _, t := range tasks {
+			task, _ := t.(map[string]interface{})
+			progress(task)
+		}
+	}
+	return last
+}
+
+// waitingStageTypes are stages that legitimately run for hours without progress.
+var waitingStageTypes = map[string]bool{
+	"manualJudgment": true,
+	"wait":           true,
+	"bake":           true,
+}
+
+// isWaiting returns true when a running stage of the execution waits by design: a
+// manual judgment, wait or bake, or any stage with a waitTime or a pending judgment.
+func isWaiting(execution map[string]interface{}) bool {
+	stages, _ := execution["stages"].([]interface{})
+	for _, s := range stages {
+		stage, _ := s.(map[string]interface{})
+		if status, _ := stage["status"].(string); status != "RUNNING" {
+			continue
+		}
+		if stageType, _ := stage["type"].(string); waitingStageTypes[stageType] {
+			return true
+		}
+		context, _ := stage["context"].(map[string]interface{})
+		if _, exists := context["waitTime"]; exists {
+			return true
+		}
+		if judgment, exists := context["judgmentStatus"]; exists && judgment == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runningStep returns the name of the running stage the execution is stuck in and its running task.
+func runningStep(execution map[string]interface{}) (string, string) {
+	stages, _ := execution["stages"].([]interface{})
+	for _, s := range stages {
+		stage, _ := s.(map[string]interface{})
+		if status, _ := stage["status"].(string); status != "RUNNING" {
+			continue
+		}
+		// Synthetic parent stages run while their children do, prefer the innermost running stage.
+		if hasRunningChild(stages, stage["id"]) {
+			continue
+		}
+		name, _ := stage["name"].(string)
+		tasks, _ := stage["tasks"].([]interface{})
+		for _, t := range tasks {
+			task, _ := t.(map[string]interface{})
+			if status, _ := task["status"].(string); status == "RUNNING" {
+				taskName, _ := task["name"].(string)
+				return name, taskName
+			}
+		}
+		return name, ""
+	}
+	return "", ""
+}
+
+func hasRunningChild(stages []interface{}, id interface{}) bool {
+	for _, s := range stages {
+		stage, _ := s.(map[string]interface{})
+		if stage["parentStageId"] == id && stage["status"] == "RUNNING" {
+			return true
+		}
+	}
+	return false
+}
+
+func describeZombie(zombie zombieExecution) string {
+	line := fmt.Sprintf("%s/%s %s no progress for %s", zombie.Application, zombie.Name, zombie.Id, formatStuckFor(zombie.StuckForMs))
+	switch {
+	case zombie.Stage != "" && zombie.Task != "":
+		line += fmt.Sprintf(" (stage: %s, task: %s)", zombie.Stage, zombie.Task)
+	case zombie.Stage != "":
+		line += fmt.Sprintf(" (stage: %s, no running task)", zombie.Stage)
+	default:
+		line += " (no running stage)"
+	}
+	if zombie.Canceled {
+		line += util.Colorize().Color(" [reset][bold][yellow]canceled")
+	}
+	return line
+}
+
+func formatStuckFor(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Minute).String()
+}
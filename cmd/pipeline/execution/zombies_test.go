@@ -0,0 +1,332 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spinnaker/spin/util"
+)
+
+func TestExecutionZombies_basic(t *testing.T) {
+	var canceled []string
+	ts := testGateExecutionZombiesSuccess(&canceled)
+	defer ts.Close()
+	currentCmd := NewZombiesCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "zombies", "-a", "app", "--stuck-for", "6h", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(canceled) != 0 {
+		t.Fatalf("Expected nothing to be canceled without --cancel, got: %v", canceled)
+	}
+}
+
+func TestExecutionZombies_allApplications(t *testing.T) {
+	var canceled []string
+	ts := testGateExecutionZombiesSuccess(&canceled)
+	defer ts.Close()
+	currentCmd := NewZombiesCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "zombies", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestExecutionZombies_cancel(t *testing.T) {
+	var canceled []string
+	ts := testGateExecutionZombiesSuccess(&canceled)
+	defer ts.Close()
+	currentCmd := NewZombiesCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	// Every fixture execution is long past the threshold, but only those not waiting by design are canceled.
+	args := []string{"ex", "zombies", "-a", "app", "--cancel", "--yes", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	expected := []string{
+		"PUT /pipelines/stuck/cancel force=true",
+		"PUT /pipelines/custom/cancel force=true",
+	}
+	if strings.Join(canceled, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(canceled, "\n"))
+	}
+}
+
+func TestExecutionZombies_flags(t *testing.T) {
+	var canceled []string
+	ts := testGateExecutionZombiesSuccess(&canceled)
+	defer ts.Close()
+	currentCmd := NewZombiesCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "zombies", "-a", "app", "--stuck-for", "0s", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestExecutionZombies_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+	currentCmd := NewZombiesCmd()
+	rootCmd := getRootCmdForTest()
+
+	executionCmd := NewExecutionCmd(os.Stdout)
+	executionCmd.AddCommand(currentCmd)
+
+	rootCmd.AddCommand(executionCmd)
+
+	args := []string{"ex", "zombies", "-a", "app", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestExecutionZombies_detect(t *testing.T) {
+	util.InitUI(false, false, "")
+	var executions []interface{}
+	if err := json.Unmarshal([]byte(zombieExecutionsJson), &executions); err != nil {
+		t.Fatalf("Could not parse executions: %s", err)
+	}
+
+	// Two hours after the fixture's latest update.
+	now := time.Unix(0, 1577880000000*int64(time.Millisecond))
+	zombies := zombieExecutions(executions, now, 6*time.Hour)
+	var lines []string
+	for _, zombie := range zombies {
+		lines = append(lines, describeZombie(zombie))
+	}
+	expected := []string{
+		"app/deploy stuck no progress for 8h0m0s (stage: Deploy in us-east-1, task: waitForUpInstances)",
+		"app/running custom no progress for 7h0m0s (stage: Check, no running task)",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected zombies:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestExecutionZombies_waiting(t *testing.T) {
+	var executions []interface{}
+	if err := json.Unmarshal([]byte(zombieExecutionsJson), &executions); err != nil {
+		t.Fatalf("Could not parse executions: %s", err)
+	}
+
+	waiting := map[string]bool{}
+	for _, e := range executions {
+		execution := e.(map[string]interface{})
+		waiting[execution["id"].(string)] = isWaiting(execution)
+	}
+	expected := map[string]bool{
+		"stuck":       false,
+		"waiting":     true,
+		"progressing": true,
+		"sleeping":    true,
+		"judging":     true,
+		"custom":      false,
+	}
+	for id, isWaiting := range expected {
+		if waiting[id] != isWaiting {
+			t.Errorf("Expected execution %s waiting to be %t, got %t", id, isWaiting, waiting[id])
+		}
+	}
+}
+
+// testGateExecutionZombiesSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves running executions of one application and records cancellations.
+func testGateExecutionZombiesSuccess(canceled *[]string) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"name": "app"}]`)
+	}))
+	mux.Handle("/applications/app/pipelines", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("statuses") != "RUNNING" {
+			http.Error(w, "Expected running executions to be requested", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, strings.TrimSpace(zombieExecutionsJson))
+	}))
+	mux.Handle("/pipelines/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*canceled = append(*canceled, fmt.Sprintf("%s %s force=%s", r.Method, r.URL.Path, r.URL.Query().Get("force")))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const zombieExecutionsJson = `
+[
+  {
+    "id": "stuck",
+    "application": "app",
+    "name": "deploy",
+    "status": "RUNNING",
+    "startTime": 1577844000000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Deploy",
+        "type": "deploy",
+        "status": "RUNNING",
+        "startTime": 1577844000000,
+        "tasks": []
+      },
+      {
+        "id": "s2",
+        "parentStageId": "s1",
+        "name": "Deploy in us-east-1",
+        "type": "createServerGroup",
+        "status": "RUNNING",
+        "startTime": 1577844000000,
+        "tasks": [
+          {"name": "createServerGroup", "status": "SUCCEEDED", "startTime": 1577844000000, "endTime": 1577851200000},
+          {"name": "waitForUpInstances", "status": "RUNNING", "startTime": 1577851200000}
+        ]
+      }
+    ]
+  },
+  {
+    "id": "waiting",
+    "application": "app",
+    "name": "approve",
+    "status": "RUNNING",
+    "startTime": 1577851200000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Manual Judgment",
+        "type": "manualJudgment",
+        "status": "RUNNING",
+        "startTime": 1577854800000,
+        "tasks": []
+      }
+    ]
+  },
+  {
+    "id": "progressing",
+    "application": "app",
+    "name": "bake",
+    "status": "RUNNING",
+    "startTime": 1577844000000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Bake",
+        "type": "bake",
+        "status": "RUNNING",
+        "startTime": 1577844000000,
+        "tasks": [
+          {"name": "createBake", "status": "SUCCEEDED", "startTime": 1577844000000, "endTime": 1577872800000},
+          {"name": "monitorBake", "status": "RUNNING", "startTime": 1577872800000}
+        ]
+      }
+    ]
+  },
+  {
+    "id": "sleeping",
+    "application": "app",
+    "name": "soak",
+    "status": "RUNNING",
+    "startTime": 1577851200000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Soak",
+        "type": "runJob",
+        "status": "RUNNING",
+        "startTime": 1577854800000,
+        "context": {"waitTime": 86400},
+        "tasks": []
+      }
+    ]
+  },
+  {
+    "id": "judging",
+    "application": "app",
+    "name": "approve-custom",
+    "status": "RUNNING",
+    "startTime": 1577851200000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Approve",
+        "type": "approval",
+        "status": "RUNNING",
+        "startTime": 1577854800000,
+        "context": {"judgmentStatus": null},
+        "tasks": []
+      }
+    ]
+  },
+  {
+    "id": "custom",
+    "application": "app",
+    "name": "running",
+    "status": "RUNNING",
+    "startTime": 1577851200000,
+    "stages": [
+      {
+        "id": "s1",
+        "name": "Check",
+        "type": "checkPreconditions",
+        "status": "RUNNING",
+        "startTime": 1577854800000,
+        "context": {"judgmentStatus": "continue"},
+        "tasks": []
+      }
+    ]
+  }
+]
+`
@@ -0,0 +1,301 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+type FmtOptions struct {
+	*pipelineOptions
+	write   bool
+	check   bool
+	stripId bool
+}
+
+var (
+	fmtPipelineShort   = "Format pipeline, pipeline template and canary config files"
+	fmtPipelineLong    = "Rewrite pipeline, pipeline template and canary config files in a canonical form: server managed fields removed, keys sorted, stages ordered by their dependencies and consistent indentation"
+	fmtPipelineExample = "usage: spin pipeline fmt [options] files..."
+)
+
+// serverManagedKeys are set by Front50 or Kayenta on every save and only add noise to diffs.
+var serverManagedKeys = []string{
+	"createTs",
+	"updateTs",
+	"lastModifiedBy",
+	"createdTimestamp",
+	"createdTimestampIso",
+	"updatedTimestamp",
+	"updatedTimestampIso",
+}
+
+func NewFmtCmd(pipelineOptions pipelineOptions) *cobra.Command {
+	options := FmtOptions{
+		pipelineOptions: &pipelineOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "fmt",
+		Short:   fmtPipelineShort,
+		Long:    fmtPipelineLong,
+		Example: fmtPipelineExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return formatPipelineFiles(cmd, options, args)
+		},
+	}
+
+	cmd.PersistentFlags().BoolVarP(&options.write, "write", "w", false, "write the result to the source files instead of stdout")
+	cmd.PersistentFlags().BoolVar(&options.check, "check", false, "list files that are not formatted and fail if there are any")
+	cmd.PersistentFlags().BoolVar(&options.stripId, "strip-id", false, "also remove the server assigned pipeline or canary config id")
+
+	return cmd
+}
+
+func formatPipelineFiles(cmd *cobra.Command, options FmtOptions, args []string) error {
+	util.InitUI(false, false, "")
+
+	if options.write && options.check {
+		return errors.New("flags 'write' and 'check' cannot be used together")
+	}
+
+	if len(args) == 0 {
+		if options.write {
+			return errors.New("no files supplied to write, exiting")
+		}
+		content, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatDocument(content, options.stripId)
+		if err != nil {
+			return err
+		}
+		if options.check && !bytes.Equal(content, formatted) {
+			return errors.New("input is not formatted")
+		}
+		if !options.check {
+			util.UI.Output(strings.TrimSuffix(string(formatted), "\n"))
+		}
+		return nil
+	}
+
+	var unformatted []string
+	for _, path := range args {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatDocument(content, options.stripId)
+		if err != nil {
+			return fmt.Errorf("Could not format %s: %v\n", path, err)
+		}
+
+		switch {
+		case options.check:
+			if !bytes.Equal(content, formatted) {
+				unformatted = append(unformatted, path)
+				util.UI.Output(path)
+			}
+		case options.write:
+			if bytes.Equal(content, formatted) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(path, formatted, info.Mode()); err != nil {
+				return err
+			}
+		default:
+			util.UI.Output(strings.TrimSuffix(string(formatted), "\n"))
+		}
+	}
+
+	if len(unformatted) > 0 {
+		return fmt.Errorf("%d file(s) not formatted, run 'spin pipeline fmt -w' to fix them\n", len(unformatted))
+	}
+	return nil
+}
+
+// formatDocument returns the canonical form of a pipeline, pipeline template or canary config.
+func formatDocument(content []byte, stripId bool) ([]byte, error) {
+	doc, err := parseDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range serverManagedKeys {
+		delete(doc, key)
+	}
+
+	if pipeline, isTemplate := doc["pipeline"].(map[string]interface{}); isTemplate {
+		// A template id is chosen by its author, so it is kept even with --strip-id.
+		for _, key := range serverManagedKeys {
+			delete(pipeline, key)
+		}
+		sortStages(pipeline)
+	} else if stripId {
+		delete(doc, "id")
+	}
+	sortStages(doc)
+
+	return encodeDocument(doc)
+}
+
+// parseDocument decodes a JSON object, keeping numbers as written so that
+// timestamps and other large values survive a round trip.
+func parseDocument(content []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("No json object to parse")
+	}
+	return doc, nil
+}
+
+// encodeDocument encodes a JSON object with sorted keys and two space indentation.
+func encodeDocument(doc interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stageRef returns the reference other stages use to depend on the stage.
+func stageRef(stage map[string]interface{}) string {
+	if refId, exists := stage["refId"]; exists && refId != nil {
+		return fmt.Sprint(refId)
+	}
+	if id, exists := stage["id"]; exists && id != nil {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
+
+// stageDependencies returns the references of the stages the stage depends on.
+func stageDependencies(stage map[string]interface{}) []string {
+	rawDeps, exists := stage["requisiteStageRefIds"].([]interface{})
+	if !exists {
+		rawDeps, _ = stage["dependsOn"].([]interface{})
+	}
+	deps := make([]string, 0, len(rawDeps))
+	for _, dep := range rawDeps {
+		deps = append(deps, fmt.Sprint(dep))
+	}
+	return deps
+}
+
+// sortStages orders the stages of a pipeline by their depth in the stage graph, breaking
+// ties by reference. Stage lists that do not form a graph are left untouched.
+func sortStages(pipeline map[string]interface{}) {
+	rawStages, exists := pipeline["stages"].([]interface{})
+	if !exists {
+		return
+	}
+
+	stages := map[string]map[string]interface{}{}
+	for _, rawStage := range rawStages {
+		stage, ok := rawStage.(map[string]interface{})
+		if !ok {
+			return
+		}
+		ref := stageRef(stage)
+		if _, duplicate := stages[ref]; ref == "" || duplicate {
+			return
+		}
+		stages[ref] = stage
+	}
+
+	depths := map[string]int{}
+	visiting := map[string]bool{}
+	var depth func(ref string) (int, bool)
+	depth = func(ref string) (int, bool) {
+		if d, known := depths[ref]; known {
+			return d, true
+		}
+		if visiting[ref] {
+			return 0, false // Cycle.
+		}
+		visiting[ref] = true
+		d := 0
+		for _, dep := range stageDependencies(stages[ref]) {
+			if _, exists := stages[dep]; !exists {
+				continue
+			}
+			depDepth, ok := depth(dep)
+			if !ok {
+				return 0, false
+			}
+			if depDepth+1 > d {
+				d = depDepth + 1
+			}
+		}
+		visiting[ref] = false
+		depths[ref] = d
+		return d, true
+	}
+
+	for ref := range stages {
+		if _, ok := depth(ref); !ok {
+			return
+		}
+	}
+
+	sort.SliceStable(rawStages, func(i, j int) bool {
+		refI := stageRef(rawStages[i].(map[string]interface{}))
+		refJ := stageRef(rawStages[j].(map[string]interface{}))
+		if depths[refI] != depths[refJ] {
+			return depths[refI] < depths[refJ]
+		}
+		return refLess(refI, refJ)
+	})
+}
+
+// refLess compares stage references numerically when both are numbers, as Deck assigns them.
+func refLess(a, b string) bool {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return numA < numB
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	default:
+		return a < b
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPipelineFmt_write(t *testing.T) {
+	tempFile := tempPipelineFile(unformattedPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "fmt", "-w", tempFile.Name()}
+	currentCmd := NewFmtCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	formatted, err := ioutil.ReadFile(tempFile.Name())
+	if err != nil {
+		t.Fatalf("Could not read formatted file: %s", err)
+	}
+	if string(formatted) != strings.TrimPrefix(formattedPipelineJsonStr, "\n") {
+		t.Fatalf("Unexpected formatted pipeline:\n%s", formatted)
+	}
+}
+
+func TestPipelineFmt_checkUnformatted(t *testing.T) {
+	tempFile := tempPipelineFile(unformattedPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "fmt", "--check", tempFile.Name()}
+	currentCmd := NewFmtCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected check to fail for an unformatted file")
+	}
+}
+
+func TestPipelineFmt_checkFormatted(t *testing.T) {
+	tempFile := tempPipelineFile(strings.TrimPrefix(formattedPipelineJsonStr, "\n"))
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "fmt", "--check", tempFile.Name()}
+	currentCmd := NewFmtCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineFmt_stripId(t *testing.T) {
+	formatted, err := formatDocument([]byte(unformattedPipelineJsonStr), true)
+	if err != nil {
+		t.Fatalf("Format failed with: %s", err)
+	}
+	doc, _ := parseDocument(formatted)
+	if _, exists := doc["id"]; exists {
+		t.Fatalf("Expected id to be removed, got: %s", formatted)
+	}
+}
+
+func TestPipelineFmt_templateKeepsId(t *testing.T) {
+	formatted, err := formatDocument([]byte(`{"id": "tmpl", "schema": "v2", "pipeline": {"stages": [], "updateTs": "1"}}`), true)
+	if err != nil {
+		t.Fatalf("Format failed with: %s", err)
+	}
+	doc, _ := parseDocument(formatted)
+	if doc["id"] != "tmpl" {
+		t.Fatalf("Expected template id to be kept, got: %s", formatted)
+	}
+	if _, exists := doc["pipeline"].(map[string]interface{})["updateTs"]; exists {
+		t.Fatalf("Expected template pipeline updateTs to be removed, got: %s", formatted)
+	}
+}
+
+const unformattedPipelineJsonStr = `
+{
+    "updateTs": "1520879791608",
+    "name": "pipeline1",
+    "id": "pipeline1",
+    "lastModifiedBy": "anonymous",
+    "application": "app",
+    "stages": [
+        {"type": "manualJudgment", "refId": "3", "requisiteStageRefIds": ["2", "10"], "name": "Judge"},
+        {"type": "wait", "refId": "10", "requisiteStageRefIds": [], "name": "Wait more", "waitTime": 1550686554744},
+        {"type": "wait", "refId": "2", "requisiteStageRefIds": [], "name": "Wait <5s>", "waitTime": 5}
+    ]
+}
+`
+
+const formattedPipelineJsonStr = `
+{
+  "application": "app",
+  "id": "pipeline1",
+  "name": "pipeline1",
+  "stages": [
+    {
+      "name": "Wait <5s>",
+      "refId": "2",
+      "requisiteStageRefIds": [],
+      "type": "wait",
+      "waitTime": 5
+    },
+    {
+      "name": "Wait more",
+      "refId": "10",
+      "requisiteStageRefIds": [],
+      "type": "wait",
+      "waitTime": 1550686554744
+    },
+    {
+      "name": "Judge",
+      "refId": "3",
+      "requisiteStageRefIds": [
+        "2",
+        "10"
+      ],
+      "type": "manualJudgment"
+    }
+  ]
+}
+`
@@ -0,0 +1,439 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+	"gopkg.in/yaml.v2"
+)
+
+type MergeOptions struct {
+	*pipelineOptions
+	base   string
+	local  string
+	remote string
+	prefer string
+	dryRun bool
+}
+
+var (
+	mergePipelineShort   = "Merge changes made to the saved pipeline into a local pipeline file"
+	mergePipelineLong    = "Three-way merge the saved pipeline into a local pipeline file, using the pipeline last saved from the file as the common base. Stages are matched by refId, triggers and notifications by what they listen to or notify, and changes made on only one side are kept. Conflicting changes are reported and nothing is written unless --prefer picks a side"
+	mergePipelineExample = "usage: spin pipeline merge --base last-applied.json --local pipeline.json [--remote live]"
+)
+
+const remoteLive = "live"
+
+// mergeIdentities are the fields identifying the elements of pipeline lists in a three-way
+// merge. The first field an element sets is used, and "type" is prefixed to the identity of
+// triggers and notifications.
+var mergeIdentities = map[string][]string{
+	"stages":            {"refId", "name"},
+	"parameterConfig":   {"name"},
+	"expectedArtifacts": {"id", "displayName"},
+	"triggers":          {"id", "project", "repository", "job", "pipeline", "cronExpression", "subscriptionName", "source", "application"},
+	"notifications":     {"address"},
+}
+
+// mergeValue is a field in one of the merged documents, which may not be set.
+type mergeValue struct {
+	value   interface{}
+	present bool
+}
+
+// mergeConflict is a field changed differently in the local and remote pipelines.
+type mergeConflict struct {
+	path   string
+	base   mergeValue
+	local  mergeValue
+	remote mergeValue
+}
+
+type pipelineMerge struct {
+	prefer    string
+	applied   []string
+	conflicts []mergeConflict
+}
+
+func NewMergeCmd(pipelineOptions pipelineOptions) *cobra.Command {
+	options := MergeOptions{
+		pipelineOptions: &pipelineOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "merge",
+		Short:   mergePipelineShort,
+		Long:    mergePipelineLong,
+		Example: mergePipelineExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return mergePipeline(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&options.base, "base", "", "path to the pipeline as last saved from the local file")
+	cmd.PersistentFlags().StringVar(&options.local, "local", "", "path to the local pipeline file to merge into")
+	cmd.PersistentFlags().StringVar(&options.remote, "remote", remoteLive, "path to the remote pipeline, or 'live' to get the saved pipeline from Spinnaker")
+	cmd.PersistentFlags().StringVar(&options.prefer, "prefer", "", "(optional) resolve conflicts with the local or remote change")
+	cmd.PersistentFlags().BoolVar(&options.dryRun, "dry-run", false, "print the merged pipeline instead of writing the local file")
+
+	return cmd
+}
+
+func mergePipeline(cmd *cobra.Command, options MergeOptions) error {
+	if options.base == "" || options.local == "" {
+		return errors.New("one of required parameters 'base' or 'local' not set")
+	}
+	if options.prefer != "" && options.prefer != "local" && options.prefer != "remote" {
+		return fmt.Errorf("Unknown value %s for 'prefer', expected local or remote\n", options.prefer)
+	}
+
+	base, err := readPipelineDocument(options.base)
+	if err != nil {
+		return err
+	}
+	local, err := readPipelineDocument(options.local)
+	if err != nil {
+		return err
+	}
+
+	var remote map[string]interface{}
+	if options.remote == remoteLive {
+		remote, err = livePipeline(cmd, local)
+	} else {
+		util.InitUI(false, false, "")
+		remote, err = readPipelineDocument(options.remote)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Decode numbers the same way for JSON, YAML and live pipelines so equal values compare equal.
+	for _, doc := range []*map[string]interface{}{&base, &local, &remote} {
+		if *doc, err = comparableDocument(*doc); err != nil {
+			return err
+		}
+	}
+
+	merge := &pipelineMerge{prefer: options.prefer}
+	merged := merge.mergeDocuments(base, local, remote)
+
+	if len(merge.conflicts) > 0 && options.prefer == "" {
+		lines := []string{fmt.Sprintf("%d conflicting change(s), resolve them in %s or pass --prefer local|remote:", len(merge.conflicts), options.local)}
+		for _, conflict := range merge.conflicts {
+			lines = append(lines, "  "+conflict.describe())
+		}
+		util.UI.Error(strings.Join(lines, "\n") + "\n")
+		return fmt.Errorf("Could not merge %s\n", options.local)
+	}
+
+	content, err := encodeMergedDocument(options.local, merged)
+	if err != nil {
+		return err
+	}
+	if options.dryRun {
+		util.UI.Output(strings.TrimSuffix(string(content), "\n"))
+		return nil
+	}
+	if len(merge.applied) == 0 && len(merge.conflicts) == 0 {
+		util.UI.Info(fmt.Sprintf("No remote changes to merge into %s.", options.local))
+		return nil
+	}
+	info, err := os.Stat(options.local)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(options.local, content, info.Mode()); err != nil {
+		return err
+	}
+
+	lines := []string{fmt.Sprintf("Merged %d remote change(s) into %s:", len(merge.applied), options.local)}
+	for _, path := range merge.applied {
+		lines = append(lines, "  "+path)
+	}
+	if len(merge.conflicts) > 0 {
+		lines = append(lines, fmt.Sprintf("Resolved %d conflict(s) with the %s change.", len(merge.conflicts), options.prefer))
+	}
+	util.UI.Info(util.Colorize().Color("[reset][bold][green]" + strings.Join(lines, "\n")))
+	return nil
+}
+
+// livePipeline gets the saved pipeline for the application and name of the local pipeline.
+func livePipeline(cmd *cobra.Command, local map[string]interface{}) (map[string]interface{}, error) {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return nil, err
+	}
+
+	application, _ := local["application"].(string)
+	name, _ := local["name"].(string)
+	if application == "" || name == "" {
+		return nil, errors.New("the local pipeline needs an 'application' and 'name' to get the live pipeline")
+	}
+	pipeline, resp, err := gateClient.ApplicationControllerApi.GetPipelineConfigUsingGET(gateClient.Context, application, name)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error getting pipeline %s in application %s, status code: %d\n", name, application, resp.StatusCode)
+	}
+	if len(pipeline) == 0 {
+		return nil, fmt.Errorf("Pipeline %s not found in application %s\n", name, application)
+	}
+	return pipeline, nil
+}
+
+// comparableDocument round trips a document through JSON, so that numbers are json.Numbers
+// whichever format they were read from.
+func comparableDocument(doc map[string]interface{}) (map[string]interface{}, error) {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(content)
+}
+
+// encodeMergedDocument encodes the merged pipeline in the format of the local file.
+func encodeMergedDocument(path string, doc map[string]interface{}) ([]byte, error) {
+	if !isYamlFile(path) {
+		return encodeDocument(doc)
+	}
+	return yaml.Marshal(yamlNumbers(doc))
+}
+
+// yamlNumbers converts json.Numbers back to ints and floats, which YAML writes unquoted.
+func yamlNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for key, child := range v {
+			m[key] = yamlNumbers(child)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, child := range v {
+			l[i] = yamlNumbers(child)
+		}
+		return l
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
+func (m *pipelineMerge) mergeDocuments(base, local, remote map[string]interface{}) map[string]interface{} {
+	// Fields set by Front50 on save are not changes to merge.
+	for _, key := range serverManagedKeys {
+		delete(remote, key)
+		delete(base, key)
+	}
+	merged := m.merge("", "", mergeValue{base, true}, mergeValue{local, true}, mergeValue{remote, true})
+	return merged.value.(map[string]interface{})
+}
+
+// merge returns the merged value of a field: unchanged on one side means the other side's
+// value, objects and identifiable lists changed on both sides are merged by field or element,
+// and anything else changed differently on both sides is a conflict.
+func (m *pipelineMerge) merge(path string, field string, base, local, remote mergeValue) mergeValue {
+	switch {
+	case equalValues(local, remote):
+		return local
+	case equalValues(base, local):
+		m.applied = append(m.applied, displayPath(path))
+		return remote
+	case equalValues(base, remote):
+		return local
+	}
+
+	localObject, localIsObject := local.value.(map[string]interface{})
+	remoteObject, remoteIsObject := remote.value.(map[string]interface{})
+	if local.present && remote.present && localIsObject && remoteIsObject {
+		baseObject, _ := base.value.(map[string]interface{})
+		return mergeValue{m.mergeObjects(path, baseObject, localObject, remoteObject), true}
+	}
+
+	localList, localIsList := local.value.([]interface{})
+	remoteList, remoteIsList := remote.value.([]interface{})
+	if _, identifiable := mergeIdentities[field]; identifiable && local.present && remote.present && localIsList && remoteIsList {
+		baseList, _ := base.value.([]interface{})
+		return mergeValue{m.mergeLists(path, field, baseList, localList, remoteList), true}
+	}
+
+	m.conflicts = append(m.conflicts, mergeConflict{path: displayPath(path), base: base, local: local, remote: remote})
+	if m.prefer == "remote" {
+		return remote
+	}
+	return local
+}
+
+func (m *pipelineMerge) mergeObjects(path string, base, local, remote map[string]interface{}) map[string]interface{} {
+	keys := map[string]bool{}
+	for _, object := range []map[string]interface{}{base, local, remote} {
+		for key := range object {
+			keys[key] = true
+		}
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	merged := map[string]interface{}{}
+	for _, key := range sorted {
+		b, bOk := base[key]
+		l, lOk := local[key]
+		r, rOk := remote[key]
+		result := m.merge(joinMergePath(path, key), key, mergeValue{b, bOk}, mergeValue{l, lOk}, mergeValue{r, rOk})
+		if result.present {
+			merged[key] = result.value
+		}
+	}
+	return merged
+}
+
+// mergeLists merges elements by identity, keeping the local order and appending remote additions.
+func (m *pipelineMerge) mergeLists(path string, field string, base, local, remote []interface{}) []interface{} {
+	baseElements, _ := identifyElements(field, base)
+	localElements, localOrder := identifyElements(field, local)
+	remoteElements, remoteOrder := identifyElements(field, remote)
+
+	order := append([]string{}, localOrder...)
+	for _, id := range remoteOrder {
+		if _, inLocal := localElements[id]; !inLocal {
+			order = append(order, id)
+		}
+	}
+	// Elements removed locally are still merged, so a remote change to them is a conflict.
+	for id := range baseElements {
+		if _, inLocal := localElements[id]; !inLocal {
+			if _, inRemote := remoteElements[id]; inRemote {
+				order = append(order, id)
+			}
+		}
+	}
+
+	var merged []interface{}
+	seen := map[string]bool{}
+	for _, id := range order {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		b, bOk := baseElements[id]
+		l, lOk := localElements[id]
+		r, rOk := remoteElements[id]
+		result := m.merge(fmt.Sprintf("%s[%s]", path, id), "", mergeValue{b, bOk}, mergeValue{l, lOk}, mergeValue{r, rOk})
+		if result.present {
+			merged = append(merged, result.value)
+		}
+	}
+	if merged == nil {
+		merged = []interface{}{}
+	}
+	return merged
+}
+
+// identifyElements indexes list elements by identity. Elements without one are identified by
+// their position.
+func identifyElements(field string, elements []interface{}) (map[string]interface{}, []string) {
+	identified := map[string]interface{}{}
+	var order []string
+	for i, rawElement := range elements {
+		id := ""
+		if element, ok := rawElement.(map[string]interface{}); ok {
+			id = elementIdentity(field, element)
+		}
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+		if _, duplicate := identified[id]; duplicate {
+			id = fmt.Sprintf("%s#%d", id, i)
+		}
+		identified[id] = rawElement
+		order = append(order, id)
+	}
+	return identified, order
+}
+
+func elementIdentity(field string, element map[string]interface{}) string {
+	for _, key := range mergeIdentities[field] {
+		if value, exists := element[key]; exists && value != nil && fmt.Sprint(value) != "" {
+			id := fmt.Sprintf("%s=%v", key, value)
+			if elementType, ok := element["type"].(string); ok && (field == "triggers" || field == "notifications") {
+				id = elementType + ":" + id
+			}
+			return id
+		}
+	}
+	return ""
+}
+
+func equalValues(a, b mergeValue) bool {
+	if a.present != b.present {
+		return false
+	}
+	return reflect.DeepEqual(a.value, b.value)
+}
+
+func joinMergePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(pipeline)"
+	}
+	return path
+}
+
+func (c mergeConflict) describe() string {
+	return fmt.Sprintf("%s: base %s, local %s, remote %s", c.path, describeMergeValue(c.base), describeMergeValue(c.local), describeMergeValue(c.remote))
+}
+
+func describeMergeValue(v mergeValue) string {
+	if !v.present {
+		return "(unset)"
+	}
+	content, err := json.Marshal(v.value)
+	if err != nil {
+		return fmt.Sprint(v.value)
+	}
+	if len(content) > 60 {
+		return string(content[:57]) + "..."
+	}
+	return string(content)
+}
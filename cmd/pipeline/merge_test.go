@@ -0,0 +1,390 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spinnaker/spin/util"
+)
+
+func TestPipelineMerge_basic(t *testing.T) {
+	dir := tempMergeFiles(t, testMergeRemoteJson)
+	defer os.RemoveAll(dir)
+
+	args := []string{"pipeline", "merge", "--base", filepath.Join(dir, "base.json"), "--local", filepath.Join(dir, "local.json"),
+		"--remote", filepath.Join(dir, "remote.json")}
+	currentCmd := NewMergeCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	merged, err := readPipelineDocument(filepath.Join(dir, "local.json"))
+	if err != nil {
+		t.Fatalf("Could not read merged pipeline: %s", err)
+	}
+	assertPipeline(t, merged, testMergedPipelineJson)
+}
+
+func TestPipelineMerge_live(t *testing.T) {
+	ts := testGatePipelineMergeSuccess()
+	defer ts.Close()
+	dir := tempMergeFiles(t, testMergeRemoteJson)
+	defer os.RemoveAll(dir)
+
+	args := []string{"pipeline", "merge", "--base", filepath.Join(dir, "base.json"), "--local", filepath.Join(dir, "local.json"),
+		"--gate-endpoint", ts.URL}
+	currentCmd := NewMergeCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	merged, err := readPipelineDocument(filepath.Join(dir, "local.json"))
+	if err != nil {
+		t.Fatalf("Could not read merged pipeline: %s", err)
+	}
+	assertPipeline(t, merged, testMergedPipelineJson)
+}
+
+func TestPipelineMerge_conflict(t *testing.T) {
+	dir := tempMergeFiles(t, testMergeConflictingRemoteJson)
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "local.json")
+
+	args := []string{"pipeline", "merge", "--base", filepath.Join(dir, "base.json"), "--local", local,
+		"--remote", filepath.Join(dir, "remote.json")}
+	currentCmd := NewMergeCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected the conflicting merge to fail")
+	}
+	content, _ := ioutil.ReadFile(local)
+	if string(content) != strings.TrimSpace(testMergeLocalJson) {
+		t.Fatalf("Expected the local pipeline to be left untouched, got:\n%s", content)
+	}
+}
+
+func TestPipelineMerge_preferRemote(t *testing.T) {
+	dir := tempMergeFiles(t, testMergeConflictingRemoteJson)
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "local.json")
+
+	args := []string{"pipeline", "merge", "--base", filepath.Join(dir, "base.json"), "--local", local,
+		"--remote", filepath.Join(dir, "remote.json"), "--prefer", "remote"}
+	currentCmd := NewMergeCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	merged, err := readPipelineDocument(local)
+	if err != nil {
+		t.Fatalf("Could not read merged pipeline: %s", err)
+	}
+	stage := merged["stages"].([]interface{})[1].(map[string]interface{})
+	if stage["account"] != "qa" {
+		t.Fatalf("Expected the remote account to win the conflict, got: %v", stage["account"])
+	}
+}
+
+func TestPipelineMerge_conflicts(t *testing.T) {
+	base, _ := parseDocument([]byte(testMergeBaseJson))
+	local, _ := parseDocument([]byte(testMergeLocalJson))
+	remote, _ := parseDocument([]byte(testMergeConflictingRemoteJson))
+
+	merge := &pipelineMerge{}
+	merge.mergeDocuments(base, local, remote)
+	var conflicts []string
+	for _, conflict := range merge.conflicts {
+		conflicts = append(conflicts, conflict.describe())
+	}
+	expected := []string{
+		`stages[refId=2].account: base "staging", local "prod", remote "qa"`,
+		`stages[refId=3]: base {"name":"Smoke test","refId":"3","requisiteStageRefIds":[..., local (unset), remote {"name":"Smoke test","refId":"3","requisiteStageRefIds":[...`,
+	}
+	if strings.Join(conflicts, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected conflicts:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(conflicts, "\n"))
+	}
+}
+
+func TestPipelineMerge_yamlLocal(t *testing.T) {
+	dir := tempMergeFiles(t, testMergeRemoteWaitJson)
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "local.yaml")
+	if err := ioutil.WriteFile(local, []byte(strings.TrimSpace(testMergeLocalWaitYaml)), 0644); err != nil {
+		t.Fatalf("Could not write local.yaml: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.json"), []byte(strings.TrimSpace(testMergeBaseWaitJson)), 0644); err != nil {
+		t.Fatalf("Could not write base.json: %s", err)
+	}
+
+	args := []string{"pipeline", "merge", "--base", filepath.Join(dir, "base.json"), "--local", local,
+		"--remote", filepath.Join(dir, "remote.json")}
+	currentCmd := NewMergeCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	content, _ := ioutil.ReadFile(local)
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "{") {
+		t.Fatalf("Expected local.yaml to be written as YAML, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), "waitTime: 60") {
+		t.Fatalf("Expected the remote waitTime to be merged as a YAML number, got:\n%s", content)
+	}
+	merged, err := readPipelineDocument(local)
+	if err != nil {
+		t.Fatalf("Could not read merged pipeline: %s", err)
+	}
+	assertPipeline(t, merged, testMergedWaitJson)
+}
+
+func TestPipelineMerge_numbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "local.yaml")
+	ioutil.WriteFile(local, []byte(strings.TrimSpace(testMergeLocalWaitYaml)), 0644)
+
+	base, _ := parseDocument([]byte(testMergeBaseWaitJson))
+	localDoc, err := readPipelineDocument(local)
+	if err != nil {
+		t.Fatalf("Could not read local pipeline: %s", err)
+	}
+	// Change the stage locally too, so the stage is merged field by field.
+	localDoc["stages"].([]interface{})[0].(map[string]interface{})["name"] = "Wait a minute"
+	remote, _ := parseDocument([]byte(testMergeRemoteWaitJson))
+	for _, doc := range []*map[string]interface{}{&base, &localDoc, &remote} {
+		*doc, _ = comparableDocument(*doc)
+	}
+
+	merge := &pipelineMerge{}
+	merge.mergeDocuments(base, localDoc, remote)
+	if len(merge.conflicts) != 0 {
+		t.Fatalf("Expected equal numbers not to conflict, got: %s", merge.conflicts[0].describe())
+	}
+	expected := "stages[refId=1].waitTime"
+	if strings.Join(merge.applied, ",") != expected {
+		t.Fatalf("Expected applied changes %s, got %v", expected, merge.applied)
+	}
+}
+
+// tempMergeFiles writes the base, local and remote pipelines of a merge to a temp dir.
+func tempMergeFiles(t *testing.T, remote string) string {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	for name, content := range map[string]string{
+		"base.json":   testMergeBaseJson,
+		"local.json":  testMergeLocalJson,
+		"remote.json": remote,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(strings.TrimSpace(content)), 0644); err != nil {
+			t.Fatalf("Could not write %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+// testGatePipelineMergeSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves the live pipeline, as edited in the UI.
+func testGatePipelineMergeSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(testMergeRemoteJson))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const testMergeBaseJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "notifications": [
+    {"type": "slack", "address": "deploys", "when": ["pipeline.failed"]}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": []},
+    {"refId": "2", "name": "Deploy", "type": "deploy", "account": "staging", "requisiteStageRefIds": ["1"]},
+    {"refId": "3", "name": "Smoke test", "type": "runJob", "requisiteStageRefIds": ["2"]}
+  ],
+  "triggers": [
+    {"type": "git", "project": "org", "slug": "app", "branch": "master", "enabled": true}
+  ]
+}
+`
+
+// The local file promotes the deploy to prod and drops the smoke test.
+const testMergeLocalJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "notifications": [
+    {"type": "slack", "address": "deploys", "when": ["pipeline.failed"]}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": []},
+    {"refId": "2", "name": "Deploy", "type": "deploy", "account": "prod", "requisiteStageRefIds": ["1"]}
+  ],
+  "triggers": [
+    {"type": "git", "project": "org", "slug": "app", "branch": "master", "enabled": true}
+  ]
+}
+`
+
+// The UI disabled the trigger, added a notification and a bake option.
+const testMergeRemoteJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "id": "1234",
+  "updateTs": "1556000000000",
+  "lastModifiedBy": "someone@example.com",
+  "notifications": [
+    {"type": "slack", "address": "deploys", "when": ["pipeline.failed"]},
+    {"type": "email", "address": "oncall@example.com", "when": ["pipeline.failed"]}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": [], "extendedAttributes": {"hardened": "true"}},
+    {"refId": "2", "name": "Deploy", "type": "deploy", "account": "staging", "requisiteStageRefIds": ["1"]},
+    {"refId": "3", "name": "Smoke test", "type": "runJob", "requisiteStageRefIds": ["2"]}
+  ],
+  "triggers": [
+    {"type": "git", "project": "org", "slug": "app", "branch": "master", "enabled": false}
+  ]
+}
+`
+
+// The UI also changed the deploy account and the smoke test the local file removed.
+const testMergeConflictingRemoteJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "notifications": [
+    {"type": "slack", "address": "deploys", "when": ["pipeline.failed"]}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": []},
+    {"refId": "2", "name": "Deploy", "type": "deploy", "account": "qa", "requisiteStageRefIds": ["1"]},
+    {"refId": "3", "name": "Smoke test", "type": "runJob", "requisiteStageRefIds": ["2"], "waitForCompletion": true}
+  ],
+  "triggers": [
+    {"type": "git", "project": "org", "slug": "app", "branch": "master", "enabled": true}
+  ]
+}
+`
+
+const testMergedPipelineJson = `
+{
+  "application": "app",
+  "name": "deploy",
+  "id": "1234",
+  "notifications": [
+    {"type": "slack", "address": "deploys", "when": ["pipeline.failed"]},
+    {"type": "email", "address": "oncall@example.com", "when": ["pipeline.failed"]}
+  ],
+  "stages": [
+    {"refId": "1", "name": "Bake", "type": "bake", "requisiteStageRefIds": [], "extendedAttributes": {"hardened": "true"}},
+    {"refId": "2", "name": "Deploy", "type": "deploy", "account": "prod", "requisiteStageRefIds": ["1"]}
+  ],
+  "triggers": [
+    {"type": "git", "project": "org", "slug": "app", "branch": "master", "enabled": false}
+  ]
+}
+`
+
+const testMergeBaseWaitJson = `
+{
+  "application": "app",
+  "name": "wait",
+  "stages": [
+    {"refId": "1", "name": "Wait", "type": "wait", "waitTime": 30, "ratio": 0.5}
+  ]
+}
+`
+
+const testMergeLocalWaitYaml = `
+application: app
+name: wait
+stages:
+  - refId: "1"
+    name: Wait
+    type: wait
+    waitTime: 30
+    ratio: 0.5
+`
+
+const testMergeRemoteWaitJson = `
+{
+  "application": "app",
+  "name": "wait",
+  "stages": [
+    {"refId": "1", "name": "Wait", "type": "wait", "waitTime": 60, "ratio": 0.5}
+  ]
+}
+`
+
+const testMergedWaitJson = `
+{
+  "application": "app",
+  "name": "wait",
+  "stages": [
+    {"refId": "1", "name": "Wait", "type": "wait", "waitTime": 60, "ratio": 0.5}
+  ]
+}
+`
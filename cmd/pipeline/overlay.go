@@ -0,0 +1,201 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	mergeStrategic = "strategic"
+	mergeJson      = "json"
+
+	// patchDirective in an overlay list element removes the matching element with "$patch": "delete".
+	patchDirective = "$patch"
+)
+
+// mergeKeys are the fields that identify the elements of pipeline lists in a strategic merge, in
+// order of preference. Other lists are replaced by the overlay.
+var mergeKeys = map[string][]string{
+	"stages":            {"refId", "name"},
+	"parameterConfig":   {"name"},
+	"expectedArtifacts": {"id", "displayName"},
+}
+
+// readPipelineDocument reads a pipeline or overlay from a JSON or, by extension, YAML file.
+func readPipelineDocument(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isYamlFile(path) {
+		doc, err := parseDocument(content)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse %s: %v\n", path, err)
+		}
+		return doc, nil
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %v\n", path, err)
+	}
+	doc, ok := normalizeYaml(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Could not parse %s: not a mapping\n", path)
+	}
+	return doc, nil
+}
+
+func isYamlFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// normalizeYaml converts the maps decoded from YAML to the string keyed maps JSON uses.
+func normalizeYaml(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, child := range v {
+			m[fmt.Sprint(key)] = normalizeYaml(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalizeYaml(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// applyOverlays reads each overlay file and merges it onto the pipeline in order.
+func applyOverlays(pipeline map[string]interface{}, overlayFiles []string, mergeType string) (map[string]interface{}, error) {
+	if mergeType != mergeStrategic && mergeType != mergeJson {
+		return nil, fmt.Errorf("Unknown merge type %s, expected %s or %s\n", mergeType, mergeStrategic, mergeJson)
+	}
+	for _, path := range overlayFiles {
+		overlay, err := readPipelineDocument(path)
+		if err != nil {
+			return nil, err
+		}
+		if mergeType == mergeJson {
+			pipeline = mergePatch(pipeline, overlay).(map[string]interface{})
+		} else {
+			pipeline = strategicMerge(pipeline, overlay, "").(map[string]interface{})
+		}
+	}
+	return pipeline, nil
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386): objects are merged, null removes a field and
+// any other value, including a list, replaces the original.
+func mergePatch(original interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	originalObject, ok := original.(map[string]interface{})
+	if !ok {
+		originalObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(originalObject, key)
+		} else {
+			originalObject[key] = mergePatch(originalObject[key], value)
+		}
+	}
+	return originalObject
+}
+
+// strategicMerge merges like mergePatch, except that lists with merge keys are merged element by
+// element: overlay elements update the element with the same key, are appended when no element
+// matches, and remove it when they carry "$patch": "delete".
+func strategicMerge(original interface{}, overlay interface{}, field string) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		originalObject, ok := original.(map[string]interface{})
+		if !ok {
+			originalObject = map[string]interface{}{}
+		}
+		for key, value := range o {
+			if value == nil {
+				delete(originalObject, key)
+			} else {
+				originalObject[key] = strategicMerge(originalObject[key], value, key)
+			}
+		}
+		return originalObject
+	case []interface{}:
+		keys, keyed := mergeKeys[field]
+		originalList, ok := original.([]interface{})
+		if !keyed || !ok {
+			return o
+		}
+		return mergeList(originalList, o, keys)
+	default:
+		return overlay
+	}
+}
+
+func mergeList(original []interface{}, overlay []interface{}, keys []string) []interface{} {
+	merged := append([]interface{}{}, original...)
+	for _, rawElement := range overlay {
+		element, ok := rawElement.(map[string]interface{})
+		if !ok {
+			merged = append(merged, rawElement)
+			continue
+		}
+		index := matchElement(merged, element, keys)
+		if element[patchDirective] == "delete" {
+			if index >= 0 {
+				merged = append(merged[:index], merged[index+1:]...)
+			}
+			continue
+		}
+		delete(element, patchDirective)
+		if index < 0 {
+			merged = append(merged, element)
+		} else {
+			merged[index] = strategicMerge(merged[index], element, "")
+		}
+	}
+	return merged
+}
+
+// matchElement returns the index of the element sharing the first merge key the overlay element sets.
+func matchElement(elements []interface{}, overlay map[string]interface{}, keys []string) int {
+	for _, key := range keys {
+		value, exists := overlay[key]
+		if !exists || value == nil {
+			continue
+		}
+		for i, rawElement := range elements {
+			if element, ok := rawElement.(map[string]interface{}); ok && element[key] != nil && fmt.Sprint(element[key]) == fmt.Sprint(value) {
+				return i
+			}
+		}
+		return -1
+	}
+	return -1
+}
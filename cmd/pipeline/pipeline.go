@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/pipeline/artifacts"
 	"github.com/spinnaker/spin/cmd/pipeline/execution"
 	"io"
 )
@@ -30,6 +31,10 @@ func NewPipelineCmd(out io.Writer) *cobra.Command {
 	cmd.AddCommand(NewDeleteCmd(options))
 	cmd.AddCommand(NewSaveCmd(options))
 	cmd.AddCommand(NewExecuteCmd(options))
+	cmd.AddCommand(NewFmtCmd(options))
+	cmd.AddCommand(NewBuildCmd(options))
+	cmd.AddCommand(NewMergeCmd(options))
+	cmd.AddCommand(artifacts.NewArtifactsCmd(out))
 	cmd.AddCommand(execution.NewExecutionCmd(out))
 	return cmd
 }
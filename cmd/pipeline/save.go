@@ -28,6 +28,10 @@ type SaveOptions struct {
 	*pipelineOptions
 	output       string
 	pipelineFile string
+	runAs        string
+	strict       bool
+	overlays     []string
+	mergeType    string
 }
 
 var (
@@ -50,6 +54,10 @@ func NewSaveCmd(pipelineOptions pipelineOptions) *cobra.Command {
 	}
 
 	cmd.PersistentFlags().StringVarP(&options.pipelineFile, "file", "f", "", "path to the pipeline file")
+	cmd.PersistentFlags().StringVar(&options.runAs, "run-as", "", "(optional) service account every pipeline trigger should run as")
+	cmd.PersistentFlags().BoolVar(&options.strict, "strict-accounts", false, "fail instead of warning when stages reference accounts the pipeline cannot access")
+	cmd.PersistentFlags().StringSliceVar(&options.overlays, "overlay", nil, "(optional) path to an overlay file to merge onto the pipeline, may be repeated and applied in order")
+	cmd.PersistentFlags().StringVar(&options.mergeType, "merge-type", mergeStrategic, "how overlays are merged, strategic or json")
 
 	return cmd
 }
@@ -60,10 +68,21 @@ func savePipeline(cmd *cobra.Command, options SaveOptions) error {
 		return err
 	}
 
-	pipelineJson, err := util.ParseJsonFromFileOrStdin(options.pipelineFile, false)
+	var pipelineJson map[string]interface{}
+	if isYamlFile(options.pipelineFile) {
+		pipelineJson, err = readPipelineDocument(options.pipelineFile)
+	} else {
+		pipelineJson, err = util.ParseJsonFromFileOrStdin(options.pipelineFile, false)
+	}
 	if err != nil {
 		return err
 	}
+	if len(options.overlays) > 0 {
+		pipelineJson, err = applyOverlays(pipelineJson, options.overlays, options.mergeType)
+		if err != nil {
+			return err
+		}
+	}
 	valid := true
 	if _, exists := pipelineJson["name"]; !exists {
 		util.UI.Error("Required pipeline key 'name' missing...\n")
@@ -86,6 +105,16 @@ func savePipeline(cmd *cobra.Command, options SaveOptions) error {
 	if !valid {
 		return fmt.Errorf("Submitted pipeline is invalid: %s\n", pipelineJson)
 	}
+	if options.runAs != "" {
+		setTriggerRunAsUser(pipelineJson, options.runAs)
+	}
+	if err = validateTriggerServiceAccounts(gateClient, pipelineJson); err != nil {
+		return err
+	}
+	if err = checkStageAccounts(gateClient, pipelineJson, options.strict); err != nil {
+		return err
+	}
+
 	application := pipelineJson["application"].(string)
 	pipelineName := pipelineJson["name"].(string)
 
@@ -15,11 +15,13 @@
 package pipeline
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/spinnaker/spin/util"
@@ -193,6 +195,251 @@ func TestPipelineSave_missingapp(t *testing.T) {
 	}
 }
 
+func TestPipelineSave_runAs(t *testing.T) {
+	saved := map[string]interface{}{}
+	ts := testGateServiceAccountsSuccess(saved)
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testTriggeredPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--run-as", "deployer@managed-service-account", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	triggers, _ := saved["triggers"].([]interface{})
+	if len(triggers) != 1 {
+		t.Fatalf("Expected one saved trigger, got: %v", saved["triggers"])
+	}
+	if runAs := triggers[0].(map[string]interface{})["runAsUser"]; runAs != "deployer@managed-service-account" {
+		t.Fatalf("Expected trigger to run as the supplied service account, got: %v", runAs)
+	}
+}
+
+func TestPipelineSave_unknownRunAsUser(t *testing.T) {
+	ts := testGateServiceAccountsSuccess(map[string]interface{}{})
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testTriggeredPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--run-as", "nobody@managed-service-account", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected save to fail for an unknown service account")
+	}
+}
+
+func TestPipelineSave_runAsUserMissingRole(t *testing.T) {
+	ts := testGateServiceAccountsSuccess(map[string]interface{}{})
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testTriggeredPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--run-as", "other@managed-service-account", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected save to fail for a service account without the application's roles")
+	}
+}
+
+func TestPipelineSave_runAsUserNamesOnly(t *testing.T) {
+	ts := testGateServiceAccountNamesSuccess()
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testTriggeredPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--run-as", "other@managed-service-account", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	// Warnings go to stderr, which the gate client's UI picks up when the command runs.
+	stderr, err := ioutil.TempFile("" /* /tmp dir. */, "pipeline-save-stderr")
+	if err != nil {
+		t.Fatal("Could not create temp stderr file.")
+	}
+	defer os.Remove(stderr.Name())
+	realStderr := os.Stderr
+	os.Stderr = stderr
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	os.Stderr = realStderr
+	stderr.Close()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	warning, _ := ioutil.ReadFile(stderr.Name())
+	if !strings.Contains(string(warning), "Could not check the roles of service account 'other@managed-service-account'") {
+		t.Fatalf("Expected a warning that the service account's roles were not checked, got: %q", warning)
+	}
+}
+
+func TestPipelineSave_inaccessibleAccounts(t *testing.T) {
+	ts := testGateServiceAccountsSuccess(map[string]interface{}{})
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testDeployPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Expected inaccessible accounts to only warn, got: %s", err)
+	}
+}
+
+func TestPipelineSave_inaccessibleAccountsStrict(t *testing.T) {
+	ts := testGateServiceAccountsSuccess(map[string]interface{}{})
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testDeployPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--strict-accounts", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected save to fail for a pipeline deploying to an inaccessible account")
+	}
+}
+
+func TestPipelineSave_runAsAccountsStrict(t *testing.T) {
+	ts := testGateServiceAccountsSuccess(map[string]interface{}{})
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testDeployPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+
+	// The service account's roles grant it the account the current user lacks.
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--run-as", "deployer@managed-service-account", "--strict-accounts", "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestPipelineSave_stageAccounts(t *testing.T) {
+	var pipeline map[string]interface{}
+	json.Unmarshal([]byte(testDeployPipelineJsonStr), &pipeline)
+
+	accounts := stageAccounts(pipeline)
+	if len(accounts) != 1 {
+		t.Fatalf("Expected only the literal account to be referenced, got: %v", accounts)
+	}
+	if stages := accounts["prod"]; len(stages) != 2 {
+		t.Fatalf("Expected prod to be referenced by two stages, got: %v", stages)
+	}
+}
+
+func TestPipelineSave_overlay(t *testing.T) {
+	saved := map[string]interface{}{}
+	ts := testGateServiceAccountsSuccess(saved)
+	defer ts.Close()
+
+	tempFile := tempPipelineFile(testDeployPipelineJsonStr)
+	if tempFile == nil {
+		t.Fatal("Could not create temp pipeline file.")
+	}
+	defer os.Remove(tempFile.Name())
+	overlayFile := tempOverlayFile(testStagingOverlayYaml)
+	if overlayFile == "" {
+		t.Fatal("Could not create temp overlay file.")
+	}
+	defer os.Remove(overlayFile)
+
+	args := []string{"pipeline", "save", "--file", tempFile.Name(), "--overlay", overlayFile, "--gate-endpoint", ts.URL}
+	currentCmd := NewSaveCmd(pipelineOptions{})
+	rootCmd := getRootCmdForTest()
+	pipelineCmd := NewPipelineCmd(os.Stdout)
+	pipelineCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(pipelineCmd)
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+
+	stages, _ := saved["stages"].([]interface{})
+	if len(stages) != 3 {
+		t.Fatalf("Expected the overlay to keep all stages, got: %v", saved["stages"])
+	}
+	manifest := stages[1].(map[string]interface{})
+	if manifest["account"] != "staging" || manifest["type"] != "deployManifest" {
+		t.Fatalf("Expected the overlay to change the stage account, got: %v", manifest)
+	}
+}
+
 func tempPipelineFile(pipelineContent string) *os.File {
 	tempFile, _ := ioutil.TempFile("" /* /tmp dir. */, "pipeline-spec")
 	bytes, err := tempFile.Write([]byte(pipelineContent))
@@ -203,6 +450,19 @@ func tempPipelineFile(pipelineContent string) *os.File {
 	return tempFile
 }
 
+// tempOverlayFile writes a YAML overlay to a temp file and returns its path.
+func tempOverlayFile(content string) string {
+	tempFile, err := ioutil.TempFile("" /* /tmp dir. */, "overlay-*.yaml")
+	if err != nil {
+		return ""
+	}
+	defer tempFile.Close()
+	if _, err := tempFile.WriteString(content); err != nil {
+		return ""
+	}
+	return tempFile.Name()
+}
+
 // GateServerSuccess spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with a 200 OK.
 func GateServerSuccess() *httptest.Server {
@@ -213,6 +473,51 @@ func GateServerSuccess() *httptest.Server {
 	return httptest.NewServer(mux)
 }
 
+// testGateServiceAccountsSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with the current user, service accounts, account and application
+// permissions, and records
+// the saved pipeline in the supplied map.
+func testGateServiceAccountsSuccess(saved map[string]interface{}) *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/auth/user/serviceAccounts", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(serviceAccountsJson))
+	}))
+	mux.Handle("/applications/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(permissionedAppJson))
+	}))
+	mux.Handle("/auth/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(currentUserJson))
+	}))
+	mux.Handle("/credentials", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(credentialsJson))
+	}))
+	mux.Handle("/pipelines", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&saved)
+		fmt.Fprintln(w, "")
+	}))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "")
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testGateServiceAccountNamesSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with service account names only, as Gate usually does, and an
+// application with permissions.
+func testGateServiceAccountNamesSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/auth/user/serviceAccounts", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `["deployer@managed-service-account", "other@managed-service-account"]`)
+	}))
+	mux.Handle("/applications/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(permissionedAppJson))
+	}))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "")
+	}))
+	return httptest.NewServer(mux)
+}
+
 // GateServerFail spins up a local http server that we will configure the GateClient
 // to direct requests to. Responds with a 500 InternalServerError.
 func GateServerFail() *httptest.Server {
@@ -317,3 +622,128 @@ const testPipelineJsonStr = `
   "updateTs": "1520879791608"
 }
 `
+
+const testTriggeredPipelineJsonStr = `
+{
+  "name": "pipeline1",
+  "id": "pipeline1",
+  "application": "app",
+  "stages": [
+    {
+      "name": "Wait",
+      "refId": "1",
+      "requisiteStageRefIds": [],
+      "type": "wait",
+      "waitTime": 30
+    }
+  ],
+  "triggers": [
+    {
+      "cronExpression": "0 0 10 ? * MON-FRI",
+      "enabled": true,
+      "type": "cron"
+    }
+  ]
+}
+`
+
+const serviceAccountsJson = `
+[
+  {
+    "name": "deployer@managed-service-account",
+    "memberOf": ["deployers"]
+  },
+  {
+    "name": "other@managed-service-account",
+    "memberOf": ["others"]
+  }
+]
+`
+
+const permissionedAppJson = `
+{
+  "name": "app",
+  "attributes": {
+    "name": "app",
+    "permissions": {
+      "READ": ["deployers", "others"],
+      "WRITE": ["deployers"]
+    }
+  }
+}
+`
+
+const testDeployPipelineJsonStr = `
+{
+  "name": "pipeline1",
+  "id": "pipeline1",
+  "application": "app",
+  "stages": [
+    {
+      "name": "Deploy",
+      "refId": "1",
+      "requisiteStageRefIds": [],
+      "type": "deploy",
+      "clusters": [
+        {
+          "account": "prod",
+          "application": "app",
+          "provider": "aws"
+        }
+      ]
+    },
+    {
+      "name": "Deploy (Manifest)",
+      "refId": "2",
+      "requisiteStageRefIds": ["1"],
+      "type": "deployManifest",
+      "account": "prod",
+      "cloudProvider": "kubernetes"
+    },
+    {
+      "name": "Run Job",
+      "refId": "3",
+      "requisiteStageRefIds": ["2"],
+      "type": "runJob",
+      "credentials": "${ parameters.account }"
+    }
+  ],
+  "triggers": [
+    {
+      "cronExpression": "0 0 10 ? * MON-FRI",
+      "enabled": true,
+      "type": "cron"
+    }
+  ]
+}
+`
+
+const currentUserJson = `
+{
+  "username": "me@example.com",
+  "allowedAccounts": ["staging"]
+}
+`
+
+const credentialsJson = `
+[
+  {
+    "name": "prod",
+    "type": "aws",
+    "permissions": {
+      "READ": ["deployers", "others"],
+      "WRITE": ["deployers"]
+    }
+  },
+  {
+    "name": "staging",
+    "type": "kubernetes"
+  }
+]
+`
+
+const testStagingOverlayYaml = `
+stages:
+- refId: "2"
+  account: staging
+`
@@ -0,0 +1,174 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+// pipelineTriggers returns the trigger definitions of the pipeline.
+func pipelineTriggers(pipeline map[string]interface{}) []map[string]interface{} {
+	var triggers []map[string]interface{}
+	rawTriggers, _ := pipeline["triggers"].([]interface{})
+	for _, rawTrigger := range rawTriggers {
+		if trigger, ok := rawTrigger.(map[string]interface{}); ok {
+			triggers = append(triggers, trigger)
+		}
+	}
+	return triggers
+}
+
+// setTriggerRunAsUser makes every trigger of the pipeline run as the given service account.
+func setTriggerRunAsUser(pipeline map[string]interface{}, serviceAccount string) {
+	for _, trigger := range pipelineTriggers(pipeline) {
+		if existing, _ := trigger["runAsUser"].(string); existing != "" && existing != serviceAccount {
+			util.UI.Warn(fmt.Sprintf("Overriding runAsUser '%s' of %v trigger with explicit flag value.\n", existing, trigger["type"]))
+		}
+		trigger["runAsUser"] = serviceAccount
+	}
+}
+
+// validateTriggerServiceAccounts checks that each trigger's runAsUser is a service account
+// available to the user and that it holds one of the roles allowed to execute the
+// application's pipelines. Fiat otherwise rejects such triggers without any visible error.
+func validateTriggerServiceAccounts(gateClient *gateclient.GatewayClient, pipeline map[string]interface{}) error {
+	var runAsTriggers []map[string]interface{}
+	for _, trigger := range pipelineTriggers(pipeline) {
+		if runAs, _ := trigger["runAsUser"].(string); runAs != "" {
+			runAsTriggers = append(runAsTriggers, trigger)
+		}
+	}
+	if len(runAsTriggers) == 0 {
+		return nil
+	}
+
+	accounts, resp, err := gateClient.AuthControllerApi.GetServiceAccountsUsingGET(gateClient.Context)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error listing service accounts, status code: %d\n", resp.StatusCode)
+	}
+	accountRoles := serviceAccountRoles(accounts)
+
+	application, _ := pipeline["application"].(string)
+	requiredRoles, err := applicationExecuteRoles(gateClient, application)
+	if err != nil {
+		return err
+	}
+
+	valid := true
+	for _, trigger := range runAsTriggers {
+		runAs := trigger["runAsUser"].(string)
+		roles, exists := accountRoles[runAs]
+		if !exists {
+			util.UI.Error(fmt.Sprintf("Trigger %v runs as '%s', which is not an available service account...\n", trigger["type"], runAs))
+			valid = false
+			continue
+		}
+		if len(requiredRoles) == 0 {
+			continue
+		}
+		// Roles are only known when Gate returns service account details instead of names, and
+		// Gate has no other endpoint for a service account's roles.
+		if roles == nil {
+			util.UI.Warn(fmt.Sprintf("Could not check the roles of service account '%s' of trigger %v, make sure it holds one of the roles required by application '%s': %s.\n",
+				runAs, trigger["type"], application, strings.Join(requiredRoles, ", ")))
+			continue
+		}
+		if !hasAnyRole(roles, requiredRoles) {
+			util.UI.Error(fmt.Sprintf("Trigger %v runs as '%s', which has none of the roles required by application '%s': %s...\n",
+				trigger["type"], runAs, application, strings.Join(requiredRoles, ", ")))
+			valid = false
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("Submitted pipeline has invalid trigger service accounts\n")
+	}
+	return nil
+}
+
+// serviceAccountRoles maps service account names to their roles. Gate returns either plain
+// names or service account objects, so roles are nil when only names are available.
+func serviceAccountRoles(accounts []interface{}) map[string][]string {
+	roles := map[string][]string{}
+	for _, account := range accounts {
+		switch a := account.(type) {
+		case string:
+			roles[a] = nil
+		case map[string]interface{}:
+			name, _ := a["name"].(string)
+			if name == "" {
+				continue
+			}
+			memberOf := []string{}
+			rawRoles, _ := a["memberOf"].([]interface{})
+			for _, role := range rawRoles {
+				if r, ok := role.(string); ok {
+					memberOf = append(memberOf, r)
+				}
+			}
+			roles[name] = memberOf
+		}
+	}
+	return roles
+}
+
+// applicationExecuteRoles returns the roles allowed to execute the application's pipelines,
+// or nothing if the application does not restrict them.
+func applicationExecuteRoles(gateClient *gateclient.GatewayClient, application string) ([]string, error) {
+	app, resp, err := gateClient.ApplicationControllerApi.GetApplicationUsingGET(gateClient.Context, application, map[string]interface{}{"expand": false})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// The application will be created without permissions.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, _ := app["attributes"].(map[string]interface{})
+	permissions, _ := attributes["permissions"].(map[string]interface{})
+	for _, permission := range []string{"EXECUTE", "WRITE"} {
+		rawRoles, _ := permissions[permission].([]interface{})
+		if len(rawRoles) == 0 {
+			continue
+		}
+		var roles []string
+		for _, role := range rawRoles {
+			if r, ok := role.(string); ok {
+				roles = append(roles, r)
+			}
+		}
+		return roles, nil
+	}
+	return nil, nil
+}
+
+func hasAnyRole(roles []string, wanted []string) bool {
+	for _, role := range roles {
+		for _, w := range wanted {
+			if strings.EqualFold(role, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -27,5 +27,6 @@ func NewProjectCmd(out io.Writer) *cobra.Command {
 
 	// create subcommands
 	cmd.AddCommand(NewGetCmd(options))
+	cmd.AddCommand(NewStatusCmd(options))
 	return cmd
 }
@@ -0,0 +1,490 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package project
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type StatusOptions struct {
+	*projectOptions
+}
+
+var (
+	statusProjectShort   = "Show the latest executions and active server groups of the specified project"
+	statusProjectLong    = "Show, for every application and pipeline of the project, the status of the latest execution, when it ran and the version it deployed, along with the project's clusters and their active server groups"
+	statusProjectExample = "usage: spin project status [options] project-name"
+)
+
+// projectPipeline is a pipeline of the project with its latest execution.
+type projectPipeline struct {
+	Application string `json:"application"`
+	Name        string `json:"name,omitempty"`
+	Id          string `json:"id,omitempty"`
+	ExecutionId string `json:"executionId,omitempty"`
+	Status      string `json:"status,omitempty"`
+	StartTime   int64  `json:"startTime,omitempty"`
+	Version     string `json:"version,omitempty"`
+}
+
+// projectCluster is a cluster of the project in one application and region.
+type projectCluster struct {
+	Account      string              `json:"account"`
+	Stack        string              `json:"stack"`
+	Detail       string              `json:"detail"`
+	Application  string              `json:"application"`
+	Region       string              `json:"region"`
+	ServerGroups []activeServerGroup `json:"serverGroups"`
+}
+
+type activeServerGroup struct {
+	Name  string `json:"name"`
+	Up    int    `json:"up"`
+	Total int    `json:"total"`
+}
+
+type projectStatus struct {
+	Project   string            `json:"project"`
+	Pipelines []projectPipeline `json:"pipelines"`
+	Clusters  []projectCluster  `json:"clusters"`
+}
+
+func NewStatusCmd(prjOptions projectOptions) *cobra.Command {
+	options := StatusOptions{
+		projectOptions: &prjOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   statusProjectShort,
+		Long:    statusProjectLong,
+		Example: statusProjectExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return projectStatusBoard(cmd, options, args)
+		},
+	}
+
+	return cmd
+}
+
+func projectStatusBoard(cmd *cobra.Command, options StatusOptions, args []string) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	projectName, err := util.ReadArgsOrStdin(args)
+	if err != nil {
+		return err
+	}
+	if projectName == "" {
+		return errors.New("no project name supplied, exiting")
+	}
+
+	project, resp, err := gateClient.ProjectControllerApi.GetUsingGET1(gateClient.Context, projectName)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Project '%s' not found\n", projectName)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error getting project, status code: %d\n", resp.StatusCode)
+	}
+	config, _ := project["config"].(map[string]interface{})
+
+	executions, resp, err := gateClient.ProjectControllerApi.AllPipelinesForProjectUsingGET(gateClient.Context, projectName, map[string]interface{}{"limit": int32(1)})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error getting the executions of project %s, status code: %d\n", projectName, resp.StatusCode)
+	}
+
+	pipelineConfigs := map[string][]interface{}{}
+	for _, application := range projectApplications(config) {
+		configs, resp, err := gateClient.ApplicationControllerApi.GetPipelineConfigsForApplicationUsingGET(gateClient.Context, application)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error listing the pipelines of application %s, status code: %d\n", application, resp.StatusCode)
+		}
+		pipelineConfigs[application] = configs
+	}
+
+	clusters, resp, err := gateClient.ProjectControllerApi.GetClustersUsingGET3(gateClient.Context, projectName, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Encountered an error getting the clusters of project %s, status code: %d\n", projectName, resp.StatusCode)
+	}
+	serverGroups := map[string][]interface{}{}
+	for _, application := range clusterApplications(clusters) {
+		applicationServerGroups, resp, err := gateClient.ServerGroupControllerApi.GetServerGroupsForApplicationUsingGET(gateClient.Context, application, map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error listing the server groups of application %s, status code: %d\n", application, resp.StatusCode)
+		}
+		serverGroups[application] = applicationServerGroups
+	}
+
+	status := projectStatus{
+		Project:   projectName,
+		Pipelines: projectPipelines(config, pipelineConfigs, executions),
+		Clusters:  projectClusters(clusters, serverGroups),
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(status, util.UI.OutputFormat)
+		return nil
+	}
+	util.UI.Output(strings.Join(renderProjectStatus(status), "\n"))
+	return nil
+}
+
+func projectApplications(config map[string]interface{}) []string {
+	var applications []string
+	seen := map[string]bool{}
+	add := func(application string) {
+		if application != "" && !seen[application] {
+			seen[application] = true
+			applications = append(applications, application)
+		}
+	}
+	listed, _ := config["applications"].([]interface{})
+	for _, application := range listed {
+		name, _ := application.(string)
+		add(name)
+	}
+	pipelineConfigs, _ := config["pipelineConfigs"].([]interface{})
+	for _, p := range pipelineConfigs {
+		pipelineConfig, _ := p.(map[string]interface{})
+		name, _ := pipelineConfig["application"].(string)
+		add(name)
+	}
+	return applications
+}
+
+// projectPipelines lists the pipelines configured in the project by application, with the latest
+// execution of each. Applications without pipelines in the project are listed on their own.
+func projectPipelines(config map[string]interface{}, pipelineConfigs map[string][]interface{}, executions []interface{}) []projectPipeline {
+	latest := map[string]map[string]interface{}{}
+	for _, e := range executions {
+		execution, _ := e.(map[string]interface{})
+		id, _ := execution["pipelineConfigId"].(string)
+		if id == "" || (latest[id] != nil && executionTime(latest[id]) >= executionTime(execution)) {
+			continue
+		}
+		latest[id] = execution
+	}
+	names := map[string]string{}
+	for _, configs := range pipelineConfigs {
+		for _, c := range configs {
+			pipelineConfig, _ := c.(map[string]interface{})
+			id, _ := pipelineConfig["id"].(string)
+			names[id], _ = pipelineConfig["name"].(string)
+		}
+	}
+
+	byApplication := map[string][]projectPipeline{}
+	configured, _ := config["pipelineConfigs"].([]interface{})
+	for _, c := range configured {
+		pipelineConfig, _ := c.(map[string]interface{})
+		pipeline := projectPipeline{}
+		pipeline.Application, _ = pipelineConfig["application"].(string)
+		pipeline.Id, _ = pipelineConfig["pipelineConfigId"].(string)
+		pipeline.Name = names[pipeline.Id]
+		if execution := latest[pipeline.Id]; execution != nil {
+			if pipeline.Name == "" {
+				pipeline.Name, _ = execution["name"].(string)
+			}
+			pipeline.ExecutionId, _ = execution["id"].(string)
+			pipeline.Status, _ = execution["status"].(string)
+			pipeline.StartTime = executionTime(execution)
+			pipeline.Version = deployedVersion(execution)
+		}
+		if pipeline.Name == "" {
+			pipeline.Name = pipeline.Id
+		}
+		byApplication[pipeline.Application] = append(byApplication[pipeline.Application], pipeline)
+	}
+
+	var pipelines []projectPipeline
+	for _, application := range projectApplications(config) {
+		applicationPipelines := byApplication[application]
+		if len(applicationPipelines) == 0 {
+			pipelines = append(pipelines, projectPipeline{Application: application})
+			continue
+		}
+		sort.SliceStable(applicationPipelines, func(i, j int) bool {
+			return applicationPipelines[i].Name < applicationPipelines[j].Name
+		})
+		pipelines = append(pipelines, applicationPipelines...)
+	}
+	return pipelines
+}
+
+func executionTime(execution map[string]interface{}) int64 {
+	if start, ok := execution["startTime"].(float64); ok && start > 0 {
+		return int64(start)
+	}
+	buildTime, _ := execution["buildTime"].(float64)
+	return int64(buildTime)
+}
+
+// deployedVersion describes what the execution deployed: the image or build that triggered it,
+// or else the server groups its deploy stages created.
+func deployedVersion(execution map[string]interface{}) string {
+	trigger, _ := execution["trigger"].(map[string]interface{})
+	artifacts, _ := trigger["artifacts"].([]interface{})
+	for _, a := range artifacts {
+		artifact, _ := a.(map[string]interface{})
+		if artifactType, _ := artifact["type"].(string); artifactType != "docker/image" {
+			continue
+		}
+		if version, _ := artifact["version"].(string); version != "" {
+			return version
+		}
+		if reference, _ := artifact["reference"].(string); reference != "" {
+			return reference
+		}
+	}
+	if tag, _ := trigger["tag"].(string); tag != "" {
+		return tag
+	}
+	if buildNumber, ok := trigger["buildNumber"]; ok && buildNumber != nil {
+		return fmt.Sprintf("#%v", buildNumber)
+	}
+
+	var deployed []string
+	stages, _ := execution["stages"].([]interface{})
+	for _, s := range stages {
+		stage, _ := s.(map[string]interface{})
+		context, _ := stage["context"].(map[string]interface{})
+		regions, _ := context["deploy.server.groups"].(map[string]interface{})
+		for _, region := range sortedKeys(regions) {
+			names, _ := regions[region].([]interface{})
+			for _, name := range names {
+				deployed = append(deployed, fmt.Sprintf("%v", name))
+			}
+		}
+	}
+	return strings.Join(deployed, ", ")
+}
+
+func clusterApplications(clusters []interface{}) []string {
+	var applications []string
+	seen := map[string]bool{}
+	for _, c := range clusters {
+		cluster, _ := c.(map[string]interface{})
+		clusterApplications, _ := cluster["applications"].([]interface{})
+		for _, a := range clusterApplications {
+			application, _ := a.(map[string]interface{})
+			name, _ := application["application"].(string)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				applications = append(applications, name)
+			}
+		}
+	}
+	sort.Strings(applications)
+	return applications
+}
+
+// projectClusters lists the project's clusters per application and region, with the enabled
+// server groups in the account whose stack and detail match the cluster's, "*" matching any.
+func projectClusters(clusters []interface{}, serverGroups map[string][]interface{}) []projectCluster {
+	var result []projectCluster
+	for _, c := range clusters {
+		cluster, _ := c.(map[string]interface{})
+		account, _ := cluster["account"].(string)
+		stack, _ := cluster["stack"].(string)
+		detail, _ := cluster["detail"].(string)
+		applications, _ := cluster["applications"].([]interface{})
+		for _, a := range applications {
+			application, _ := a.(map[string]interface{})
+			applicationName, _ := application["application"].(string)
+			regions, _ := application["clusters"].([]interface{})
+			for _, r := range regions {
+				regionCluster, _ := r.(map[string]interface{})
+				region, _ := regionCluster["region"].(string)
+				entry := projectCluster{
+					Account:      account,
+					Stack:        stack,
+					Detail:       detail,
+					Application:  applicationName,
+					Region:       region,
+					ServerGroups: []activeServerGroup{},
+				}
+				for _, s := range serverGroups[applicationName] {
+					serverGroup, _ := s.(map[string]interface{})
+					if !inProjectCluster(serverGroup, account, region, stack, detail) {
+						continue
+					}
+					entry.ServerGroups = append(entry.ServerGroups, activeServerGroupOf(serverGroup))
+				}
+				sort.Slice(entry.ServerGroups, func(i, j int) bool {
+					return entry.ServerGroups[i].Name < entry.ServerGroups[j].Name
+				})
+				result = append(result, entry)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Stack != b.Stack {
+			return a.Stack < b.Stack
+		}
+		if a.Detail != b.Detail {
+			return a.Detail < b.Detail
+		}
+		if a.Application != b.Application {
+			return a.Application < b.Application
+		}
+		return a.Region < b.Region
+	})
+	return result
+}
+
+func inProjectCluster(serverGroup map[string]interface{}, account, region, stack, detail string) bool {
+	if disabled, _ := serverGroup["isDisabled"].(bool); disabled {
+		return false
+	}
+	if serverGroupAccount, _ := serverGroup["account"].(string); serverGroupAccount != account {
+		return false
+	}
+	serverGroupRegion, _ := serverGroup["region"].(string)
+	if serverGroupRegion != region {
+		return false
+	}
+	serverGroupStack, serverGroupDetail := serverGroupMoniker(serverGroup)
+	return (stack == "*" || stack == serverGroupStack) && (detail == "*" || detail == serverGroupDetail)
+}
+
+// serverGroupMoniker returns the stack and detail of the server group, from its moniker or else
+// from its app-stack-detail-vNNN name.
+func serverGroupMoniker(serverGroup map[string]interface{}) (string, string) {
+	if moniker, ok := serverGroup["moniker"].(map[string]interface{}); ok {
+		stack, _ := moniker["stack"].(string)
+		detail, _ := moniker["detail"].(string)
+		return stack, detail
+	}
+	name, _ := serverGroup["name"].(string)
+	parts := strings.Split(name, "-")
+	if len(parts) > 1 && strings.HasPrefix(parts[len(parts)-1], "v") {
+		parts = parts[:len(parts)-1]
+	}
+	stack, detail := "", ""
+	if len(parts) > 1 {
+		stack = parts[1]
+	}
+	if len(parts) > 2 {
+		detail = strings.Join(parts[2:], "-")
+	}
+	return stack, detail
+}
+
+func activeServerGroupOf(serverGroup map[string]interface{}) activeServerGroup {
+	active := activeServerGroup{}
+	active.Name, _ = serverGroup["name"].(string)
+	counts, _ := serverGroup["instanceCounts"].(map[string]interface{})
+	up, _ := counts["up"].(float64)
+	total, _ := counts["total"].(float64)
+	active.Up, active.Total = int(up), int(total)
+	return active
+}
+
+func renderProjectStatus(status projectStatus) []string {
+	lines := []string{util.Colorize().Color("[bold]Pipelines")}
+	application := ""
+	for _, pipeline := range status.Pipelines {
+		if pipeline.Application != application {
+			application = pipeline.Application
+			lines = append(lines, "  "+application)
+		}
+		if pipeline.Name == "" {
+			lines = append(lines, "    no pipelines in the project")
+			continue
+		}
+		if pipeline.ExecutionId == "" {
+			lines = append(lines, fmt.Sprintf("    %-30s %s", pipeline.Name, util.Colorize().Color("[yellow]NOT RUN[reset]")))
+			continue
+		}
+		line := fmt.Sprintf("    %-30s %s %s", pipeline.Name, colorExecutionStatus(pipeline.Status), formatStartTime(pipeline.StartTime))
+		if pipeline.Version != "" {
+			line += " " + pipeline.Version
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, util.Colorize().Color("[bold]Clusters"))
+	if len(status.Clusters) == 0 {
+		lines = append(lines, "  no clusters in the project")
+	}
+	for _, cluster := range status.Clusters {
+		header := fmt.Sprintf("  %s %s/%s/%s %s", cluster.Account, cluster.Application, cluster.Stack, cluster.Detail, cluster.Region)
+		if len(cluster.ServerGroups) == 0 {
+			lines = append(lines, header+" no active server groups")
+			continue
+		}
+		lines = append(lines, header)
+		for _, serverGroup := range cluster.ServerGroups {
+			lines = append(lines, fmt.Sprintf("    %-30s %d/%d up", serverGroup.Name, serverGroup.Up, serverGroup.Total))
+		}
+	}
+	return lines
+}
+
+func colorExecutionStatus(status string) string {
+	color := "[yellow]"
+	switch status {
+	case "SUCCEEDED":
+		color = "[green]"
+	case "TERMINAL", "CANCELED", "STOPPED":
+		color = "[red]"
+	}
+	return util.Colorize().Color(fmt.Sprintf("%s%-10s[reset]", color, status))
+}
+
+func formatStartTime(ms int64) string {
+	if ms == 0 {
+		return "not started"
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestProjectStatus_basic(t *testing.T) {
+	ts := testGateProjectStatusSuccess()
+	defer ts.Close()
+
+	currentCmd := NewStatusCmd(projectOptions{})
+	rootCmd := getRootCmdForTest()
+	projectCmd := NewProjectCmd(os.Stdout)
+	projectCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(projectCmd)
+
+	args := []string{"project", "status", "shop", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestProjectStatus_notFound(t *testing.T) {
+	ts := testGateProjectStatusSuccess()
+	defer ts.Close()
+
+	currentCmd := NewStatusCmd(projectOptions{})
+	rootCmd := getRootCmdForTest()
+	projectCmd := NewProjectCmd(os.Stdout)
+	projectCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(projectCmd)
+
+	args := []string{"project", "status", "missing", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestProjectStatus_board(t *testing.T) {
+	util.InitUI(false, false, "")
+	var project map[string]interface{}
+	var executions, clusters, pipelineConfigs, serverGroups []interface{}
+	json.Unmarshal([]byte(projectStatusProjectJson), &project)
+	json.Unmarshal([]byte(projectStatusExecutionsJson), &executions)
+	json.Unmarshal([]byte(projectStatusClustersJson), &clusters)
+	json.Unmarshal([]byte(projectStatusPipelineConfigsJson), &pipelineConfigs)
+	json.Unmarshal([]byte(projectStatusServerGroupsJson), &serverGroups)
+
+	status := projectStatus{
+		Project:   "shop",
+		Pipelines: projectPipelines(project["config"].(map[string]interface{}), map[string][]interface{}{"app": pipelineConfigs}, executions),
+		Clusters:  projectClusters(clusters, map[string][]interface{}{"app": serverGroups}),
+	}
+	expected := []string{
+		"Pipelines",
+		"  app",
+		"    build                          SUCCEEDED  2020-01-01 10:00 UTC #42",
+		"    deploy                         TERMINAL   2020-01-01 12:00 UTC 1.4.2",
+		"    rollback                       NOT RUN",
+		"  other",
+		"    no pipelines in the project",
+		"Clusters",
+		"  prod app/main/* us-east-1",
+		"    app-main-canary-v003           1/1 up",
+		"    app-main-v042                  3/3 up",
+		"  prod app/main/* us-west-2 no active server groups",
+	}
+	lines := renderProjectStatus(status)
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected board:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestProjectStatus_deployedVersion(t *testing.T) {
+	for expected, execution := range map[string]string{
+		"1.4.2":                        `{"trigger": {"artifacts": [{"type": "docker/image", "reference": "gcr.io/app:1.4.2", "version": "1.4.2"}]}}`,
+		"latest":                       `{"trigger": {"tag": "latest"}}`,
+		"#7":                           `{"trigger": {"buildNumber": 7}}`,
+		"app-main-v003, app-main-v004": `{"trigger": {}, "stages": [{"context": {"deploy.server.groups": {"us-west-2": ["app-main-v004"], "us-east-1": ["app-main-v003"]}}}]}`,
+		"":                             `{"trigger": {"type": "manual"}}`,
+	} {
+		var parsed map[string]interface{}
+		json.Unmarshal([]byte(execution), &parsed)
+		if version := deployedVersion(parsed); version != expected {
+			t.Errorf("Expected version %q for %s, got %q", expected, execution, version)
+		}
+	}
+}
+
+// testGateProjectStatusSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves a project with its executions, pipelines, clusters and server groups.
+func testGateProjectStatusSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/projects/shop", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(projectStatusProjectJson))
+	}))
+	mux.Handle("/projects/shop/pipelines", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(projectStatusExecutionsJson))
+	}))
+	mux.Handle("/projects/shop/clusters", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(projectStatusClustersJson))
+	}))
+	mux.Handle("/projects/missing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	mux.Handle("/applications/app/pipelineConfigs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(projectStatusPipelineConfigsJson))
+	}))
+	mux.Handle("/applications/other/pipelineConfigs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "[]")
+	}))
+	mux.Handle("/applications/app/serverGroups", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, strings.TrimSpace(projectStatusServerGroupsJson))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const projectStatusProjectJson = `
+{
+  "name": "shop",
+  "config": {
+    "applications": ["app", "other"],
+    "pipelineConfigs": [
+      {"application": "app", "pipelineConfigId": "deploy-id"},
+      {"application": "app", "pipelineConfigId": "build-id"},
+      {"application": "app", "pipelineConfigId": "rollback-id"}
+    ],
+    "clusters": [
+      {"account": "prod", "stack": "main", "detail": "*", "applications": ["app"]}
+    ]
+  }
+}
+`
+
+const projectStatusExecutionsJson = `
+[
+  {
+    "id": "exec-2",
+    "application": "app",
+    "name": "deploy",
+    "pipelineConfigId": "deploy-id",
+    "status": "TERMINAL",
+    "startTime": 1577880000000,
+    "trigger": {
+      "artifacts": [{"type": "docker/image", "name": "gcr.io/app", "reference": "gcr.io/app:1.4.2", "version": "1.4.2"}]
+    }
+  },
+  {
+    "id": "exec-1",
+    "application": "app",
+    "name": "deploy",
+    "pipelineConfigId": "deploy-id",
+    "status": "SUCCEEDED",
+    "startTime": 1577872800000
+  },
+  {
+    "id": "exec-3",
+    "application": "app",
+    "name": "build",
+    "pipelineConfigId": "build-id",
+    "status": "SUCCEEDED",
+    "startTime": 1577872800000,
+    "trigger": {"type": "jenkins", "buildNumber": 42}
+  }
+]
+`
+
+const projectStatusPipelineConfigsJson = `
+[
+  {"id": "deploy-id", "name": "deploy"},
+  {"id": "build-id", "name": "build"},
+  {"id": "rollback-id", "name": "rollback"}
+]
+`
+
+const projectStatusClustersJson = `
+[
+  {
+    "account": "prod",
+    "stack": "main",
+    "detail": "*",
+    "applications": [
+      {
+        "application": "app",
+        "clusters": [
+          {"region": "us-east-1", "instanceCounts": {"up": 4, "total": 4}},
+          {"region": "us-west-2", "instanceCounts": {"up": 0, "total": 0}}
+        ]
+      }
+    ]
+  }
+]
+`
+
+const projectStatusServerGroupsJson = `
+[
+  {"name": "app-main-v042", "account": "prod", "region": "us-east-1", "isDisabled": false, "instanceCounts": {"up": 3, "total": 3}},
+  {"name": "app-main-v041", "account": "prod", "region": "us-east-1", "isDisabled": true, "instanceCounts": {"up": 0, "total": 3}},
+  {"name": "app-main-canary-v003", "account": "prod", "region": "us-east-1", "moniker": {"app": "app", "stack": "main", "detail": "canary"}, "instanceCounts": {"up": 1, "total": 1}},
+  {"name": "app-staging-v010", "account": "prod", "region": "us-east-1", "instanceCounts": {"up": 2, "total": 2}},
+  {"name": "app-main-v042", "account": "test", "region": "us-west-2", "instanceCounts": {"up": 1, "total": 1}}
+]
+`
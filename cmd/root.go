@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"github.com/spinnaker/spin/cmd/admin"
 	"github.com/spinnaker/spin/cmd/canary"
 	"io"
 
 	"github.com/spf13/cobra"
 	"github.com/spinnaker/spin/cmd/application"
+	"github.com/spinnaker/spin/cmd/cluster"
+	"github.com/spinnaker/spin/cmd/instance"
+	"github.com/spinnaker/spin/cmd/manifest"
 	"github.com/spinnaker/spin/cmd/pipeline"
 	pipeline_template "github.com/spinnaker/spin/cmd/pipeline-template"
 	"github.com/spinnaker/spin/cmd/project"
+	server_group "github.com/spinnaker/spin/cmd/server-group"
+	"github.com/spinnaker/spin/cmd/shell"
+	"github.com/spinnaker/spin/cmd/webhook"
 	"github.com/spinnaker/spin/version"
 )
 
@@ -45,11 +52,18 @@ func NewCmdRoot(out io.Writer) *cobra.Command {
 	cmd.PersistentFlags().StringVar(&options.defaultHeaders, "default-headers", "", "configure default headers for gate client as comma separated list (e.g. key1=value1,key2=value2)")
 
 	// create subcommands
+	cmd.AddCommand(admin.NewAdminCmd(out))
 	cmd.AddCommand(application.NewApplicationCmd(out))
 	cmd.AddCommand(canary.NewCanaryCmd(out))
+	cmd.AddCommand(cluster.NewClusterCmd(out))
+	cmd.AddCommand(instance.NewInstanceCmd(out))
+	cmd.AddCommand(server_group.NewServerGroupCmd(out))
+	cmd.AddCommand(manifest.NewManifestCmd(out))
 	cmd.AddCommand(pipeline.NewPipelineCmd(out))
 	cmd.AddCommand(pipeline_template.NewPipelineTemplateCmd(out))
 	cmd.AddCommand(project.NewProjectCmd(out))
+	cmd.AddCommand(webhook.NewWebhookCmd(out))
+	cmd.AddCommand(shell.NewShellCmd(func() *cobra.Command { return NewCmdRoot(out) }))
 
 	return cmd
 }
@@ -0,0 +1,268 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package server_group
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+)
+
+type DiffOptions struct {
+	*serverGroupOptions
+	application string
+	account     string
+	region      string
+}
+
+var (
+	diffServerGroupShort   = "Show the configuration differences between two server groups"
+	diffServerGroupLong    = "Compare the launch configuration or template, image, instance type, capacity, tags, load balancers and security groups (or pod spec for Kubernetes) of two server groups, ignoring fields that differ between any two versions such as names, timestamps and instances. Server groups are given as name or account/region/name"
+	diffServerGroupExample = "usage: spin server-group diff --account prod --region us-east-1 app-main-v001 app-main-v002"
+)
+
+// volatileFields differ between any two server groups and are ignored wherever they appear.
+var volatileFields = map[string]bool{
+	"createdTime":             true,
+	"creationTimestamp":       true,
+	"resourceVersion":         true,
+	"uid":                     true,
+	"selfLink":                true,
+	"instances":               true,
+	"instanceCounts":          true,
+	"health":                  true,
+	"isDisabled":              true,
+	"disabled":                true,
+	"launchConfigurationName": true,
+	"launchConfigurationARN":  true,
+	"autoScalingGroupName":    true,
+	"autoScalingGroupARN":     true,
+	"launchTemplateId":        true,
+	"versionNumber":           true,
+	"serverGroupManagers":     true,
+}
+
+// volatilePaths differ between any two server groups but are only ignored at these paths,
+// since the same keys name containers, env vars, ports and volumes further down.
+var volatilePaths = map[string]bool{
+	"name":                         true,
+	"status":                       true,
+	"sequence":                     true,
+	"moniker.sequence":             true,
+	"manifest.metadata.name":       true,
+	"manifest.metadata.generation": true,
+	"manifest.status":              true,
+}
+
+// serverGroupDifference is a field that differs between two server groups. Missing values are nil.
+type serverGroupDifference struct {
+	Field string      `json:"field"`
+	Left  interface{} `json:"left"`
+	Right interface{} `json:"right"`
+}
+
+func NewDiffCmd(serverGroupOptions serverGroupOptions) *cobra.Command {
+	options := DiffOptions{
+		serverGroupOptions: &serverGroupOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "diff",
+		Short:   diffServerGroupShort,
+		Long:    diffServerGroupLong,
+		Example: diffServerGroupExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffServerGroups(cmd, options, args)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.application, "application", "a", "", "(optional) Spinnaker application of the server groups, derived from their names by default")
+	cmd.PersistentFlags().StringVar(&options.account, "account", "", "account of server groups not given as account/region/name")
+	cmd.PersistentFlags().StringVar(&options.region, "region", "", "region or namespace of server groups not given as account/region/name")
+
+	return cmd
+}
+
+func diffServerGroups(cmd *cobra.Command, options DiffOptions, args []string) error {
+	gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 2 {
+		return errors.New("two server groups are required, exiting")
+	}
+
+	var serverGroups []map[string]interface{}
+	var names []string
+	for _, arg := range args {
+		account, region, name := options.account, options.region, arg
+		if parts := strings.Split(arg, "/"); len(parts) == 3 {
+			account, region, name = parts[0], parts[1], parts[2]
+		}
+		if account == "" || region == "" {
+			return fmt.Errorf("Server group %s needs an account and region, either as account/region/name or with the 'account' and 'region' flags\n", arg)
+		}
+		application := options.application
+		if application == "" {
+			application = strings.SplitN(name, "-", 2)[0]
+		}
+
+		details, resp, err := gateClient.ServerGroupControllerApi.GetServerGroupDetailsUsingGET(gateClient.Context,
+			application, account, region, name, map[string]interface{}{})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("Server group %s not found in %s/%s\n", name, account, region)
+		}
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Encountered an error getting server group %s, status code: %d\n", name, resp.StatusCode)
+		}
+		serverGroup, ok := details.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Unexpected response for server group %s: %v\n", name, details)
+		}
+		serverGroups = append(serverGroups, serverGroup)
+		names = append(names, name)
+	}
+
+	differences := compareServerGroups(serverGroups[0], names[0], serverGroups[1], names[1])
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(differences, util.UI.OutputFormat)
+		return nil
+	}
+	if len(differences) == 0 {
+		util.UI.Info(fmt.Sprintf("No configuration differences between %s and %s.", names[0], names[1]))
+		return nil
+	}
+	util.UI.Output(fmt.Sprintf("--- %s\n+++ %s", names[0], names[1]))
+	for _, difference := range differences {
+		switch {
+		case difference.Left == nil:
+			util.UI.Output(util.Colorize().Color(fmt.Sprintf("[green]+ %s: %s", difference.Field, formatValue(difference.Right))))
+		case difference.Right == nil:
+			util.UI.Output(util.Colorize().Color(fmt.Sprintf("[red]- %s: %s", difference.Field, formatValue(difference.Left))))
+		default:
+			util.UI.Output(util.Colorize().Color(fmt.Sprintf("[yellow]~ %s: %s -> %s", difference.Field, formatValue(difference.Left), formatValue(difference.Right))))
+		}
+	}
+	return nil
+}
+
+// compareServerGroups returns the differing fields of the two server groups, sorted by field.
+// Each server group's own name is masked in values so derived names don't show as changes.
+func compareServerGroups(left map[string]interface{}, leftName string, right map[string]interface{}, rightName string) []serverGroupDifference {
+	leftFields := map[string]interface{}{}
+	flattenServerGroup("", left, leftName, leftFields)
+	rightFields := map[string]interface{}{}
+	flattenServerGroup("", right, rightName, rightFields)
+
+	fields := map[string]bool{}
+	for field := range leftFields {
+		fields[field] = true
+	}
+	for field := range rightFields {
+		fields[field] = true
+	}
+	var sorted []string
+	for field := range fields {
+		sorted = append(sorted, field)
+	}
+	sort.Strings(sorted)
+
+	var differences []serverGroupDifference
+	for _, field := range sorted {
+		l, r := leftFields[field], rightFields[field]
+		if !reflect.DeepEqual(l, r) {
+			differences = append(differences, serverGroupDifference{Field: field, Left: l, Right: r})
+		}
+	}
+	return differences
+}
+
+// flattenServerGroup maps dotted field paths to their scalar values, skipping volatile fields and paths.
+// Lists of scalars, such as load balancers and security groups, are compared as sorted sets.
+func flattenServerGroup(path string, value interface{}, name string, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			field := joinPath(path, key)
+			if volatileFields[key] || volatilePaths[field] {
+				continue
+			}
+			flattenServerGroup(field, child, name, fields)
+		}
+	case []interface{}:
+		if scalars, ok := scalarSet(v, name); ok {
+			if len(scalars) > 0 {
+				fields[path] = scalars
+			}
+			return
+		}
+		for i, child := range v {
+			flattenServerGroup(fmt.Sprintf("%s[%d]", path, i), child, name, fields)
+		}
+	case string:
+		fields[path] = maskName(v, name)
+	case nil:
+	default:
+		fields[path] = v
+	}
+}
+
+func scalarSet(values []interface{}, name string) ([]string, bool) {
+	set := []string{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, false
+		case string:
+			set = append(set, maskName(v, name))
+		default:
+			set = append(set, fmt.Sprintf("%v", v))
+		}
+	}
+	sort.Strings(set)
+	return set, true
+}
+
+func maskName(value string, name string) string {
+	if name == "" {
+		return value
+	}
+	return strings.Replace(value, name, "<server-group>", -1)
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func formatValue(value interface{}) string {
+	if set, ok := value.([]string); ok {
+		return "[" + strings.Join(set, ", ") + "]"
+	}
+	return fmt.Sprintf("%v", value)
+}
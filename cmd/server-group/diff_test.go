@@ -0,0 +1,276 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package server_group
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestServerGroupDiff_basic(t *testing.T) {
+	ts := testGateServerGroupSuccess()
+	defer ts.Close()
+
+	currentCmd := NewDiffCmd(serverGroupOptions{})
+	rootCmd := getRootCmdForTest()
+	serverGroupCmd := NewServerGroupCmd(os.Stdout)
+	serverGroupCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(serverGroupCmd)
+
+	args := []string{"server-group", "diff", "app-main-v001", "app-main-v002", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestServerGroupDiff_qualifiedNames(t *testing.T) {
+	ts := testGateServerGroupSuccess()
+	defer ts.Close()
+
+	currentCmd := NewDiffCmd(serverGroupOptions{})
+	rootCmd := getRootCmdForTest()
+	serverGroupCmd := NewServerGroupCmd(os.Stdout)
+	serverGroupCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(serverGroupCmd)
+
+	args := []string{"server-group", "diff", "prod/us-east-1/app-main-v001", "prod/us-east-1/app-main-v002", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+}
+
+func TestServerGroupDiff_flags(t *testing.T) {
+	ts := testGateServerGroupSuccess()
+	defer ts.Close()
+
+	currentCmd := NewDiffCmd(serverGroupOptions{})
+	rootCmd := getRootCmdForTest()
+	serverGroupCmd := NewServerGroupCmd(os.Stdout)
+	serverGroupCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(serverGroupCmd)
+
+	args := []string{"server-group", "diff", "app-main-v001", "app-main-v002", "--gate-endpoint", ts.URL} // Missing account and region.
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestServerGroupDiff_notFound(t *testing.T) {
+	ts := testGateServerGroupSuccess()
+	defer ts.Close()
+
+	currentCmd := NewDiffCmd(serverGroupOptions{})
+	rootCmd := getRootCmdForTest()
+	serverGroupCmd := NewServerGroupCmd(os.Stdout)
+	serverGroupCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(serverGroupCmd)
+
+	args := []string{"server-group", "diff", "app-main-v001", "app-main-v003", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestServerGroupDiff_fail(t *testing.T) {
+	ts := GateServerFail()
+	defer ts.Close()
+
+	currentCmd := NewDiffCmd(serverGroupOptions{})
+	rootCmd := getRootCmdForTest()
+	serverGroupCmd := NewServerGroupCmd(os.Stdout)
+	serverGroupCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(serverGroupCmd)
+
+	args := []string{"server-group", "diff", "app-main-v001", "app-main-v002", "--account", "prod", "--region", "us-east-1", "--gate-endpoint", ts.URL}
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected failure but command succeeded")
+	}
+}
+
+func TestServerGroupDiff_differences(t *testing.T) {
+	var left, right map[string]interface{}
+	json.Unmarshal([]byte(testServerGroupV001), &left)
+	json.Unmarshal([]byte(testServerGroupV002), &right)
+
+	differences := compareServerGroups(left, "app-main-v001", right, "app-main-v002")
+	var actual []string
+	for _, difference := range differences {
+		actual = append(actual, fmt.Sprintf("%s: %s -> %s", difference.Field, formatValue(difference.Left), formatValue(difference.Right)))
+	}
+	expected := []string{
+		"capacity.desired: 2 -> 3",
+		"image.imageId: ami-1 -> ami-2",
+		"launchConfig.instanceType: m5.large -> m5.xlarge",
+		"securityGroups: [sg-1, sg-2] -> [sg-1]",
+		"tags.team: <nil> -> payments",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected differences:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestServerGroupDiff_nestedNames(t *testing.T) {
+	var left, right map[string]interface{}
+	json.Unmarshal([]byte(fmt.Sprintf(testKubernetesServerGroup, "v001", "1", "LOG_LEVEL")), &left)
+	json.Unmarshal([]byte(fmt.Sprintf(testKubernetesServerGroup, "v002", "3", "LOGGING_LEVEL")), &right)
+
+	differences := compareServerGroups(left, "replicaSet app-main-v001", right, "replicaSet app-main-v002")
+	var actual []string
+	for _, difference := range differences {
+		actual = append(actual, fmt.Sprintf("%s: %s -> %s", difference.Field, formatValue(difference.Left), formatValue(difference.Right)))
+	}
+	expected := []string{
+		"manifest.spec.template.spec.containers[0].env[0].name: LOG_LEVEL -> LOGGING_LEVEL",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected differences:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+// testGateServerGroupSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Serves two versions of a server group and 404s for any other.
+func testGateServerGroupSuccess() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/serverGroups/prod/us-east-1/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/applications/app/serverGroups/prod/us-east-1/") {
+		case "app-main-v001":
+			fmt.Fprintln(w, strings.TrimSpace(testServerGroupV001))
+		case "app-main-v002":
+			fmt.Fprintln(w, strings.TrimSpace(testServerGroupV002))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return httptest.NewServer(mux)
+}
+
+// GateServerFail spins up a local http server that we will configure the GateClient
+// to direct requests to. Responds with a 500 InternalServerError.
+func GateServerFail() *httptest.Server {
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/serverGroups/prod/us-east-1/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// TODO(jacobkiefer): Mock more robust errors once implemented upstream.
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+const testServerGroupV001 = `
+{
+  "name": "app-main-v001",
+  "account": "prod",
+  "region": "us-east-1",
+  "createdTime": 1556000000000,
+  "isDisabled": true,
+  "capacity": {"min": 1, "max": 4, "desired": 2},
+  "image": {"imageId": "ami-1", "name": "app-1.0"},
+  "launchConfig": {
+    "launchConfigurationName": "app-main-v001-20190401",
+    "instanceType": "m5.large",
+    "userData": "export SERVER_GROUP=app-main-v001"
+  },
+  "loadBalancers": ["app-main-frontend"],
+  "securityGroups": ["sg-2", "sg-1"],
+  "tags": {"owner": "app-team"},
+  "instances": [{"name": "i-1"}]
+}
+`
+
+const testServerGroupV002 = `
+{
+  "name": "app-main-v002",
+  "account": "prod",
+  "region": "us-east-1",
+  "createdTime": 1557000000000,
+  "isDisabled": false,
+  "capacity": {"min": 1, "max": 4, "desired": 3},
+  "image": {"imageId": "ami-2", "name": "app-1.0"},
+  "launchConfig": {
+    "launchConfigurationName": "app-main-v002-20190501",
+    "instanceType": "m5.xlarge",
+    "userData": "export SERVER_GROUP=app-main-v002"
+  },
+  "loadBalancers": ["app-main-frontend"],
+  "securityGroups": ["sg-1"],
+  "tags": {"owner": "app-team", "team": "payments"},
+  "instances": [{"name": "i-2"}, {"name": "i-3"}]
+}
+`
+
+const testKubernetesServerGroup = `
+{
+  "name": "replicaSet app-main-%[1]s",
+  "account": "prod",
+  "region": "default",
+  "moniker": {"app": "app", "cluster": "replicaSet app-main", "sequence": %[2]s},
+  "manifest": {
+    "kind": "ReplicaSet",
+    "metadata": {
+      "name": "app-main-%[1]s",
+      "namespace": "default",
+      "generation": %[2]s,
+      "uid": "uid-%[1]s"
+    },
+    "spec": {
+      "replicas": 2,
+      "template": {
+        "spec": {
+          "containers": [
+            {
+              "name": "app",
+              "image": "gcr.io/app:1.0",
+              "env": [{"name": "%[3]s", "value": "debug"}],
+              "ports": [{"name": "http", "containerPort": 8080}]
+            }
+          ]
+        }
+      }
+    },
+    "status": {"replicas": 2, "readyReplicas": %[2]s}
+  },
+  "status": {"replicas": 2}
+}
+`
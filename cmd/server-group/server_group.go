@@ -0,0 +1,44 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package server_group
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+type serverGroupOptions struct{}
+
+var (
+	serverGroupShort   = ""
+	serverGroupLong    = ""
+	serverGroupExample = ""
+)
+
+func NewServerGroupCmd(out io.Writer) *cobra.Command {
+	options := serverGroupOptions{}
+	cmd := &cobra.Command{
+		Use:     "server-group",
+		Aliases: []string{"server-groups", "sg"},
+		Short:   serverGroupShort,
+		Long:    serverGroupLong,
+		Example: serverGroupExample,
+	}
+
+	// create subcommands
+	cmd.AddCommand(NewDiffCmd(options))
+	return cmd
+}
@@ -0,0 +1,519 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/util"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	shellShort = "Run spin commands in an interactive session"
+	shellLong  = `Run spin commands in an interactive session that authenticates with Gate once and reuses the
+connection for every command, with command history and tab completion.
+
+Besides spin commands, the shell understands:
+  use                       show the current application and pipeline
+  use app <application>     pass --application <application> to commands that take it
+  use pipeline <pipeline>   pass --name <pipeline> to pipeline and execution commands
+  unuse [app|pipeline]      clear the current selection
+  history                   list the commands run in this session
+  !<n>                      run command n from the history again
+  exit                      leave the shell`
+	shellExample = "usage: spin shell [options]"
+)
+
+// historyFileName is kept next to the spin config in the user's home directory.
+const historyFileName = "shell_history"
+
+// shellSession is the state kept between the commands of an interactive shell.
+type shellSession struct {
+	newRoot     func() *cobra.Command
+	globalArgs  []string
+	application string
+	pipeline    string
+	history     []string
+	historyFile string
+	out         io.Writer
+
+	// applications caches the application names offered for completion.
+	applications []string
+}
+
+// NewShellCmd returns the shell command. newRoot builds a fresh root command for each line,
+// so flag values don't carry over between commands.
+func NewShellCmd(newRoot func() *cobra.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "shell",
+		Short:   shellShort,
+		Long:    shellLong,
+		Example: shellExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runShell(cmd, newRoot)
+		},
+	}
+	return cmd
+}
+
+func runShell(cmd *cobra.Command, newRoot func() *cobra.Command) error {
+	gateclient.EnableSession()
+	session := &shellSession{
+		newRoot:    newRoot,
+		globalArgs: changedFlags(cmd.InheritedFlags()),
+		out:        os.Stdout,
+	}
+	if home, err := homedir.Dir(); err == nil {
+		session.historyFile = filepath.Join(home, ".spin", historyFileName)
+	}
+
+	// Authenticate up front so the first command isn't the slow one.
+	if _, err := session.gateClient(); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		reader := bufio.NewReader(os.Stdin)
+		return session.run(func() (string, error) { return reader.ReadString('\n') }, func(string) {})
+	}
+
+	term := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, session.prompt())
+	term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		completed := session.complete(line[:pos])
+		if completed == line[:pos] {
+			return "", 0, false
+		}
+		return completed + line[pos:], len(completed), true
+	}
+	readLine := func() (string, error) {
+		// Only hold the terminal in raw mode while reading, so command output renders normally.
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+		defer terminal.Restore(fd, state)
+		if width, height, err := terminal.GetSize(fd); err == nil {
+			term.SetSize(width, height)
+		}
+		return term.ReadLine()
+	}
+	return session.run(readLine, term.SetPrompt)
+}
+
+// run executes lines until the input ends or the user exits.
+func (s *shellSession) run(readLine func() (string, error), setPrompt func(string)) error {
+	for {
+		setPrompt(s.prompt())
+		line, err := readLine()
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			fmt.Fprintln(s.out)
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if exit := s.execute(strings.TrimSpace(line)); exit {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// execute runs a line of input, returning whether the shell should exit.
+func (s *shellSession) execute(line string) bool {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return false
+	}
+	if strings.HasPrefix(line, "!") {
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 1 || n > len(s.history) {
+			s.error(fmt.Sprintf("No command %s in the history", line[1:]))
+			return false
+		}
+		line = s.history[n-1]
+		fmt.Fprintln(s.out, line)
+	}
+	s.addHistory(line)
+
+	args, err := splitArgs(line)
+	if err != nil {
+		s.error(err.Error())
+		return false
+	}
+	if args[0] == "spin" {
+		args = args[1:]
+		if len(args) == 0 {
+			return false
+		}
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "use":
+		s.use(args[1:])
+	case "unuse":
+		s.unuse(args[1:])
+	case "history":
+		for i, entry := range s.history {
+			fmt.Fprintf(s.out, "%4d  %s\n", i+1, entry)
+		}
+	case "shell":
+		s.error("Already in a spin shell")
+	default:
+		s.runCommand(args)
+	}
+	return false
+}
+
+func (s *shellSession) use(args []string) {
+	switch {
+	case len(args) == 0:
+		fmt.Fprintf(s.out, "application: %s\npipeline: %s\n", valueOrNone(s.application), valueOrNone(s.pipeline))
+	case len(args) == 2 && (args[0] == "app" || args[0] == "application"):
+		s.application = args[1]
+		s.pipeline = ""
+	case len(args) == 2 && args[0] == "pipeline":
+		s.pipeline = args[1]
+	default:
+		s.error("usage: use [app <application> | pipeline <pipeline>]")
+	}
+}
+
+func (s *shellSession) unuse(args []string) {
+	switch {
+	case len(args) == 0:
+		s.application = ""
+		s.pipeline = ""
+	case len(args) == 1 && (args[0] == "app" || args[0] == "application"):
+		s.application = ""
+	case len(args) == 1 && args[0] == "pipeline":
+		s.pipeline = ""
+	default:
+		s.error("usage: unuse [app | pipeline]")
+	}
+}
+
+// runCommand runs a spin command on a fresh root command, with the shell's global flags and the
+// current application and pipeline filled in where the command takes them and they weren't given.
+func (s *shellSession) runCommand(args []string) {
+	root := s.newRoot()
+	args = append(append([]string{}, args...), s.selectionArgs(root, args)...)
+	root.SetArgs(append(append([]string{}, s.globalArgs...), args...))
+	if err := root.Execute(); err != nil {
+		s.error(err.Error())
+	}
+}
+
+func (s *shellSession) selectionArgs(root *cobra.Command, args []string) []string {
+	target, _, err := root.Find(args)
+	if err != nil || target == root {
+		return nil
+	}
+	var selection []string
+	if s.application != "" && target.LocalFlags().Lookup("application") != nil && !hasFlag(target, args, "application") {
+		selection = append(selection, "--application", s.application)
+	}
+	if s.pipeline != "" && isPipelineCommand(target) &&
+		target.LocalFlags().Lookup("name") != nil && !hasFlag(target, args, "name") {
+		selection = append(selection, "--name", s.pipeline)
+	}
+	return selection
+}
+
+// isPipelineCommand reports whether the command is a pipeline command, or one of its execution
+// or artifacts commands.
+func isPipelineCommand(cmd *cobra.Command) bool {
+	for parent := cmd.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Name() == "pipeline" {
+			return true
+		}
+		if parent.Name() != "execution" && parent.Name() != "artifacts" {
+			return false
+		}
+	}
+	return false
+}
+
+// hasFlag reports whether the flag, or its shorthand, is among the args.
+func hasFlag(cmd *cobra.Command, args []string, name string) bool {
+	flag := cmd.LocalFlags().Lookup(name)
+	for _, arg := range args {
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+		if flag != nil && flag.Shorthand != "" && (arg == "-"+flag.Shorthand || strings.HasPrefix(arg, "-"+flag.Shorthand+"=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// complete extends the last word of the line with its longest unambiguous completion: a
+// subcommand, a flag of the command, or an application or pipeline name after 'use'.
+func (s *shellSession) complete(line string) string {
+	args, err := splitArgs(line)
+	if err != nil {
+		return line
+	}
+	word := ""
+	if len(args) > 0 && !strings.HasSuffix(line, " ") {
+		word = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+
+	var candidates []string
+	switch {
+	case len(args) == 0:
+		candidates = []string{"use", "unuse", "history", "exit"}
+		for _, cmd := range s.newRoot().Commands() {
+			if cmd.IsAvailableCommand() && cmd.Name() != "shell" {
+				candidates = append(candidates, cmd.Name())
+			}
+		}
+	case (args[0] == "use" || args[0] == "unuse") && len(args) == 1:
+		candidates = []string{"app", "pipeline"}
+	case args[0] == "use" && len(args) == 2 && (args[1] == "app" || args[1] == "application"):
+		candidates = s.applicationNames()
+	case args[0] == "use" && len(args) == 2 && args[1] == "pipeline":
+		candidates = s.pipelineNames()
+	default:
+		target, _, err := s.newRoot().Find(args)
+		if err != nil {
+			return line
+		}
+		if strings.HasPrefix(word, "-") {
+			target.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+				candidates = append(candidates, "--"+flag.Name)
+			})
+			target.InheritedFlags().VisitAll(func(flag *pflag.Flag) {
+				candidates = append(candidates, "--"+flag.Name)
+			})
+		} else {
+			for _, cmd := range target.Commands() {
+				if cmd.IsAvailableCommand() {
+					candidates = append(candidates, cmd.Name())
+				}
+			}
+		}
+	}
+
+	completion := longestCommonPrefix(word, candidates)
+	if completion == "" || completion == word {
+		return line
+	}
+	if matches(word, candidates) == 1 {
+		completion += " "
+	}
+	return line[:len(line)-len(word)] + completion
+}
+
+// applicationNames lists the applications in Gate, once per session.
+func (s *shellSession) applicationNames() []string {
+	if s.applications != nil {
+		return s.applications
+	}
+	gateClient, err := s.gateClient()
+	if err != nil {
+		return nil
+	}
+	applications, _, err := gateClient.ApplicationControllerApi.GetAllApplicationsUsingGET(gateClient.Context, map[string]interface{}{})
+	if err != nil {
+		return nil
+	}
+	s.applications = []string{}
+	for _, application := range applications {
+		if name, ok := application.(map[string]interface{})["name"].(string); ok {
+			s.applications = append(s.applications, name)
+		}
+	}
+	return s.applications
+}
+
+// pipelineNames lists the pipelines of the current application.
+func (s *shellSession) pipelineNames() []string {
+	if s.application == "" {
+		return nil
+	}
+	gateClient, err := s.gateClient()
+	if err != nil {
+		return nil
+	}
+	pipelines, _, err := gateClient.ApplicationControllerApi.GetPipelineConfigsForApplicationUsingGET(gateClient.Context, s.application)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, pipeline := range pipelines {
+		if name, ok := pipeline.(map[string]interface{})["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// gateClient returns the session's client, authenticating if it hasn't yet.
+func (s *shellSession) gateClient() (*gateclient.GatewayClient, error) {
+	root := s.newRoot()
+	if err := root.ParseFlags(s.globalArgs); err != nil {
+		return nil, err
+	}
+	return gateclient.NewGateClient(root.PersistentFlags())
+}
+
+func (s *shellSession) addHistory(line string) {
+	s.history = append(s.history, line)
+	if s.historyFile == "" {
+		return
+	}
+	file, err := os.OpenFile(s.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+func (s *shellSession) prompt() string {
+	selection := s.application
+	if s.pipeline != "" {
+		selection += "/" + s.pipeline
+	}
+	if selection == "" {
+		return "spin> "
+	}
+	return fmt.Sprintf("spin(%s)> ", selection)
+}
+
+func (s *shellSession) error(message string) {
+	if util.UI != nil {
+		util.UI.Error(message)
+		return
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// changedFlags returns the flags set on the shell command, to pass on to every command.
+func changedFlags(flags *pflag.FlagSet) []string {
+	var args []string
+	flags.Visit(func(flag *pflag.Flag) {
+		args = append(args, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+	})
+	return args
+}
+
+// splitArgs splits a line into words like a POSIX shell, honouring quotes and backslashes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func longestCommonPrefix(word string, candidates []string) string {
+	prefix := ""
+	found := false
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, word) {
+			continue
+		}
+		if !found {
+			prefix = candidate
+			found = true
+			continue
+		}
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func matches(word string, candidates []string) int {
+	count := 0
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			count++
+		}
+	}
+	return count
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/application"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/cmd/pipeline"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{SilenceUsage: true, SilenceErrors: true}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	rootCmd.AddCommand(application.NewApplicationCmd(os.Stdout))
+	rootCmd.AddCommand(pipeline.NewPipelineCmd(os.Stdout))
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func testShellSession(gateEndpoint string) *shellSession {
+	return &shellSession{
+		newRoot:    getRootCmdForTest,
+		globalArgs: []string{"--gate-endpoint=" + gateEndpoint},
+		out:        &bytes.Buffer{},
+	}
+}
+
+func TestShell_reusesClient(t *testing.T) {
+	var versionProbes int
+	ts := testGateShellSuccess(&versionProbes)
+	defer ts.Close()
+
+	gateclient.EnableSession()
+	session := testShellSession(ts.URL)
+	input := bufio.NewReader(strings.NewReader("application list\nspin application list\n\nexit\napplication list\n"))
+	err := session.run(func() (string, error) { return input.ReadString('\n') }, func(string) {})
+	if err != nil {
+		t.Fatalf("Shell failed with: %s", err)
+	}
+	if versionProbes != 1 {
+		t.Fatalf("Expected Gate to be probed once for the session, got %d", versionProbes)
+	}
+	expected := []string{"application list", "spin application list", "exit"}
+	if strings.Join(session.history, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected history:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(session.history, "\n"))
+	}
+}
+
+func TestShell_selection(t *testing.T) {
+	session := testShellSession("http://localhost:8084")
+	session.execute("use app app")
+	session.execute("use pipeline deploy")
+	if session.prompt() != "spin(app/deploy)> " {
+		t.Fatalf("Unexpected prompt %q", session.prompt())
+	}
+
+	for _, c := range []struct {
+		line     string
+		expected []string
+	}{
+		{"pipeline get", []string{"--application", "app", "--name", "deploy"}},
+		{"pipeline get -a other", []string{"--name", "deploy"}},
+		{"pipeline get --name=release", []string{"--application", "app"}},
+		{"pipeline list", []string{"--application", "app"}},
+		{"pipeline execution get", []string{"--application", "app", "--name", "deploy"}},
+		{"pipeline execution list", nil},
+		{"application list", nil},
+	} {
+		args, _ := splitArgs(c.line)
+		selection := session.selectionArgs(getRootCmdForTest(), args)
+		if strings.Join(selection, " ") != strings.Join(c.expected, " ") {
+			t.Errorf("Expected %q to get %v, got %v", c.line, c.expected, selection)
+		}
+	}
+
+	session.execute("unuse pipeline")
+	if session.application != "app" || session.pipeline != "" {
+		t.Fatalf("Expected only the pipeline to be cleared, got %s/%s", session.application, session.pipeline)
+	}
+	session.execute("use app other")
+	session.execute("unuse")
+	if session.prompt() != "spin> " {
+		t.Fatalf("Expected the selection to be cleared, got prompt %q", session.prompt())
+	}
+}
+
+func TestShell_complete(t *testing.T) {
+	session := testShellSession("http://localhost:8084")
+	for line, expected := range map[string]string{
+		"pipel":                "pipeline ",
+		"pipeline ex":          "pipeline execut",
+		"pipeline get --appl":  "pipeline get --application ",
+		"use a":                "use app ",
+		"application list xyz": "application list xyz",
+	} {
+		if completed := session.complete(line); completed != expected {
+			t.Errorf("Expected %q to complete to %q, got %q", line, expected, completed)
+		}
+	}
+}
+
+func TestShell_splitArgs(t *testing.T) {
+	args, err := splitArgs(`pipeline save --file "my pipeline.json" -a 'app' it\'s`)
+	if err != nil {
+		t.Fatalf("Could not split args: %s", err)
+	}
+	expected := []string{"pipeline", "save", "--file", "my pipeline.json", "-a", "app", "it's"}
+	if strings.Join(args, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Expected %v, got %v", expected, args)
+	}
+	if _, err := splitArgs(`use app "unterminated`); err == nil {
+		t.Fatalf("Expected an unterminated quote to fail")
+	}
+}
+
+// testGateShellSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Counts the version probes made while connecting.
+func testGateShellSuccess(versionProbes *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/version", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*versionProbes++
+		fmt.Fprintln(w, `{"version": "Unknown"}`)
+	}))
+	mux.Handle("/applications", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"name": "app"}]`)
+	}))
+	return httptest.NewServer(mux)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spinnaker/spin/cmd/gateclient"
+)
+
+// expressionResolver evaluates a string holding one or more ${...} expressions.
+type expressionResolver func(value string) (interface{}, error)
+
+// gateExpressionResolver evaluates strings with Orca, through Gate, against an execution.
+func gateExpressionResolver(gateClient *gateclient.GatewayClient, execution string) expressionResolver {
+	return func(value string) (interface{}, error) {
+		evaluated, resp, err := gateClient.PipelineControllerApi.EvaluateExpressionForExecutionUsingGET(gateClient.Context, execution, value)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("Execution %s not found\n", execution)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Encountered an error evaluating %s, status code: %d\n", value, resp.StatusCode)
+		}
+		// Orca leaves the expression unevaluated and explains why in detail.
+		if detail, ok := evaluated["detail"].(map[string]interface{}); ok && len(detail) > 0 {
+			var reasons []string
+			for _, key := range sortedKeys(detail) {
+				failures, _ := detail[key].([]interface{})
+				for _, f := range failures {
+					failure, _ := f.(map[string]interface{})
+					reasons = append(reasons, fmt.Sprintf("%v", failure["description"]))
+				}
+			}
+			return nil, fmt.Errorf("Could not evaluate %s: %s\n", value, strings.Join(reasons, "; "))
+		}
+		return evaluated["result"], nil
+	}
+}
+
+// localExpressionResolver evaluates the property references in strings, such as
+// ${trigger.parameters.env} or ${parameters['env']}, against the context.
+func localExpressionResolver(context map[string]interface{}) expressionResolver {
+	return func(value string) (interface{}, error) {
+		expressions, err := findExpressions(value)
+		if err != nil {
+			return nil, err
+		}
+		var resolved strings.Builder
+		last := 0
+		for _, e := range expressions {
+			result, err := lookupPath(context, value[e.start+2:e.end-1])
+			if err != nil {
+				return nil, fmt.Errorf("Could not evaluate %s locally: %v, use --execution to evaluate it with Gate", value[e.start:e.end], err)
+			}
+			// A string that is a single expression takes the type of its value.
+			if len(expressions) == 1 && e.start == 0 && e.end == len(value) {
+				return result, nil
+			}
+			resolved.WriteString(value[last:e.start])
+			if s, ok := result.(string); ok {
+				resolved.WriteString(s)
+			} else {
+				encoded, _ := json.Marshal(result)
+				resolved.Write(encoded)
+			}
+			last = e.end
+		}
+		resolved.WriteString(value[last:])
+		return resolved.String(), nil
+	}
+}
+
+// resolveExpressions returns a copy of the value with every string holding an expression resolved.
+func resolveExpressions(value interface{}, resolve expressionResolver) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := map[string]interface{}{}
+		for key, child := range v {
+			r, err := resolveExpressions(child, resolve)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			r, err := resolveExpressions(child, resolve)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, r)
+		}
+		return resolved, nil
+	case string:
+		if strings.Contains(v, "${") {
+			return resolve(v)
+		}
+	}
+	return value, nil
+}
+
+type expressionSpan struct {
+	start int
+	end   int
+}
+
+// findExpressions locates the ${...} expressions in the string, allowing braces inside them.
+func findExpressions(value string) ([]expressionSpan, error) {
+	var spans []expressionSpan
+	for i := 0; i < len(value); i++ {
+		if !strings.HasPrefix(value[i:], "${") {
+			continue
+		}
+		depth := 0
+		end := -1
+		for j := i + 1; j < len(value) && end < 0; j++ {
+			switch value[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = j + 1
+				}
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated expression in %s", value)
+		}
+		spans = append(spans, expressionSpan{start: i, end: end})
+		i = end - 1
+	}
+	return spans, nil
+}
+
+// lookupPath follows a path through decoded JSON. It takes the JSON paths of webhook stages,
+// such as $.data.items[0].status, and property references, such as trigger.parameters['env'].
+func lookupPath(value interface{}, path string) (interface{}, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	current := value
+	for path != "" {
+		var key string
+		index := -1
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+			fallthrough
+		case isIdentifierStart(path[0]):
+			n := 0
+			for n < len(path) && isIdentifierPart(path[n]) {
+				n++
+			}
+			key, path = path[:n], path[n:]
+			if key == "" {
+				return nil, fmt.Errorf("empty property name")
+			}
+		case path[0] == '[':
+			closing := strings.Index(path, "]")
+			if closing < 0 {
+				return nil, fmt.Errorf("unterminated [ in path")
+			}
+			selector := strings.TrimSpace(path[1:closing])
+			path = path[closing+1:]
+			if len(selector) > 1 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				key = selector[1 : len(selector)-1]
+			} else if i, err := strconv.Atoi(selector); err == nil {
+				index = i
+			} else {
+				return nil, fmt.Errorf("unsupported selector [%s]", selector)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported expression at %q", path)
+		}
+
+		if index >= 0 {
+			list, ok := current.([]interface{})
+			if !ok || index >= len(list) {
+				return nil, fmt.Errorf("no element %d", index)
+			}
+			current = list[index]
+			continue
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object property", key)
+		}
+		if current, ok = object[key]; !ok {
+			return nil, fmt.Errorf("no property %s", key)
+		}
+	}
+	return current, nil
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || c == '-' || (c >= '0' && c <= '9')
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
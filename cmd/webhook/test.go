@@ -0,0 +1,392 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/cmd/pipeline/execution"
+	"github.com/spinnaker/spin/util"
+)
+
+type TestOptions struct {
+	*webhookOptions
+	stageFile   string
+	execution   string
+	selector    execution.ExecutionSelector
+	contextFile string
+	dryRun      bool
+}
+
+var (
+	testWebhookShort   = "Call a webhook stage from the local machine and show the status Spinnaker would give it"
+	testWebhookLong    = "Resolve the expressions in a webhook stage's URL, headers and payload, with Gate against an existing execution, given by id or picked with the same selectors as spin pipeline execution get, or locally against a context file, make the call from the local machine and evaluate the stage's status and progress JSON paths against the response, without running the pipeline. Local evaluation only supports property references such as ${trigger.parameters.env}. Use --dry-run to print the call as a curl command instead"
+	testWebhookExample = `usage: spin webhook test [options]
+	spin webhook test -f stage.json --execution 01E1QR7GJKXN8FZ1M0V4PCXJ5Q
+	spin webhook test -f stage.json -a app -n deploy --last-failed
+	spin webhook test -f stage.json --context context.json --dry-run`
+)
+
+const webhookTimeout = 30 * time.Second
+
+// Statuses of the webhook stage, as Orca would set them.
+const (
+	stageSucceeded = "SUCCEEDED"
+	stageRunning   = "RUNNING"
+	stageCanceled  = "CANCELED"
+	stageTerminal  = "TERMINAL"
+)
+
+// webhookRequest is the call a webhook stage makes once its expressions are resolved.
+type webhookRequest struct {
+	Method  string            `json:"method"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload interface{}       `json:"payload,omitempty"`
+}
+
+// webhookResult is the outcome of the call, and of the status check that follows it when
+// the stage waits for completion.
+type webhookResult struct {
+	Request     webhookRequest `json:"request"`
+	StatusCode  int            `json:"statusCode"`
+	Response    interface{}    `json:"response,omitempty"`
+	StatusUrl   string         `json:"statusUrl,omitempty"`
+	StatusValue interface{}    `json:"statusValue,omitempty"`
+	Progress    interface{}    `json:"progress,omitempty"`
+	Status      string         `json:"status"`
+}
+
+func NewTestCmd(webhookOptions webhookOptions) *cobra.Command {
+	options := TestOptions{
+		webhookOptions: &webhookOptions,
+	}
+	cmd := &cobra.Command{
+		Use:     "test",
+		Short:   testWebhookShort,
+		Long:    testWebhookLong,
+		Example: testWebhookExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return testWebhook(cmd, options)
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.stageFile, "file", "f", "", "path to the webhook stage JSON, reads from stdin if unset")
+	cmd.PersistentFlags().StringVar(&options.execution, "execution", "", "id of an execution to evaluate the stage's expressions against with Gate")
+	cmd.PersistentFlags().StringVar(&options.contextFile, "context", "", "path to a JSON context to evaluate the stage's expressions against locally")
+	options.selector.AddFlags(cmd)
+	cmd.PersistentFlags().BoolVar(&options.dryRun, "dry-run", false, "print the call as a curl command instead of making it")
+
+	return cmd
+}
+
+func testWebhook(cmd *cobra.Command, options TestOptions) error {
+	useExecution := options.execution != "" || options.selector.IsSet()
+	if useExecution && options.contextFile != "" {
+		return errors.New("use only one of --execution, the execution selectors and --context")
+	}
+
+	stage, err := util.ParseJsonFromFileOrStdin(options.stageFile, false)
+	if err != nil {
+		return err
+	}
+	if stage == nil {
+		return errors.New("no webhook stage supplied, exiting")
+	}
+
+	var resolve expressionResolver
+	switch {
+	case useExecution:
+		gateClient, err := gateclient.NewGateClient(cmd.InheritedFlags())
+		if err != nil {
+			return err
+		}
+		executionId, err := options.selector.ExecutionId(gateClient, options.execution)
+		if err != nil {
+			return err
+		}
+		resolve = gateExpressionResolver(gateClient, executionId)
+	case options.contextFile != "":
+		if _, err := gateclient.LoadConfig(cmd.InheritedFlags()); err != nil {
+			return err
+		}
+		context, err := util.ParseJsonFromFile(options.contextFile, false)
+		if err != nil {
+			return err
+		}
+		resolve = localExpressionResolver(context)
+	default:
+		if _, err := gateclient.LoadConfig(cmd.InheritedFlags()); err != nil {
+			return err
+		}
+		resolve = func(expression string) (interface{}, error) {
+			return nil, fmt.Errorf("%s needs --execution or --context to be evaluated", expression)
+		}
+	}
+
+	resolved, err := resolveExpressions(stage, resolve)
+	if err != nil {
+		return err
+	}
+	stage = resolved.(map[string]interface{})
+	request, err := buildWebhookRequest(stage)
+	if err != nil {
+		return err
+	}
+
+	if options.dryRun {
+		util.UI.Output(curlCommand(request))
+		return nil
+	}
+
+	result, err := callWebhook(&http.Client{Timeout: webhookTimeout}, stage, request)
+	if err != nil {
+		return err
+	}
+
+	if util.UI.OutputFormat != nil && util.UI.OutputFormat.JsonPath != "" {
+		util.UI.JsonOutput(result, util.UI.OutputFormat)
+	} else {
+		for _, line := range renderWebhookResult(result) {
+			util.UI.Output(line)
+		}
+	}
+	if result.Status == stageTerminal {
+		return fmt.Errorf("The webhook stage would fail, the call returned status code %d\n", result.StatusCode)
+	}
+	return nil
+}
+
+func buildWebhookRequest(stage map[string]interface{}) (webhookRequest, error) {
+	url, _ := stage["url"].(string)
+	if url == "" {
+		return webhookRequest{}, errors.New("the stage has no url, preconfigured webhook stages get theirs from Orca's configuration")
+	}
+	method, _ := stage["method"].(string)
+	if method == "" {
+		method = http.MethodPost
+	}
+	request := webhookRequest{
+		Method:  strings.ToUpper(method),
+		Url:     url,
+		Headers: map[string]string{},
+		Payload: stage["payload"],
+	}
+	if request.Payload != nil {
+		request.Headers["Content-Type"] = "application/json"
+	}
+	headers, _ := stage["customHeaders"].(map[string]interface{})
+	for name, value := range headers {
+		// Orca accepts a list of values for a header.
+		if values, ok := value.([]interface{}); ok {
+			var joined []string
+			for _, v := range values {
+				joined = append(joined, fmt.Sprintf("%v", v))
+			}
+			request.Headers[name] = strings.Join(joined, ",")
+			continue
+		}
+		request.Headers[name] = fmt.Sprintf("%v", value)
+	}
+	return request, nil
+}
+
+// callWebhook makes the call and, when the stage waits for completion, checks its status
+// URL once the way Orca's monitor task would.
+func callWebhook(client *http.Client, stage map[string]interface{}, request webhookRequest) (webhookResult, error) {
+	result := webhookResult{Request: request}
+	resp, body, err := doWebhookRequest(client, request)
+	if err != nil {
+		return result, err
+	}
+	result.StatusCode = resp.StatusCode
+	result.Response = body
+
+	if statusCodeIn(stage["failFastStatusCodes"], resp.StatusCode) || resp.StatusCode >= 300 {
+		result.Status = stageTerminal
+		return result, nil
+	}
+	if wait, _ := stage["waitForCompletion"].(bool); !wait {
+		result.Status = stageSucceeded
+		return result, nil
+	}
+
+	resolution, _ := stage["statusUrlResolution"].(string)
+	switch resolution {
+	case "locationHeader":
+		result.StatusUrl = resp.Header.Get("Location")
+	case "webhookResponse":
+		path, _ := stage["statusUrlJsonPath"].(string)
+		value, err := lookupPath(body, path)
+		if err != nil {
+			return result, fmt.Errorf("Could not read the status url at %s from the response: %v\n", path, err)
+		}
+		result.StatusUrl, _ = value.(string)
+	default:
+		result.StatusUrl = request.Url
+	}
+	if result.StatusUrl == "" {
+		return result, fmt.Errorf("Could not resolve the status url with %s\n", resolution)
+	}
+
+	statusRequest := webhookRequest{Method: http.MethodGet, Url: result.StatusUrl, Headers: request.Headers}
+	resp, body, err = doWebhookRequest(client, statusRequest)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode >= 300 {
+		return result, fmt.Errorf("Encountered an error checking the status at %s, status code: %d\n", result.StatusUrl, resp.StatusCode)
+	}
+
+	statusPath, _ := stage["statusJsonPath"].(string)
+	if statusPath == "" {
+		return result, errors.New("the stage waits for completion but has no statusJsonPath")
+	}
+	result.StatusValue, err = lookupPath(body, statusPath)
+	if err != nil {
+		return result, fmt.Errorf("Could not read the status at %s from the status response: %v\n", statusPath, err)
+	}
+	if progressPath, _ := stage["progressJsonPath"].(string); progressPath != "" {
+		result.Progress, err = lookupPath(body, progressPath)
+		if err != nil {
+			return result, fmt.Errorf("Could not read the progress at %s from the status response: %v\n", progressPath, err)
+		}
+	}
+	result.Status = webhookStageStatus(stage, result.StatusValue)
+	return result, nil
+}
+
+func doWebhookRequest(client *http.Client, request webhookRequest) (*http.Response, interface{}, error) {
+	var requestBody bytes.Buffer
+	if request.Payload != nil && request.Method != http.MethodGet {
+		if err := json.NewEncoder(&requestBody).Encode(request.Payload); err != nil {
+			return nil, nil, err
+		}
+	}
+	req, err := http.NewRequest(request.Method, request.Url, &requestBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		body = string(raw)
+	}
+	return resp, body, nil
+}
+
+func statusCodeIn(codes interface{}, statusCode int) bool {
+	list, _ := codes.([]interface{})
+	for _, code := range list {
+		if fmt.Sprintf("%v", code) == fmt.Sprintf("%d", statusCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookStageStatus maps the value found at the status JSON path to a stage status with
+// the stage's comma separated success, canceled and terminal statuses.
+func webhookStageStatus(stage map[string]interface{}, value interface{}) string {
+	status := strings.ToUpper(fmt.Sprintf("%v", value))
+	for _, candidate := range []struct {
+		key    string
+		status string
+	}{
+		{"successStatuses", stageSucceeded},
+		{"canceledStatuses", stageCanceled},
+		{"terminalStatuses", stageTerminal},
+	} {
+		statuses, _ := stage[candidate.key].(string)
+		for _, s := range strings.Split(statuses, ",") {
+			if s = strings.ToUpper(strings.TrimSpace(s)); s != "" && s == status {
+				return candidate.status
+			}
+		}
+	}
+	return stageRunning
+}
+
+func curlCommand(request webhookRequest) string {
+	parts := []string{"curl", "-X", request.Method, shellQuote(request.Url)}
+	var names []string
+	for name := range request.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, "-H", shellQuote(name+": "+request.Headers[name]))
+	}
+	if request.Payload != nil && request.Method != http.MethodGet {
+		payload, _ := json.Marshal(request.Payload)
+		parts = append(parts, "--data", shellQuote(string(payload)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func renderWebhookResult(result webhookResult) []string {
+	lines := []string{fmt.Sprintf("%s %s returned %d", result.Request.Method, result.Request.Url, result.StatusCode)}
+	if result.Response != nil {
+		response, _ := json.MarshalIndent(result.Response, "", "  ")
+		if s, ok := result.Response.(string); ok {
+			response = []byte(s)
+		}
+		lines = append(lines, string(response))
+	}
+	if result.StatusUrl != "" {
+		lines = append(lines, fmt.Sprintf("Status url: %s", result.StatusUrl))
+		status, _ := json.Marshal(result.StatusValue)
+		lines = append(lines, fmt.Sprintf("Status: %s", status))
+	}
+	if result.Progress != nil {
+		progress, _ := json.Marshal(result.Progress)
+		lines = append(lines, fmt.Sprintf("Progress: %s", progress))
+	}
+	color := "[yellow]"
+	switch result.Status {
+	case stageSucceeded:
+		color = "[green]"
+	case stageTerminal:
+		color = "[red]"
+	}
+	lines = append(lines, util.Colorize().Color(fmt.Sprintf("[reset][bold]Stage status: %s%s", color, result.Status)))
+	return lines
+}
@@ -0,0 +1,317 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spinnaker/spin/util"
+)
+
+func getRootCmdForTest() *cobra.Command {
+	rootCmd := &cobra.Command{}
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.spin/config)")
+	rootCmd.PersistentFlags().String("gate-endpoint", "", "Gate (API server) endpoint. Default http://localhost:8084")
+	rootCmd.PersistentFlags().Bool("insecure", false, "Ignore Certificate Errors")
+	rootCmd.PersistentFlags().Bool("quiet", false, "Squelch non-essential output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable color")
+	rootCmd.PersistentFlags().String("output", "", "Configure output formatting")
+	rootCmd.PersistentFlags().String("default-headers", "", "Configure additional headers for gate client requests")
+	util.InitUI(false, false, "")
+	return rootCmd
+}
+
+func TestWebhookTest_context(t *testing.T) {
+	var received []string
+	hook := testWebhookServer(&received)
+	defer hook.Close()
+
+	stageFile := tempJsonFile(t, testWebhookStageJson)
+	defer os.Remove(stageFile)
+	contextFile := tempJsonFile(t, fmt.Sprintf(testWebhookContextJson, hook.URL))
+	defer os.Remove(contextFile)
+
+	args := []string{"webhook", "test", "-f", stageFile, "--context", contextFile}
+	err := executeWebhookCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	expected := []string{
+		`POST /hooks X-Env=prod {"env":"prod","items":[],"replicas":3,"summary":"deploy 3 to prod"}`,
+		"GET /status/42 X-Env=prod",
+	}
+	if strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected calls:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(received, "\n"))
+	}
+}
+
+func TestWebhookTest_execution(t *testing.T) {
+	var received []string
+	hook := testWebhookServer(&received)
+	defer hook.Close()
+	ts := testGateEvaluateSuccess(hook.URL)
+	defer ts.Close()
+
+	stageFile := tempJsonFile(t, testWebhookStageJson)
+	defer os.Remove(stageFile)
+
+	args := []string{"webhook", "test", "-f", stageFile, "--execution", "exec", "--gate-endpoint", ts.URL}
+	err := executeWebhookCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(received) != 2 || !strings.HasPrefix(received[0], `POST /hooks X-Env=prod {"env":"prod","items":[],"replicas":3`) {
+		t.Fatalf("Expected the call to use the values evaluated by Gate, got: %v", received)
+	}
+}
+
+func TestWebhookTest_executionSelector(t *testing.T) {
+	var received []string
+	hook := testWebhookServer(&received)
+	defer hook.Close()
+	ts := testGateEvaluateSuccess(hook.URL)
+	defer ts.Close()
+
+	stageFile := tempJsonFile(t, testWebhookStageJson)
+	defer os.Remove(stageFile)
+
+	args := []string{"webhook", "test", "-f", stageFile, "-a", "app", "-n", "deploy", "--last-failed", "--gate-endpoint", ts.URL}
+	err := executeWebhookCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(received) != 2 || !strings.HasPrefix(received[0], `POST /hooks X-Env=prod {"env":"prod","items":[],"replicas":3`) {
+		t.Fatalf("Expected the call to use the values evaluated against the selected execution, got: %v", received)
+	}
+}
+
+func TestWebhookTest_dryRun(t *testing.T) {
+	var received []string
+	hook := testWebhookServer(&received)
+	defer hook.Close()
+
+	stageFile := tempJsonFile(t, testWebhookStageJson)
+	defer os.Remove(stageFile)
+	contextFile := tempJsonFile(t, fmt.Sprintf(testWebhookContextJson, hook.URL))
+	defer os.Remove(contextFile)
+
+	args := []string{"webhook", "test", "-f", stageFile, "--context", contextFile, "--dry-run"}
+	err := executeWebhookCmd(args)
+	if err != nil {
+		t.Fatalf("Command failed with: %s", err)
+	}
+	if len(received) != 0 {
+		t.Fatalf("Expected a dry run to make no calls, got: %v", received)
+	}
+}
+
+func TestWebhookTest_terminal(t *testing.T) {
+	var received []string
+	hook := testWebhookServer(&received)
+	defer hook.Close()
+
+	stageFile := tempJsonFile(t, fmt.Sprintf(`{"type": "webhook", "url": "%s/fail", "method": "GET"}`, hook.URL))
+	defer os.Remove(stageFile)
+
+	args := []string{"webhook", "test", "-f", stageFile}
+	err := executeWebhookCmd(args)
+	if err == nil {
+		t.Fatalf("Expected a failing webhook to fail the command")
+	}
+}
+
+func TestWebhookTest_flags(t *testing.T) {
+	stageFile := tempJsonFile(t, testWebhookStageJson)
+	defer os.Remove(stageFile)
+	contextFile := tempJsonFile(t, `{}`)
+	defer os.Remove(contextFile)
+
+	for _, args := range [][]string{
+		{"webhook", "test", "-f", stageFile},
+		{"webhook", "test", "-f", stageFile, "--context", contextFile},
+		{"webhook", "test", "-f", stageFile, "--context", contextFile, "--execution", "exec"},
+		{"webhook", "test", "-f", stageFile, "--context", contextFile, "-a", "app", "-n", "deploy", "--last"},
+		{"webhook", "test", "-f", stageFile, "--last-failed"},
+	} {
+		if err := executeWebhookCmd(args); err == nil {
+			t.Errorf("Expected %v to fail but it succeeded", args)
+		}
+	}
+}
+
+func TestWebhookTest_status(t *testing.T) {
+	stage := map[string]interface{}{
+		"successStatuses":  "done, finished",
+		"canceledStatuses": "CANCELED",
+		"terminalStatuses": "error",
+	}
+	for value, expected := range map[string]string{
+		"DONE":     stageSucceeded,
+		"finished": stageSucceeded,
+		"canceled": stageCanceled,
+		"ERROR":    stageTerminal,
+		"working":  stageRunning,
+	} {
+		if status := webhookStageStatus(stage, value); status != expected {
+			t.Errorf("Expected %s to be %s, got %s", value, expected, status)
+		}
+	}
+}
+
+func TestWebhookTest_lookupPath(t *testing.T) {
+	var document interface{}
+	json.Unmarshal([]byte(`{"data": {"items": [{"state": "ok"}], "build-status": "done"}, "parameters": {"my env": "prod"}}`), &document)
+	for path, expected := range map[string]interface{}{
+		"$.data.items[0].state":     "ok",
+		"data.items[0]['state']":    "ok",
+		"$['data']['build-status']": "done",
+		`parameters["my env"]`:      "prod",
+	} {
+		value, err := lookupPath(document, path)
+		if err != nil || value != expected {
+			t.Errorf("Expected %s to be %v, got %v (%v)", path, expected, value, err)
+		}
+	}
+	for _, path := range []string{"$.data.items[1]", "data.missing", "data.items + 1", "#root.data"} {
+		if _, err := lookupPath(document, path); err == nil {
+			t.Errorf("Expected %s to fail", path)
+		}
+	}
+}
+
+func executeWebhookCmd(args []string) error {
+	currentCmd := NewTestCmd(webhookOptions{})
+	rootCmd := getRootCmdForTest()
+	webhookCmd := NewWebhookCmd(os.Stdout)
+	webhookCmd.AddCommand(currentCmd)
+	rootCmd.AddCommand(webhookCmd)
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+func tempJsonFile(t *testing.T, content string) string {
+	tempFile, err := ioutil.TempFile("", "webhook*.json")
+	if err != nil {
+		t.Fatalf("Could not create temp file: %s", err)
+	}
+	defer tempFile.Close()
+	tempFile.WriteString(strings.TrimSpace(content))
+	return tempFile.Name()
+}
+
+// testWebhookServer serves a webhook that starts a job and a status endpoint for it,
+// and records the calls it receives.
+func testWebhookServer(received *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+	record := func(r *http.Request) {
+		call := fmt.Sprintf("%s %s X-Env=%s", r.Method, r.URL.Path, r.Header.Get("X-Env"))
+		if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+			call += " " + strings.TrimSpace(string(body))
+		}
+		*received = append(*received, call)
+	}
+	mux.Handle("/hooks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Location", "http://"+r.Host+"/status/42")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, `{"id": "42"}`)
+	}))
+	mux.Handle("/status/42", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		fmt.Fprintln(w, `{"job": {"state": "DONE", "percent": 100}}`)
+	}))
+	mux.Handle("/fail", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	return httptest.NewServer(mux)
+}
+
+// testGateEvaluateSuccess spins up a local http server that we will configure the GateClient
+// to direct requests to. Evaluates the expressions of the test stage like Orca would.
+func testGateEvaluateSuccess(hookUrl string) *httptest.Server {
+	results := map[string]interface{}{
+		"${webhookBase}/hooks":           hookUrl + "/hooks",
+		"${trigger.parameters.env}":      "prod",
+		"${trigger.parameters.replicas}": 3,
+		"deploy ${trigger.parameters.replicas} to ${trigger.parameters.env}": "deploy 3 to prod",
+	}
+	mux := util.TestGateMuxWithVersionHandler()
+	mux.Handle("/applications/app/pipelineConfigs/deploy", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"id": "deploy-id", "name": "deploy", "application": "app"}`)
+	}))
+	mux.Handle("/executions", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"id": "exec", "status": "TERMINAL"}]`)
+	}))
+	mux.Handle("/pipelines/exec/evaluateExpression", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expression := r.URL.Query().Get("expression")
+		result, ok := results[expression]
+		if !ok {
+			payload, _ := json.Marshal(map[string]interface{}{
+				"result": expression,
+				"detail": map[string]interface{}{expression: []interface{}{map[string]string{"description": "unknown"}}},
+			})
+			fmt.Fprintln(w, string(payload))
+			return
+		}
+		payload, _ := json.Marshal(map[string]interface{}{"result": result})
+		fmt.Fprintln(w, string(payload))
+	}))
+	return httptest.NewServer(mux)
+}
+
+const testWebhookStageJson = `
+{
+  "type": "webhook",
+  "name": "Start deploy job",
+  "url": "${webhookBase}/hooks",
+  "method": "post",
+  "customHeaders": {
+    "X-Env": "${trigger.parameters.env}"
+  },
+  "payload": {
+    "env": "${trigger.parameters.env}",
+    "items": [],
+    "replicas": "${trigger.parameters.replicas}",
+    "summary": "deploy ${trigger.parameters.replicas} to ${trigger.parameters.env}"
+  },
+  "waitForCompletion": true,
+  "statusUrlResolution": "locationHeader",
+  "statusJsonPath": "$.job.state",
+  "progressJsonPath": "$.job.percent",
+  "successStatuses": "DONE",
+  "terminalStatuses": "FAILED"
+}
+`
+
+const testWebhookContextJson = `
+{
+  "webhookBase": "%s",
+  "trigger": {
+    "parameters": {
+      "env": "prod",
+      "replicas": 3
+    }
+  }
+}
+`
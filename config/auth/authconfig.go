@@ -23,6 +23,17 @@ import (
 	"github.com/spinnaker/spin/config/auth/x509"
 )
 
+// Names of the authentication methods accepted in AuthConfig.Methods.
+// Each name matches the key of the method's configuration block.
+const (
+	MethodX509                 = "x509"
+	MethodOAuth2               = "oauth2"
+	MethodBasic                = "basic"
+	MethodIap                  = "iap"
+	MethodLdap                 = "ldap"
+	MethodGoogleServiceAccount = "google_service_account"
+)
+
 // AuthConfig is the CLI's authentication configuration.
 type AuthConfig struct {
 	Enabled bool                 `yaml:"enabled"`
@@ -30,7 +41,16 @@ type AuthConfig struct {
 	OAuth2  *oauth2.OAuth2Config `yaml:"oauth2,omitempty"`
 	Basic   *basic.BasicConfig   `yaml:"basic,omitempty"`
 	Iap     *config.IapConfig    `yaml:"iap,omitempty"`
-	Ldap    *ldap.LdapConfig     `yaml:"ldap,omitempty`
+	Ldap    *ldap.LdapConfig     `yaml:"ldap,omitempty"`
+
+	// Methods is an optional ordered list of authentication methods to try.
+	// The first method Gate accepts is used for the rest of the command.
+	Methods []string `yaml:"methods,omitempty"`
 
 	GoogleServiceAccount *gsa.GoogleServiceAccountConfig `yaml:"google_service_account,omitempty"`
 }
+
+// HasMethods returns true if an ordered list of authentication methods is configured.
+func (a *AuthConfig) HasMethods() bool {
+	return a != nil && a.Enabled && len(a.Methods) > 0
+}
@@ -48,12 +48,12 @@ func GetIapToken(iapConfig IapConfig) (string, error) {
 	done := make(chan error)
 
 	if err != nil {
-		fmt.Errorf("Failed to listen on open port")
+		return "", fmt.Errorf("Failed to listen on open port: %v", err)
 	}
 	port := listener.Addr().(*net.TCPAddr).Port
 	clientStateToken := make([]byte, serverStateTokenLen)
 	if _, err = rand.Read(clientStateToken); err != nil {
-		fmt.Errorf("Failed to create state token")
+		return "", fmt.Errorf("Failed to create state token: %v", err)
 	}
 	clientState := base64.URLEncoding.EncodeToString(clientStateToken)
 
@@ -23,5 +23,17 @@ type Config struct {
 	Gate struct {
 		Endpoint string `yaml:"endpoint"`
 	} `yaml:"gate"`
+	Deck struct {
+		Endpoint string `yaml:"endpoint"`
+	} `yaml:"deck"`
+	Canary struct {
+		// Endpoint of a standalone Kayenta API. When set, canary commands call Kayenta
+		// directly instead of going through Gate.
+		Endpoint string `yaml:"endpoint"`
+	} `yaml:"canary"`
+	Trace struct {
+		// Endpoint of an OTLP/HTTP collector that execution traces are sent to.
+		Endpoint string `yaml:"endpoint"`
+	} `yaml:"trace"`
 	Auth *auth.AuthConfig `yaml:"auth"`
 }
@@ -0,0 +1,142 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ci
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	ProviderGitHubActions = "github-actions"
+	ProviderGitLabCI      = "gitlab-ci"
+	ProviderJenkins       = "jenkins"
+
+	// OutputFileEnv names a file to write step outputs to in CI systems without a standard
+	// outputs file, e.g. a GitLab dotenv report or a Jenkins properties file.
+	OutputFileEnv = "SPIN_OUTPUT"
+)
+
+// Environment describes the CI build spin is running in.
+type Environment struct {
+	Provider   string
+	Repository string
+	Sha        string
+	Branch     string
+	BuildUrl   string
+	Actor      string
+
+	outputFile string
+}
+
+// Detect returns the CI build spin is running in from the standard environment variables of
+// the supported CI systems, or nil when not running in CI.
+func Detect() *Environment {
+	var env *Environment
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		env = &Environment{
+			Provider:   ProviderGitHubActions,
+			Repository: os.Getenv("GITHUB_REPOSITORY"),
+			Sha:        os.Getenv("GITHUB_SHA"),
+			Branch:     firstNonEmpty(os.Getenv("GITHUB_HEAD_REF"), os.Getenv("GITHUB_REF_NAME"), strings.TrimPrefix(os.Getenv("GITHUB_REF"), "refs/heads/")),
+			Actor:      os.Getenv("GITHUB_ACTOR"),
+			outputFile: os.Getenv("GITHUB_OUTPUT"),
+		}
+		if server, runId := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_RUN_ID"); server != "" && runId != "" {
+			env.BuildUrl = fmt.Sprintf("%s/%s/actions/runs/%s", server, env.Repository, runId)
+		}
+	case os.Getenv("GITLAB_CI") == "true":
+		env = &Environment{
+			Provider:   ProviderGitLabCI,
+			Repository: os.Getenv("CI_PROJECT_PATH"),
+			Sha:        os.Getenv("CI_COMMIT_SHA"),
+			Branch:     firstNonEmpty(os.Getenv("CI_COMMIT_BRANCH"), os.Getenv("CI_COMMIT_REF_NAME")),
+			BuildUrl:   firstNonEmpty(os.Getenv("CI_JOB_URL"), os.Getenv("CI_PIPELINE_URL")),
+			Actor:      os.Getenv("GITLAB_USER_LOGIN"),
+		}
+	case os.Getenv("JENKINS_URL") != "":
+		env = &Environment{
+			Provider:   ProviderJenkins,
+			Repository: os.Getenv("GIT_URL"),
+			Sha:        os.Getenv("GIT_COMMIT"),
+			Branch:     firstNonEmpty(os.Getenv("BRANCH_NAME"), os.Getenv("GIT_BRANCH")),
+			BuildUrl:   os.Getenv("BUILD_URL"),
+			Actor:      firstNonEmpty(os.Getenv("BUILD_USER_ID"), os.Getenv("CHANGE_AUTHOR")),
+		}
+	default:
+		return nil
+	}
+
+	if env.outputFile == "" {
+		env.outputFile = os.Getenv(OutputFileEnv)
+	}
+	return env
+}
+
+// TriggerMetadata returns the build metadata to attach to pipeline triggers.
+func (e *Environment) TriggerMetadata() map[string]interface{} {
+	metadata := map[string]interface{}{"provider": e.Provider}
+	for key, value := range map[string]string{
+		"repository": e.Repository,
+		"sha":        e.Sha,
+		"branch":     e.Branch,
+		"buildUrl":   e.BuildUrl,
+		"actor":      e.Actor,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// WriteOutputs appends the outputs to the CI system's step outputs file as name=value lines.
+// Nothing is written when the CI system has no outputs file configured.
+func (e *Environment) WriteOutputs(outputs map[string]string) error {
+	if e.outputFile == "" {
+		return nil
+	}
+
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines strings.Builder
+	for _, name := range names {
+		lines.WriteString(fmt.Sprintf("%s=%s\n", name, outputs[name]))
+	}
+
+	f, err := os.OpenFile(e.outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not open CI output file %s: %v", e.outputFile, err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(lines.String())
+	return err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2019, Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ci
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// ciVariables are all the environment variables Detect reads.
+var ciVariables = []string{
+	"GITHUB_ACTIONS", "GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_HEAD_REF", "GITHUB_REF_NAME", "GITHUB_REF",
+	"GITHUB_ACTOR", "GITHUB_SERVER_URL", "GITHUB_RUN_ID", "GITHUB_OUTPUT",
+	"GITLAB_CI", "CI_PROJECT_PATH", "CI_COMMIT_SHA", "CI_COMMIT_BRANCH", "CI_COMMIT_REF_NAME", "CI_JOB_URL",
+	"CI_PIPELINE_URL", "GITLAB_USER_LOGIN",
+	"JENKINS_URL", "GIT_URL", "GIT_COMMIT", "BRANCH_NAME", "GIT_BRANCH", "BUILD_URL", "BUILD_USER_ID", "CHANGE_AUTHOR",
+	OutputFileEnv,
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected *Environment
+	}{
+		{
+			name:     "none",
+			env:      map[string]string{},
+			expected: nil,
+		},
+		{
+			name: "github",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REPOSITORY": "spinnaker/spin",
+				"GITHUB_SHA":        "abc123",
+				"GITHUB_REF":        "refs/heads/main",
+				"GITHUB_ACTOR":      "octocat",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_RUN_ID":     "42",
+				"GITHUB_OUTPUT":     "/tmp/github-output",
+			},
+			expected: &Environment{
+				Provider:   ProviderGitHubActions,
+				Repository: "spinnaker/spin",
+				Sha:        "abc123",
+				Branch:     "main",
+				BuildUrl:   "https://github.com/spinnaker/spin/actions/runs/42",
+				Actor:      "octocat",
+				outputFile: "/tmp/github-output",
+			},
+		},
+		{
+			name: "github pull request",
+			env: map[string]string{
+				"GITHUB_ACTIONS":  "true",
+				"GITHUB_HEAD_REF": "feature",
+				"GITHUB_REF":      "refs/pull/7/merge",
+			},
+			expected: &Environment{
+				Provider: ProviderGitHubActions,
+				Branch:   "feature",
+			},
+		},
+		{
+			name: "gitlab",
+			env: map[string]string{
+				"GITLAB_CI":          "true",
+				"CI_PROJECT_PATH":    "group/app",
+				"CI_COMMIT_SHA":      "def456",
+				"CI_COMMIT_REF_NAME": "release",
+				"CI_PIPELINE_URL":    "https://gitlab.com/group/app/-/pipelines/1",
+				"GITLAB_USER_LOGIN":  "someone",
+				OutputFileEnv:        "build.env",
+			},
+			expected: &Environment{
+				Provider:   ProviderGitLabCI,
+				Repository: "group/app",
+				Sha:        "def456",
+				Branch:     "release",
+				BuildUrl:   "https://gitlab.com/group/app/-/pipelines/1",
+				Actor:      "someone",
+				outputFile: "build.env",
+			},
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL":   "https://jenkins.example.com/",
+				"GIT_URL":       "https://github.com/spinnaker/spin.git",
+				"GIT_COMMIT":    "789abc",
+				"GIT_BRANCH":    "origin/main",
+				"BUILD_URL":     "https://jenkins.example.com/job/spin/3/",
+				"CHANGE_AUTHOR": "someone",
+			},
+			expected: &Environment{
+				Provider:   ProviderJenkins,
+				Repository: "https://github.com/spinnaker/spin.git",
+				Sha:        "789abc",
+				Branch:     "origin/main",
+				BuildUrl:   "https://jenkins.example.com/job/spin/3/",
+				Actor:      "someone",
+			},
+		},
+	}
+	for _, test := range tests {
+		restore := setCiEnvironment(t, test.env)
+		if env := Detect(); !reflect.DeepEqual(env, test.expected) {
+			t.Errorf("Expected %s environment %+v, got %+v", test.name, test.expected, env)
+		}
+		restore()
+	}
+}
+
+func TestWriteOutputs(t *testing.T) {
+	outputFile, err := ioutil.TempFile("" /* /tmp dir. */, "ci-output")
+	if err != nil {
+		t.Fatalf("Could not create temp output file: %s", err)
+	}
+	defer os.Remove(outputFile.Name())
+	outputFile.WriteString("earlier=step\n")
+
+	env := &Environment{Provider: ProviderGitHubActions, outputFile: outputFile.Name()}
+	outputs := map[string]string{
+		"execution_url":    "https://deck/#/applications/app/executions/details/01ABC",
+		"execution_id":     "01ABC",
+		"execution_status": "SUCCEEDED",
+	}
+	if err := env.WriteOutputs(outputs); err != nil {
+		t.Fatalf("Could not write outputs: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(outputFile.Name())
+	if err != nil {
+		t.Fatalf("Could not read output file: %s", err)
+	}
+	expected := "earlier=step\n" +
+		"execution_id=01ABC\n" +
+		"execution_status=SUCCEEDED\n" +
+		"execution_url=https://deck/#/applications/app/executions/details/01ABC\n"
+	if string(content) != expected {
+		t.Fatalf("Expected outputs:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+func TestWriteOutputs_noOutputFile(t *testing.T) {
+	env := &Environment{Provider: ProviderJenkins}
+	if err := env.WriteOutputs(map[string]string{"execution_id": "01ABC"}); err != nil {
+		t.Fatalf("Expected outputs to be skipped without an output file, got: %s", err)
+	}
+}
+
+// setCiEnvironment clears the CI environment variables and sets the given ones, and returns a
+// function restoring the environment the tests run in.
+func setCiEnvironment(t *testing.T, env map[string]string) func() {
+	previous := map[string]string{}
+	for _, name := range ciVariables {
+		if value, exists := os.LookupEnv(name); exists {
+			previous[name] = value
+		}
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatalf("Could not set %s: %s", name, err)
+		}
+	}
+	return func() {
+		for _, name := range ciVariables {
+			if value, exists := previous[name]; exists {
+				os.Setenv(name, value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}
+}
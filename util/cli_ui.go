@@ -79,6 +79,16 @@ func (u *ColorizeUi) AskSecret(query string) (string, error) {
 	return u.Ui.AskSecret(u.colorize(query, u.OutputColor))
 }
 
+// Confirm asks a yes/no question and returns true only if the user answers yes.
+func (u *ColorizeUi) Confirm(query string) bool {
+	answer, err := u.Ask(fmt.Sprintf("%s [y/N]", query))
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func (u *ColorizeUi) Output(message string) {
 	u.Ui.Output(u.colorize(message, u.OutputColor))
 }